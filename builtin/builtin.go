@@ -14,73 +14,181 @@ type LookupByType struct {
 
 type FuncLookup struct {
 	Params []*parser.Field
+	Doc    string
 }
 
 var (
 	Lookup = BuiltinLookup{
 		ByType: map[parser.ObjType]LookupByType{
+			"[]string": LookupByType{
+				Func: map[string]FuncLookup{
+					"split": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "value", false),
+							parser.NewField(parser.Str, "sep", false),
+						},
+						Doc: "Splits a string into a list of substrings separated by the separator.",
+					},
+					"append": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "elems", true),
+						},
+						Doc: "Appends strings to the end of the list.",
+					},
+				},
+			},
+			parser.Bool: LookupByType{
+				Func: map[string]FuncLookup{
+					"equal": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "a", false),
+							parser.NewField(parser.Str, "b", false),
+						},
+						Doc: "Compares two strings for equality.",
+					},
+					"localFileExists": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "path", false),
+						},
+						Doc: "Checks whether a file exists on the local system. Relative paths are\nresolved relative to the module.",
+					},
+					"localEnvSet": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "key", false),
+						},
+						Doc: "Checks whether an environment variable is set on the local system, even if\nit is set to an empty value.",
+					},
+					"not": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Bool, "value", false),
+						},
+						Doc: "Negates a bool.",
+					},
+					"and": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Bool, "values", true),
+						},
+						Doc: "Checks whether all of the bools are true.",
+					},
+					"or": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Bool, "values", true),
+						},
+						Doc: "Checks whether any of the bools are true.",
+					},
+				},
+			},
 			parser.Filesystem: LookupByType{
 				Func: map[string]FuncLookup{
 					"scratch": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "An empty filesystem.",
 					},
 					"image": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "ref", false),
 						},
+						Doc: "An OCI image's filesystem.",
 					},
 					"http": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "url", false),
 						},
+						Doc: "A filesystem with a file retrieved from a HTTP URL.",
 					},
 					"git": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "remote", false),
 							parser.NewField(parser.Str, "ref", false),
 						},
+						Doc: "A filesystem with the files from a git repository checked out from\na git reference. Note that by default, the `.git` directory is not included.",
 					},
 					"local": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", false),
 						},
+						Doc: "A filesystem with the files synced up from a directory on the\nlocal system.",
 					},
 					"frontend": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "source", false),
 						},
+						Doc: "Generates a filesystem using an external frontend.",
 					},
 					"shell": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "arg", true),
 						},
+						Doc: "Sets the current shell command to use when executing subsequent `run`\nmethods. By default, this is [\"sh\", \"-c\"].",
 					},
 					"run": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "arg", true),
 						},
+						Doc: "Executes an command in the current filesystem.\nIf no arguments are given, it will execute the current args set on the\nfilesystem.\nIf exactly one arg is given it will be wrapped with /bin/sh -c 'arg'.\nIf more than one arg is given, it will be executed directly, without a shell.",
 					},
 					"env": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "key", false),
 							parser.NewField(parser.Str, "value", false),
 						},
+						Doc: "Sets an environment key pair for all subsequent calls in this filesystem\nblock.",
 					},
 					"dir": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", false),
 						},
+						Doc: "Sets the working directory for all subsequent calls in this filesystem block.",
 					},
 					"user": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "name", false),
 						},
+						Doc: "Sets the current user for all subsequent calls in this filesystem block,\nand the default user of the image config.",
+					},
+					"entrypoint": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "arg", true),
+						},
+						Doc: "Sets the entrypoint of the image config. Images have no entrypoint unless it\nis set, as the commands of `run` are not used as one.",
+					},
+					"cmd": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "arg", true),
+						},
+						Doc: "Sets the default arguments to the entrypoint of the image config.",
+					},
+					"label": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "key", false),
+							parser.NewField(parser.Str, "value", false),
+						},
+						Doc: "Sets a label in the image config.",
+					},
+					"expose": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "port", false),
+						},
+						Doc: "Adds a port to expose from containers of the image. Ports without a protocol\ndefault to tcp.",
+					},
+					"volume": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "path", false),
+						},
+						Doc: "Adds a volume to create for containers of the image.",
+					},
+					"stopSignal": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "signal", false),
+						},
+						Doc: "Sets the system call signal sent to containers of the image to exit.",
 					},
 					"mkdir": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", false),
 							parser.NewField(parser.Int, "filemode", false),
 						},
+						Doc: "Creates a directory in the current filesystem.",
 					},
 					"mkfile": FuncLookup{
 						Params: []*parser.Field{
@@ -88,11 +196,13 @@ var (
 							parser.NewField(parser.Int, "filemode", false),
 							parser.NewField(parser.Str, "content", false),
 						},
+						Doc: "Creates a file in the current filesystem.",
 					},
 					"rm": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", false),
 						},
+						Doc: "Removes a file from the current filesystem.",
 					},
 					"copy": FuncLookup{
 						Params: []*parser.Field{
@@ -100,37 +210,44 @@ var (
 							parser.NewField(parser.Str, "src", false),
 							parser.NewField(parser.Str, "dst", false),
 						},
+						Doc: "Copies a file from an input filesystem into the current filesystem.",
 					},
 					"dockerPush": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "ref", false),
 						},
+						Doc: "Pushes the filesystem to a registry following the distribution\nspec: https://github.com/opencontainers/distribution-spec/",
 					},
 					"dockerLoad": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "ref", false),
 						},
+						Doc: "Loads the filesystem as a Docker image to the docker client found in your\nenvironment.",
 					},
 					"download": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "localPath", false),
 						},
+						Doc: "Downloads the filesystem to a local path.",
 					},
 					"downloadTarball": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "localPath", false),
 						},
+						Doc: "Downloads the filesystem as a tarball to a local path.",
 					},
 					"downloadOCITarball": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "localPath", false),
 						},
+						Doc: "Downloads the filesystem as a OCI filesystem bundle to a local path.\nSee: https://github.com/opencontainers/runtime-spec/blob/master/bundle.md",
 					},
 					"downloadDockerTarball": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "localPath", false),
 							parser.NewField(parser.Str, "ref", false),
 						},
+						Doc: "Downloads the filesystem as a Docker image tarball to a local path.\nThe tarball is able to be loaded into a docker engine via `docker load`.\nSee: https://docs.docker.com/engine/reference/commandline/save/\nand https://docs.docker.com/engine/reference/commandline/load/",
 					},
 				},
 			},
@@ -140,43 +257,82 @@ var (
 						Params: []*parser.Field{
 							parser.NewField("group", "groups", true),
 						},
+						Doc: "Execute groups in parallel.",
+					},
+				},
+			},
+			parser.Int: LookupByType{
+				Func: map[string]FuncLookup{
+					"parseInt": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "value", false),
+						},
+						Doc: "Parses a string as an integer. The base is implied by the string's prefix,\nso `0o755` is parsed as an octal and `0x1f` as a hexadecimal.",
+					},
+					"add": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Int, "a", false),
+							parser.NewField(parser.Int, "b", false),
+						},
+						Doc: "Adds two integers.",
 					},
 				},
 			},
 			"option::copy": LookupByType{
 				Func: map[string]FuncLookup{
 					"followSymlinks": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Follow symlinks in the input filesystem and copy the symlink targets too.",
 					},
 					"contentsOnly": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "If the `src` path is a directory, only the contents of the directory is\ncopied to the destination.",
 					},
 					"unpack": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "If the `src` path is an archive, attempt to unpack its contents into the\ndestination.",
 					},
 					"createDestPath": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Create the parent directories of the destination if they don't already exist.",
 					},
 					"allowWildcard": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Allows wildcards in the path to copy.",
 					},
 					"allowEmptyWildcard": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Allows wildcards to match no files in the path to copy.",
 					},
 					"chown": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "owner", false),
 						},
+						Doc: "Change the owner of the copy path.",
 					},
 					"chmod": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "filemode", false),
 						},
+						Doc: "Modifies the permissions of the copied files.",
 					},
 					"createdTime": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "created", false),
 						},
+						Doc: "Sets the created time of the copy path.",
 					},
 				},
 			},
@@ -187,19 +343,24 @@ var (
 							parser.NewField(parser.Str, "key", false),
 							parser.NewField(parser.Filesystem, "value", false),
 						},
+						Doc: "Provide an input filesystem to the external frontend. Read the documentation\nfor the frontend to see what it will accept.",
 					},
 					"opt": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "key", false),
 							parser.NewField(parser.Str, "value", false),
 						},
+						Doc: "Provide a key value pair to the external frontend. Read the documentation\nfor the frontend to see what it will accept.",
 					},
 				},
 			},
 			"option::git": LookupByType{
 				Func: map[string]FuncLookup{
 					"keepGitDir": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Keeps the `.git` directory of the git repository.",
 					},
 				},
 			},
@@ -209,23 +370,35 @@ var (
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "digest", false),
 						},
+						Doc: "Verifies the checksum of the retrieved file against a digest.",
 					},
 					"chmod": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "filemode", false),
 						},
+						Doc: "Modifies the permissions of the retrieved file.",
 					},
 					"filename": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "name", false),
 						},
+						Doc: "Writes the retrieved file with a specified name.",
 					},
 				},
 			},
 			"option::image": LookupByType{
 				Func: map[string]FuncLookup{
 					"resolve": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Resolves the OCI Image Config and inherit its environment, working directory,\nand entrypoint.",
+					},
+					"platform": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "value", false),
+						},
+						Doc: "Selects the platform of the image to pull when the image is a manifest list.\nBy default, the platform the target is built for is used.",
 					},
 				},
 			},
@@ -235,16 +408,19 @@ var (
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "pattern", true),
 						},
+						Doc: "Sync only files that match any of the included patterns.",
 					},
 					"excludePatterns": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "pattern", true),
 						},
+						Doc: "Sync only files that do not match any of the excluded patterns.",
 					},
 					"followPaths": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", true),
 						},
+						Doc: "Sync the targets of symlinks if path is to a symlink.",
 					},
 				},
 			},
@@ -252,32 +428,41 @@ var (
 				Func: map[string]FuncLookup{
 					"ignoreError": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "If the command returns a non-zero status code ignore\nthe failure and continue processing the hlb file.",
 					},
 					"includeStderr": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "Capture stderr intermixed with stdout on the command.",
 					},
 					"onlyStderr": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "Only capture the stderr from the command, ignore stdout.",
 					},
 					"shlex": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "Attempt to lex the single-argument shell command provided to `localRun`\nto determine if a `/bin/sh -c '...'` wrapper needs to be added.",
 					},
 				},
 			},
 			"option::mkdir": LookupByType{
 				Func: map[string]FuncLookup{
 					"createParents": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Create the parent directories if they don't exist already.",
 					},
 					"chown": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "owner", false),
 						},
+						Doc: "Change the owner of the directory.",
 					},
 					"createdTime": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "created", false),
 						},
+						Doc: "Sets the created time of the directory.",
 					},
 				},
 			},
@@ -287,108 +472,145 @@ var (
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "owner", false),
 						},
+						Doc: "Change the owner of the file.",
 					},
 					"createdTime": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "created", false),
 						},
+						Doc: "Sets the created time of the file.",
 					},
 				},
 			},
 			"option::mount": LookupByType{
 				Func: map[string]FuncLookup{
 					"readonly": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Sets the mount to be attached as a read-only filesystem.",
 					},
 					"tmpfs": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Sets the mount to be attached as a tmpfs filesystem.",
 					},
 					"sourcePath": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", false),
 						},
+						Doc: "Mount a path from the input filesystem. By default, the root of the input\nfilesystem is mounted.",
 					},
 					"cache": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "cacheid", false),
 							parser.NewField(parser.Str, "sharingmode", false),
 						},
+						Doc: "Cache a snapshot of the mount after the run command has executed. A cacheid\nmust be provided to uniquely identify the cache mount.\nCompilers and package managers commonly have an option to specify cache\ndirectories. Depending on their implementation, it may be safe to share the\ncache with concurrent processes. This is adjusted via the `sharingmode`\nargument.\nThe cache is modified every time the parent run command is executed. A cache\ncould also be managed by not using the `cache` option. Instead, the mount can\nbe aliased, and then pushed as an image, so that there it can be a stable\nsnapshot, or updated externally.",
 					},
 				},
 			},
 			"option::rm": LookupByType{
 				Func: map[string]FuncLookup{
 					"allowNotFound": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Allows the file to not be found.",
 					},
 					"allowWildcard": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Allows wildcards in the path to remove.",
 					},
 				},
 			},
 			"option::run": LookupByType{
 				Func: map[string]FuncLookup{
 					"readonlyRootfs": FuncLookup{
-						Params: []*parser.Field{},
+						Params: []*parser.Field{
+							parser.NewDefaultField(parser.Bool, "value", parser.NewBoolExpr(true)),
+						},
+						Doc: "Sets the rootfs as read-only for the duration of the run command.",
 					},
 					"env": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "key", false),
 							parser.NewField(parser.Str, "value", false),
 						},
+						Doc: "Sets an environment key pair for the duration of the run command.",
 					},
 					"dir": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", false),
 						},
+						Doc: "Sets the working directory for the duration of the run command.",
 					},
 					"user": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "name", false),
 						},
+						Doc: "Sets the current user for the duration of the run command.",
 					},
 					"ignoreCache": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "Ignore any previously cached results for the run command.\n@ return an option to ignore existing cache for the run command.",
+					},
+					"platform": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField(parser.Str, "value", false),
+						},
+						Doc: "Sets the platform for the duration of the run command. By default, the\nplatform the target is built for is used.",
 					},
 					"network": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "networkmode", false),
 						},
+						Doc: "Sets the networking mode for the duration of the run command. By default, the\nvalue is `unset` (using BuildKit's CNI provider, otherwise its host\nnamespace).",
 					},
 					"security": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "securitymode", false),
 						},
+						Doc: "Sets the security mode for the duration of the run command. By default, the\nvalue is `sandbox`.",
 					},
 					"shlex": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "Attempt to lex the single-argument shell command provided to `run`\nto determine if a `/bin/sh -c '...'` wrapper needs to be added.",
 					},
 					"host": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "hostname", false),
 							parser.NewField(parser.Str, "address", false),
 						},
+						Doc: "Adds a host entry to /etc/hosts for the duration of the run command.",
 					},
 					"ssh": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "Mounts a SSH socket for the duration of the run command. By default, it will\ntry to use the SSH socket found from $SSH_AUTH_SOCK. Otherwise, an option\n`localPath` can be provided to specify a filepath to a SSH auth socket or\n*.pem file.",
 					},
 					"forward": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "src", false),
 							parser.NewField(parser.Str, "dest", false),
 						},
+						Doc: "Forwards traffic to/from a local source to a unix domain socket mounted for\nthe duration of the run command. The source must be a fully qualified URI\nwhere the scheme must be either `unix://` or `tcp://`.",
 					},
 					"secret": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "localPath", false),
 							parser.NewField(parser.Str, "mountPoint", false),
 						},
+						Doc: "Mounts a secure file for the duration of the run command. Secrets are\nattached via a tmpfs mount, so all the data stays in volatile memory.",
 					},
 					"mount": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Filesystem, "input", false),
 							parser.NewField(parser.Str, "mountPoint", false),
 						},
+						Doc: "Attaches an additional filesystem for the duration of the run command.",
 					},
 				},
 			},
@@ -398,26 +620,31 @@ var (
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "id", false),
 						},
+						Doc: "Sets the user ID for the secure file. By default, the UID is 0.",
 					},
 					"gid": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "id", false),
 						},
+						Doc: "Sets the group ID for the secure file. By default, the GID is 0.",
 					},
 					"mode": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "filemode", false),
 						},
+						Doc: "Sets the permissions for the secure file. By default, the file mode is 0o600.",
 					},
 					"includePatterns": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "pattern", true),
 						},
+						Doc: "Attach secrets only for files that match any of the included patterns.",
 					},
 					"excludePatterns": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "pattern", true),
 						},
+						Doc: "Attach secrets only for files that do not match any of the excluded patterns.",
 					},
 				},
 			},
@@ -427,26 +654,31 @@ var (
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "mountPoint", false),
 						},
+						Doc: "Sets the target directory to mount the SSH agent socket. By default, it is\nmounted to `/run/buildkit/ssh_agent.${N}`, where N is the index of the\nsocket. If $SSH_AUTH_SOCK is not set, it will set SSH_AUTH_SOCK to the\nmountPoint.",
 					},
 					"localPaths": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "path", true),
 						},
+						Doc: "Sets the paths for a single SSH agent socket or a list of PEM keys. By\ndefault, the SSH agent defined by $SSH_AUTH_SOCK will be forwarded into the\ncontainer.\nPEM files with passphrases are not supported atm.",
 					},
 					"uid": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "id", false),
 						},
+						Doc: "Sets the user ID for the SSH agent socket. By default, the UID is 0.",
 					},
 					"gid": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "id", false),
 						},
+						Doc: "Sets the group ID for the SSH agent socket. By default, the GID is 0.",
 					},
 					"mode": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Int, "filemode", false),
 						},
+						Doc: "Sets the permissions for the SSH agent socket. By default, the file mode is\n0o600.",
 					},
 				},
 			},
@@ -457,6 +689,7 @@ var (
 							parser.NewField(parser.Str, "name", false),
 							parser.NewField(parser.Str, "value", false),
 						},
+						Doc: "Add a string field with provided name to be available\ninside the template.",
 					},
 				},
 			},
@@ -467,31 +700,45 @@ var (
 							parser.NewField(parser.Str, "formatString", false),
 							parser.NewField(parser.Str, "values", true),
 						},
+						Doc: "A format specifier that is interpolated with values.",
 					},
 					"localArch": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "The architecture for the clients local environment.",
 					},
 					"localCwd": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "The current working directory from the clients local environment.",
 					},
 					"localEnv": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "key", false),
 						},
+						Doc: "An environment variable from the client's local environment.",
 					},
 					"localOs": FuncLookup{
 						Params: []*parser.Field{},
+						Doc:    "The OS from the clients local environment.",
 					},
 					"localRun": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "command", false),
 							parser.NewField(parser.Str, "args", true),
 						},
+						Doc: "Executes an command in the local environment.\nIf exactly one arg is given it will be wrapped with /bin/sh -c 'arg'.\nIf more than one arg is given, it will be executed directly, without a shell.",
 					},
 					"template": FuncLookup{
 						Params: []*parser.Field{
 							parser.NewField(parser.Str, "text", false),
 						},
+						Doc: "Process text as a Go text template.\nFor template syntax documentation see:\nhttps://golang.org/pkg/text/template/",
+					},
+					"join": FuncLookup{
+						Params: []*parser.Field{
+							parser.NewField("[]string", "elems", false),
+							parser.NewField(parser.Str, "sep", false),
+						},
+						Doc: "Concatenates the elements of a list of strings, placing the separator\nbetween them.",
 					},
 				},
 			},
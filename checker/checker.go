@@ -16,6 +16,21 @@ func CheckSelectors(mod *parser.Module) error {
 	return new(checker).CheckSelectors(mod)
 }
 
+// CheckExpr checks an expression of type typ against a scope that has already
+// been checked, like the scope of a function being debugged.
+func CheckExpr(scope *parser.Scope, typ parser.ObjType, expr *parser.Expr) error {
+	c := new(checker)
+	err := c.checkExpr(scope, typ, expr)
+	if err != nil {
+		return err
+	}
+
+	if len(c.errs) > 0 {
+		return ErrSemantic{c.errs}
+	}
+	return nil
+}
+
 type checker struct {
 	errs           []error
 	duplicateDecls []*parser.Ident
@@ -137,8 +152,16 @@ func (c *checker) Check(mod *parser.Module) error {
 			return false
 		case *parser.FuncDecl:
 			fun := n
+			if fun.Type != nil {
+				err := checkListType(fun.Type)
+				if err != nil {
+					c.errs = append(c.errs, err)
+					return false
+				}
+			}
+
 			if fun.Params != nil {
-				err := c.checkFieldList(fun.Params.List)
+				err := c.checkFieldList(mod.Scope, fun.Params.List)
 				if err != nil {
 					c.errs = append(c.errs, err)
 					return false
@@ -166,16 +189,49 @@ func (c *checker) Check(mod *parser.Module) error {
 
 func (c *checker) CheckSelectors(mod *parser.Module) error {
 	var (
-		fun  *parser.FuncDecl
-		call *parser.CallStmt
+		fun   *parser.FuncDecl
+		call  *parser.CallStmt
+		scope *parser.Scope
 	)
 
-	parser.Inspect(mod, func(node parser.Node) bool {
+	var inspect func(node parser.Node) bool
+	inspect = func(node parser.Node) bool {
 		switch n := node.(type) {
 		case *parser.FuncDecl:
 			fun = n
+			scope = n.Scope
+		case *parser.FieldList:
+			// Defaults of fields are checked in the module scope.
+			return false
 		case *parser.CallStmt:
 			call = n
+		case *parser.IfStmt:
+			// Named conditions are calls to bool functions, which may be
+			// selected from an import.
+			call = &parser.CallStmt{
+				Func: n.Cond,
+				Args: n.Args,
+			}
+		case *parser.ForStmt:
+			// Named lists are calls to list functions, which may be selected from
+			// an import.
+			call = &parser.CallStmt{
+				Func: n.List,
+				Args: n.Args,
+			}
+			parser.Inspect(n.List, inspect)
+			for _, arg := range n.Args {
+				parser.Inspect(arg, inspect)
+			}
+
+			// Arguments in the body of the loop may refer to the loop variable.
+			outer := scope
+			if n.Scope != nil {
+				scope = n.Scope
+			}
+			parser.Inspect(n.Body, inspect)
+			scope = outer
+			return false
 		case *parser.Expr:
 			if n.Selector == nil {
 				return false
@@ -210,7 +266,7 @@ func (c *checker) CheckSelectors(mod *parser.Module) error {
 
 					// Arguments are passed by value, so invoke the arguments in the
 					// function's scope, not the imported module's scope.
-					err = c.checkCallArgs(fun.Scope, n, args, with, params)
+					err = c.checkCallArgs(scope, n, args, with, params)
 					if err != nil {
 						c.errs = append(c.errs, err)
 					}
@@ -221,7 +277,8 @@ func (c *checker) CheckSelectors(mod *parser.Module) error {
 			return false
 		}
 		return true
-	})
+	}
+	parser.Inspect(mod, inspect)
 
 	if len(c.errs) > 0 {
 		return ErrSemantic{c.errs}
@@ -248,7 +305,7 @@ func (c *checker) registerDecl(scope *parser.Scope, ident *parser.Ident, node pa
 	})
 }
 
-func (c *checker) checkFieldList(fields []*parser.Field) error {
+func (c *checker) checkFieldList(scope *parser.Scope, fields []*parser.Field) error {
 	var dupFields []*parser.Field
 
 	// Check for duplicate fields.
@@ -274,6 +331,47 @@ func (c *checker) checkFieldList(fields []*parser.Field) error {
 		return ErrDuplicateFields{dupFields}
 	}
 
+	for _, field := range fields {
+		err := checkListType(field.Type)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Defaults are evaluated in the module scope, so they may not refer to the
+	// other fields.
+	var hasDefault bool
+	for _, field := range fields {
+		if field.Default == nil {
+			if hasDefault && field.Variadic == nil {
+				return ErrDefaultOrder{field}
+			}
+			continue
+		}
+
+		if field.Variadic != nil {
+			return ErrVariadicDefault{field}
+		}
+		hasDefault = true
+
+		err := c.checkExpr(scope, field.Type.Kind(), field.Default.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkListType checks that a list type, or a function type returning or taking
+// lists, only has lists of strings.
+func checkListType(typ *parser.Type) error {
+	ret, params := typ.Signature()
+	for _, t := range append([]parser.ObjType{ret}, params...) {
+		if t.IsList() && t.Elem() != parser.Str {
+			return ErrListElemType{typ}
+		}
+	}
 	return nil
 }
 
@@ -290,6 +388,22 @@ func (c *checker) checkBlockStmt(scope *parser.Scope, typ parser.ObjType, block
 			continue
 		}
 
+		if stmt.If != nil {
+			err := c.checkIfStmt(scope, typ, stmt.If)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if stmt.For != nil {
+			err := c.checkForStmt(scope, typ, stmt.For)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		call := stmt.Call
 		if call.Func == nil || call.Func.Name() == "breakpoint" {
 			continue
@@ -351,10 +465,15 @@ func (c *checker) checkBlockStmt(scope *parser.Scope, typ parser.ObjType, block
 					c.errs = append(c.errs, ErrUseModuleWithoutSelector{Ident: call.Func.IdentNode()})
 					continue
 				}
-			case parser.FieldKind:
+			case parser.FieldKind, parser.ExprKind:
 				field, ok := obj.Node.(*parser.Field)
 				if ok {
 					callType = field.Type
+					if callType.IsFunc() {
+						// Calling a function value results in its return type.
+						ret, _ := callType.Signature()
+						callType = parser.NewType(ret)
+					}
 				}
 			}
 
@@ -373,7 +492,97 @@ func (c *checker) checkBlockStmt(scope *parser.Scope, typ parser.ObjType, block
 	return nil
 }
 
+func (c *checker) checkIfStmt(scope *parser.Scope, typ parser.ObjType, ifStmt *parser.IfStmt) error {
+	err := c.checkCondition(scope, ifStmt)
+	if err != nil {
+		return err
+	}
+
+	// Only one of the branches will be emitted, but both must be valid for the
+	// type of the enclosing block.
+	err = c.checkBlockStmt(scope, typ, ifStmt.Body)
+	if err != nil {
+		return err
+	}
+
+	if ifStmt.Else != nil {
+		switch {
+		case ifStmt.Else.If != nil:
+			return c.checkIfStmt(scope, typ, ifStmt.Else.If)
+		case ifStmt.Else.Body != nil:
+			return c.checkBlockStmt(scope, typ, ifStmt.Else.Body)
+		}
+	}
+
+	return nil
+}
+
+func (c *checker) checkCondition(scope *parser.Scope, ifStmt *parser.IfStmt) error {
+	cond := ifStmt.Cond
+	switch {
+	case cond.Ident != nil, cond.Selector != nil:
+		// A named condition is a call to a bool function, so it is checked as if
+		// it was the only statement of a bool block.
+		return c.checkBlockStmt(scope, parser.Bool, parser.NewBlockStmt(&parser.Stmt{
+			Call: &parser.CallStmt{
+				Func: cond,
+				Args: ifStmt.Args,
+			},
+		}))
+	default:
+		if len(ifStmt.Args) > 0 {
+			return ErrNumArgs{cond, 0, len(ifStmt.Args)}
+		}
+		return c.checkExpr(scope, parser.Bool, cond)
+	}
+}
+
+func (c *checker) checkForStmt(scope *parser.Scope, typ parser.ObjType, forStmt *parser.ForStmt) error {
+	list := forStmt.List
+	switch {
+	case list.Ident != nil, list.Selector != nil:
+		// A named list is a call to a list function, so it is checked as if it was
+		// the only statement of a list block.
+		err := c.checkBlockStmt(scope, parser.ListOf(parser.Str), parser.NewBlockStmt(&parser.Stmt{
+			Call: &parser.CallStmt{
+				Func: list,
+				Args: forStmt.Args,
+			},
+		}))
+		if err != nil {
+			return err
+		}
+	default:
+		if len(forStmt.Args) > 0 {
+			return ErrNumArgs{list, 0, len(forStmt.Args)}
+		}
+
+		err := c.checkExpr(scope, parser.ListOf(parser.Str), list)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The loop variable is only in scope for the body of the loop.
+	forStmt.Scope = parser.NewScope(forStmt, scope)
+	forStmt.Scope.Insert(&parser.Object{
+		Kind:  parser.FieldKind,
+		Ident: forStmt.Var,
+		Node: &parser.Field{
+			Pos:  forStmt.Var.Pos,
+			Type: parser.NewType(parser.Str),
+			Name: forStmt.Var,
+		},
+	})
+
+	return c.checkBlockStmt(forStmt.Scope, typ, forStmt.Body)
+}
+
 func (c *checker) checkCallStmt(scope *parser.Scope, typ parser.ObjType, call *parser.CallStmt) error {
+	if call.Func.Named != nil {
+		return ErrNamedArg{call.Func.Named}
+	}
+
 	if call.Func.Selector != nil {
 		return nil
 	}
@@ -395,6 +604,13 @@ func (c *checker) checkCallSignature(scope *parser.Scope, typ parser.ObjType, ex
 
 	if ok {
 		signature = fun.Params
+
+		// Builtins are implemented with positional arguments.
+		for _, arg := range args {
+			if arg.Named != nil {
+				return nil, ErrNamedArg{arg.Named}
+			}
+		}
 	} else {
 		obj := scope.Lookup(expr.Name())
 		if obj == nil {
@@ -428,17 +644,39 @@ func (c *checker) checkCallSignature(scope *parser.Scope, typ parser.ObjType, ex
 			default:
 				panic("implementation error")
 			}
+		} else if field, ok := obj.Node.(*parser.Field); ok && field.Type.IsFunc() {
+			// Function values are called with arguments matching the parameter
+			// types of their function type.
+			_, paramTypes := field.Type.Signature()
+			for i, paramType := range paramTypes {
+				signature = append(signature, parser.NewField(paramType, fmt.Sprintf("%s[%d]", field.Name, i), false))
+			}
 		}
 	}
 
 	// When the signature has a variadic field, construct a temporary signature to
 	// match the calling arguments.
-	params := extendSignatureWithVariadic(signature, args)
+	params, err := extendSignatureWithVariadic(signature, args)
+	if err != nil {
+		return params, err
+	}
 
 	if len(params) != len(args) {
 		return params, ErrNumArgs{expr, len(params), len(args)}
 	}
 
+	// Fields without defaults must be passed an argument.
+	bound := make(map[*parser.Field]struct{})
+	for _, param := range params {
+		bound[param] = struct{}{}
+	}
+	for _, field := range signature {
+		if _, ok := bound[field]; ok || field.Variadic != nil || field.Default != nil {
+			continue
+		}
+		return params, ErrMissingArg{expr, field}
+	}
+
 	return params, nil
 }
 
@@ -452,10 +690,14 @@ func (c *checker) checkCallArgs(scope *parser.Scope, expr *parser.Expr, args []*
 	}
 
 	for i, arg := range args {
-		typ := params[i].Type.ObjType
-		err := c.checkExpr(scope, typ, arg)
+		typ := params[i].Type.Kind()
+		err := c.checkArg(scope, typ, arg)
 		if err != nil {
-			return err
+			// A list may be passed to a variadic param, spreading its elements
+			// as the variadic arguments.
+			if params[i].Variadic == nil || c.checkArg(scope, parser.ListOf(typ), arg) != nil {
+				return err
+			}
 		}
 	}
 
@@ -473,6 +715,15 @@ func (c *checker) checkCallArgs(scope *parser.Scope, expr *parser.Expr, args []*
 }
 
 func (c *checker) checkExpr(scope *parser.Scope, typ parser.ObjType, expr *parser.Expr) error {
+	if expr.Named != nil {
+		return ErrNamedArg{expr.Named}
+	}
+	return c.checkArg(scope, typ, expr)
+}
+
+// checkArg checks an expression passed as an argument to a call, which may be
+// named.
+func (c *checker) checkArg(scope *parser.Scope, typ parser.ObjType, expr *parser.Expr) error {
 	var err error
 	switch {
 	case expr.Bad != nil:
@@ -483,6 +734,8 @@ func (c *checker) checkExpr(scope *parser.Scope, typ parser.ObjType, expr *parse
 		err = c.checkIdentArg(scope, typ, expr.Ident)
 	case expr.BasicLit != nil:
 		err = c.checkBasicLitArg(typ, expr.BasicLit)
+	case expr.ListLit != nil:
+		err = c.checkListLitArg(scope, typ, expr.ListLit)
 	case expr.FuncLit != nil:
 		err = c.checkFuncLitArg(scope, typ, expr.FuncLit)
 	default:
@@ -506,21 +759,59 @@ func (c *checker) checkIdentArg(scope *parser.Scope, typ parser.ObjType, ident *
 	case parser.DeclKind:
 		switch n := obj.Node.(type) {
 		case *parser.FuncDecl:
+			if typ.IsFunc() {
+				// Functions passed as function values must match the parameter
+				// types and return type of the function type.
+				var paramTypes []parser.ObjType
+				if n.Params != nil {
+					for _, param := range n.Params.List {
+						paramTypes = append(paramTypes, param.Type.Kind())
+					}
+				}
+
+				found := parser.FuncOf(n.Type.ObjType, paramTypes...)
+				if found != typ {
+					return ErrWrongArgType{ident.Pos, typ, found}
+				}
+				return nil
+			}
+
+			err := CheckOptionSubtype(ident, typ, n.Type)
+			if err != nil {
+				return err
+			}
+
 			if n.Params.NumFields() > 0 {
 				return ErrFuncArg{ident}
 			}
 		case *parser.AliasDecl:
+			if typ.IsFunc() {
+				return ErrWrongArgType{ident.Pos, typ, n.Func.Type.ObjType}
+			}
+
 			if n.Func.Params.NumFields() > 0 {
 				return ErrFuncArg{ident}
 			}
 		default:
 			panic("unknown arg type")
 		}
-	case parser.FieldKind:
+	case parser.FieldKind, parser.ExprKind:
+		// Fields are bound to expressions when the scope of a function is
+		// checked during codegen, like the conditions of breakpoints.
 		var err error
 		switch n := obj.Node.(type) {
 		case *parser.Field:
-			err = c.checkType(ident, typ, n.Type)
+			fieldType := n.Type
+			if ret, params := fieldType.Signature(); fieldType.IsFunc() && !typ.IsFunc() && len(params) == 0 {
+				// Function values that take no arguments may be used as a value of
+				// their return type.
+				fieldType = parser.NewType(ret)
+			}
+			if n.Variadic != nil && fieldType.ObjType == parser.Str {
+				// Variadic string fields are bound to the list of their arguments.
+				fieldType = parser.NewType(parser.ListOf(parser.Str))
+			}
+			err = c.checkType(ident, typ, fieldType)
 		default:
 			panic("unknown arg type")
 		}
@@ -553,11 +844,35 @@ func (c *checker) checkBasicLitArg(typ parser.ObjType, lit *parser.BasicLit) err
 	return nil
 }
 
+func (c *checker) checkListLitArg(scope *parser.Scope, typ parser.ObjType, lit *parser.ListLit) error {
+	if !typ.IsList() {
+		found := parser.ListOf(parser.None)
+		if len(lit.Elems) > 0 && lit.Elems[0].BasicLit != nil {
+			found = parser.ListOf(lit.Elems[0].BasicLit.ObjType())
+		}
+		return ErrWrongArgType{lit.Pos, typ, found}
+	}
+
+	for _, elem := range lit.Elems {
+		err := c.checkExpr(scope, typ.Elem(), elem)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *checker) checkFuncLitArg(scope *parser.Scope, typ parser.ObjType, lit *parser.FuncLit) error {
 	if typ == parser.Group && lit.Type.ObjType == parser.Filesystem {
 		typ = lit.Type.ObjType
 	}
 
+	// Function literals have no parameters, so they may be passed as function
+	// values that take no arguments.
+	if ret, params := typ.Signature(); typ.IsFunc() && len(params) == 0 {
+		typ = ret
+	}
+
 	err := c.checkType(lit, typ, lit.Type)
 	if err != nil {
 		return err
@@ -568,11 +883,48 @@ func (c *checker) checkFuncLitArg(scope *parser.Scope, typ parser.ObjType, lit *
 
 func (c *checker) checkOptionBlockStmt(scope *parser.Scope, typ parser.ObjType, block *parser.BlockStmt) error {
 	for _, stmt := range block.List {
+		if stmt.If != nil {
+			err := c.checkIfStmt(scope, typ, stmt.If)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if stmt.For != nil {
+			return ErrForInOptionBlock{stmt.For}
+		}
+
 		call := stmt.Call
 		if call == nil || call.Func == nil {
 			continue
 		}
 
+		// Option functions may be composed of other option functions, which
+		// must have the same subtype.
+		if call.Func.Ident != nil {
+			_, ok := builtin.Lookup.ByType[typ].Func[call.Func.Ident.Name]
+			if obj := scope.Lookup(call.Func.Ident.Name); !ok && obj != nil {
+				var callType *parser.Type
+				switch n := obj.Node.(type) {
+				case *parser.FuncDecl:
+					callType = n.Type
+				case *parser.AliasDecl:
+					callType = n.Func.Type
+				case *parser.Field:
+					ret, _ := n.Type.Signature()
+					callType = parser.NewType(ret)
+				}
+
+				if callType != nil {
+					err := CheckOptionSubtype(call.Func.Ident, typ, callType)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
 		err := c.checkCallStmt(scope, typ, call)
 		if err != nil {
 			return err
@@ -583,22 +935,85 @@ func (c *checker) checkOptionBlockStmt(scope *parser.Scope, typ parser.ObjType,
 
 func (c *checker) checkType(node parser.Node, expected parser.ObjType, actual *parser.Type) error {
 	if !actual.Equals(expected) {
-		return ErrWrongArgType{node.Position(), expected, actual.ObjType}
+		return ErrWrongArgType{node.Position(), expected, actual.Kind()}
+	}
+	return nil
+}
+
+// CheckOptionSubtype checks that an option function called in place of an option
+// of type typ has the same subtype, as option functions are composed from the
+// options of their body.
+func CheckOptionSubtype(ident *parser.Ident, typ parser.ObjType, callType *parser.Type) error {
+	expected := parser.NewType(typ)
+	if expected.Primary() != parser.Option || expected.Secondary() == parser.None {
+		return nil
+	}
+
+	if callType.Primary() != parser.Option || (callType.Secondary() != parser.None && callType.Secondary() != expected.Secondary()) {
+		return ErrOptionSubtype{ident, typ, callType.ObjType}
 	}
 	return nil
 }
 
-func extendSignatureWithVariadic(fields []*parser.Field, args []*parser.Expr) []*parser.Field {
-	params := make([]*parser.Field, len(fields))
-	copy(params, fields)
+// extendSignatureWithVariadic returns the field each argument is passed to.
+// Positional arguments are matched in order, with the remaining arguments
+// passed to the variadic field if there is one, and named arguments are
+// matched by name. If there are too many arguments, the fields returned are
+// fewer than the arguments.
+func extendSignatureWithVariadic(fields []*parser.Field, args []*parser.Expr) ([]*parser.Field, error) {
+	var (
+		params   []*parser.Field
+		variadic *parser.Field
+		named    bool
+		bound    = make(map[string]struct{})
+	)
+
+	positional := fields
+	if len(fields) > 0 && fields[len(fields)-1].Variadic != nil {
+		variadic = fields[len(fields)-1]
+		positional = fields[:len(fields)-1]
+	}
+
+	for i, arg := range args {
+		if arg.Named != nil {
+			named = true
+
+			name := arg.Named.Name.Name
+			if _, ok := bound[name]; ok {
+				return params, ErrDuplicateArg{arg.Named}
+			}
+
+			var param *parser.Field
+			for _, field := range fields {
+				if field.Name.Name == name {
+					param = field
+					break
+				}
+			}
+			if param == nil {
+				return params, ErrNoSuchParam{arg.Named}
+			}
+
+			bound[name] = struct{}{}
+			params = append(params, param)
+			continue
+		}
+
+		if named {
+			return params, ErrPositionalAfterNamed{arg}
+		}
 
-	if len(params) > 0 && params[len(params)-1].Variadic != nil {
-		variadicParam := params[len(params)-1]
-		params = params[:len(params)-1]
-		for i := range args[len(params):] {
-			params = append(params, parser.NewField(variadicParam.Type.ObjType, fmt.Sprintf("%s[%d]", variadicParam.Name, i), false))
+		switch {
+		case i < len(positional):
+			bound[positional[i].Name.Name] = struct{}{}
+			params = append(params, positional[i])
+		case variadic != nil:
+			bound[variadic.Name.Name] = struct{}{}
+			params = append(params, parser.NewField(variadic.Type.Kind(), fmt.Sprintf("%s[%d]", variadic.Name, i-len(positional)), true))
+		default:
+			return params, nil
 		}
 	}
 
-	return params
+	return params, nil
 }
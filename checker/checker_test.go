@@ -335,6 +335,483 @@ func TestChecker_Check(t *testing.T) {
 		}
 		`,
 		nil,
+	}, {
+		"if else statement",
+		`
+		fs default(bool cond) {
+			if cond {
+				image "alpine"
+			} else if equal localOs "darwin" {
+				image "busybox"
+			} else {
+				scratch
+			}
+		}
+		`,
+		nil,
+	}, {
+		"if statement in option block",
+		`
+		fs default() {
+			image "alpine" with option {
+				if true {
+					resolve
+				}
+			}
+		}
+		`,
+		nil,
+	}, {
+		"bool func decls and params",
+		`
+		fs default() {
+			build false
+		}
+		fs build(bool dev) {
+			image "alpine" with option {
+				resolve dev
+			}
+			run "echo hi" with option {
+				mount scratch "/src" with option {
+					readonly bool { not dev; }
+				}
+				if and dev hasConfig {
+					dir "/tmp"
+				}
+			}
+		}
+		bool hasConfig() {
+			or bool { localFileExists "./config"; } bool { localEnvSet "CONFIG"; }
+		}
+		`,
+		nil,
+	}, {
+		"variadic string params are lists",
+		`
+		fs default() {
+			build "echo" "hi"
+		}
+		fs build(variadic string args) {
+			image "alpine"
+			run args
+			env "ARGS" string { join args ","; }
+		}
+		`,
+		nil,
+	}, {
+		"errors when variadic string param is used as a string",
+		`
+		fs build(variadic string args) {
+			image args
+		}
+		`,
+		ErrWrongArgType{
+			Pos: lexer.Position{
+				Filename: "<stdin>",
+				Line:     2,
+				Column:   7,
+			},
+			Expected: parser.Str,
+			Found:    parser.ListOf(parser.Str),
+		},
+	}, {
+		"errors when toggle option is not a bool",
+		`
+		fs default() {
+			scratch
+			mkdir "/foo" 0o755 with option {
+				createParents "true"
+			}
+		}
+		`,
+		ErrWrongArgType{
+			Pos: lexer.Position{
+				Filename: "<stdin>",
+				Line:     4,
+				Column:   16,
+			},
+			Expected: parser.Bool,
+			Found:    parser.Str,
+		},
+	}, {
+		"errors when a bool toggle is passed more than one arg",
+		`
+		fs default() {
+			image "alpine" with option {
+				resolve true false
+			}
+		}
+		`,
+		ErrNumArgs{
+			Node: &parser.Expr{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     3,
+					Column:   2,
+				},
+			},
+			Expected: 1,
+			Actual:   2,
+		},
+	}, {
+		"int func decls and params",
+		`
+		fs default() {
+			build 1000
+		}
+		fs build(int uid) {
+			image "alpine"
+			mkdir "/foo" int { add uid 1; }
+			run "echo hi" with option {
+				ssh with option {
+					uid uid
+					mode fileMode
+				}
+			}
+		}
+		int fileMode() {
+			parseInt "0o600"
+		}
+		`,
+		nil,
+	}, {
+		"errors when int func body is not an int",
+		`
+		int fileMode() {
+			localEnv "MODE"
+		}
+		`,
+		ErrIdentNotDefined{
+			Ident: &parser.Ident{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     2,
+					Column:   1,
+				},
+				Name: "localEnv",
+			},
+		},
+	}, {
+		"list literals and spreading lists into variadic params",
+		`
+		fs default() {
+			build ["*.go", "go.mod"]
+		}
+		fs build([]string patterns) {
+			local "." with option {
+				includePatterns patterns "go.sum"
+				excludePatterns []string {
+					split "vendor,.git" ","
+					append "bin"
+				}
+			}
+			run ["go", "build"] string { join patterns " "; }
+		}
+		`,
+		nil,
+	}, {
+		"errors when list is passed to a non-variadic param",
+		`
+		fs default() {
+			image ["alpine"]
+		}
+		`,
+		ErrWrongArgType{
+			Pos: lexer.Position{
+				Filename: "<stdin>",
+				Line:     2,
+				Column:   7,
+			},
+			Expected: parser.Str,
+			Found:    parser.ListOf(parser.Str),
+		},
+	}, {
+		"errors when list param does not have string elements",
+		`
+		fs default([]fs inputs) {
+			scratch
+		}
+		`,
+		ErrListElemType{
+			Type: &parser.Type{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     1,
+					Column:   12,
+				},
+				ObjType: parser.ListOf(parser.Filesystem),
+			},
+		},
+	}, {
+		"errors when list func does not return string elements",
+		`
+		[]int ports() {
+			split "80,443" ","
+		}
+		`,
+		ErrListElemType{
+			Type: &parser.Type{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     1,
+					Column:   1,
+				},
+				ObjType: parser.ListOf(parser.Int),
+			},
+		},
+	}, {
+		"for loops",
+		`
+		group default() {
+			for goos in platforms "linux,darwin" {
+				parallel fs { build goos; }
+			}
+		}
+		fs build(string goos) {
+			image "golang"
+			for goarch in ["amd64", "arm64"] {
+				run "go build" with option {
+					env "GOOS" goos
+					env "GOARCH" goarch
+				}
+			}
+		}
+		[]string platforms(string list) {
+			split list ","
+		}
+		`,
+		nil,
+	}, {
+		"errors when loop variable is used outside of the loop",
+		`
+		fs default() {
+			image "golang"
+			for goos in ["linux"] {
+				env "GOOS" goos
+			}
+			run "echo" goos
+		}
+		`,
+		ErrIdentNotDefined{
+			Ident: &parser.Ident{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     6,
+					Column:   12,
+				},
+				Name: "goos",
+			},
+		},
+	}, {
+		"errors when for loop is used in an option block",
+		`
+		fs default() {
+			image "golang"
+			run "go build" with option {
+				for goos in ["linux"] {
+					env "GOOS" goos
+				}
+			}
+		}
+		`,
+		ErrForInOptionBlock{
+			For: &parser.ForStmt{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     4,
+					Column:   2,
+				},
+			},
+		},
+	}, {
+		"function values",
+		`
+		fs default() {
+			build fs { image "alpine"; } addCerts
+		}
+		fs build(fs() base, fs(fs) transform) {
+			transform base
+			run "make"
+		}
+		fs addCerts(fs base) {
+			base
+			copy fs { image "certs"; } "/" "/etc/ssl"
+		}
+		`,
+		nil,
+	}, {
+		"errors when function value has the wrong signature",
+		`
+		fs default() {
+			build addUser
+		}
+		fs build(fs(fs) transform) {
+			transform scratch
+		}
+		fs addUser(string name) {
+			image "alpine"
+			run "adduser" name
+		}
+		`,
+		ErrWrongArgType{
+			Pos: lexer.Position{
+				Filename: "<stdin>",
+				Line:     2,
+				Column:   7,
+			},
+			Expected: parser.FuncOf(parser.Filesystem, parser.Filesystem),
+			Found:    parser.FuncOf(parser.Filesystem, parser.Str),
+		},
+	}, {
+		"default values and named args",
+		`
+		fs default() {
+			build "./cmd/hlb"
+			build "./cmd/hlb" "darwin"
+			build "./cmd/hlb" tags=["netgo"] goos="windows"
+			build pkg="./cmd/docgen"
+		}
+		fs build(string pkg, string goos = "linux", []string tags = [], fs base = golang) {
+			base
+			run "go build" with option {
+				env "GOOS" goos
+			}
+		}
+		fs golang() {
+			image "golang:alpine"
+		}
+		`,
+		nil,
+	}, {
+		"errors when a field without a default is not passed an arg",
+		`
+		fs default() {
+			build goos="darwin"
+		}
+		fs build(string pkg, string goos = "linux") {
+			image "golang"
+		}
+		`,
+		ErrMissingArg{
+			Node: &parser.Expr{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     2,
+					Column:   1,
+				},
+			},
+			Field: parser.NewField(parser.Str, "pkg", false),
+		},
+	}, {
+		"errors when named arg does not match a field",
+		`
+		fs default() {
+			build "./cmd/hlb" goarch="arm64"
+		}
+		fs build(string pkg, string goos = "linux") {
+			image "golang"
+		}
+		`,
+		ErrNoSuchParam{
+			Named: &parser.NamedArg{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     2,
+					Column:   19,
+				},
+				Name: parser.NewIdent("goarch"),
+			},
+		},
+	}, {
+		"composing option funcs",
+		`
+		fs default() {
+			image "golang"
+			run "go build" with option {
+				goCache
+				netrcSecret
+				if localEnvSet "CI" {
+					all goCache netrcSecret
+				}
+			}
+		}
+		option::run all(variadic option::run opts) {
+			opts
+		}
+		option::run goCache() {
+			mount scratch "/root/.cache/go-build" with option {
+				cache "go-build" "shared"
+			}
+		}
+		option::run netrcSecret() {
+			secret "./.netrc" "/root/.netrc"
+		}
+		`,
+		nil,
+	}, {
+		"errors when option func has a different subtype",
+		`
+		fs default() {
+			image "alpine"
+			run "make" with option {
+				goCache
+			}
+		}
+		option::mount goCache() {
+			cache "go-build" "shared"
+		}
+		`,
+		ErrOptionSubtype{
+			Ident: &parser.Ident{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     4,
+					Column:   2,
+				},
+				Name: "goCache",
+			},
+			Expected: parser.ObjType("option::run"),
+			Found:    parser.ObjType("option::mount"),
+		},
+	}, {
+		"errors when if condition is not a bool",
+		`
+		fs default() {
+			if "true" {
+				scratch
+			}
+		}
+		`,
+		ErrWrongArgType{
+			Pos: lexer.Position{
+				Filename: "<stdin>",
+				Line:     2,
+				Column:   4,
+			},
+			Expected: parser.Bool,
+			Found:    parser.Str,
+		},
+	}, {
+		"errors when if branch does not match block type",
+		`
+		group default() {
+			if true {
+				parallel fs { scratch; }
+			} else {
+				image "alpine"
+			}
+		}
+		`,
+		ErrIdentNotDefined{
+			Ident: &parser.Ident{
+				Pos: lexer.Position{
+					Filename: "<stdin>",
+					Line:     5,
+					Column:   2,
+				},
+				Name: "image",
+			},
+		},
 	}, {
 		"errors when fs statement is called in a group block",
 		`
@@ -487,3 +964,117 @@ func validateError(t *testing.T, expected error, actual error) {
 		require.Equal(t, expected.Error(), actual.Error())
 	}
 }
+
+func TestChecker_CheckExpr(t *testing.T) {
+	t.Parallel()
+
+	module, err := parser.Parse(strings.NewReader(cleanup(`
+	fs default(string ref) {
+		image ref
+	}
+	string greet(string name) {
+		format "hello %s" name
+	}
+	`)))
+	require.NoError(t, err)
+
+	err = Check(module)
+	require.NoError(t, err)
+
+	scope := module.Scope.Lookup("default").Node.(*parser.FuncDecl).Scope
+
+	for _, tc := range []struct {
+		name    string
+		typ     parser.ObjType
+		expr    *parser.Expr
+		errType error
+	}{{
+		"literal",
+		parser.Str,
+		parser.NewStringExpr("hello"),
+		nil,
+	}, {
+		"call with param in scope",
+		parser.Str,
+		parser.NewFuncLitExpr(parser.Str, parser.NewCallStmt("greet", []*parser.Expr{parser.NewIdentExpr("ref")}, nil, nil)),
+		nil,
+	}, {
+		"missing arg",
+		parser.Str,
+		parser.NewFuncLitExpr(parser.Str, parser.NewCallStmt("greet", nil, nil, nil)),
+		ErrMissingArg{},
+	}, {
+		"ident not in scope",
+		parser.Filesystem,
+		parser.NewFuncLitExpr(parser.Filesystem, parser.NewCallStmt("image", []*parser.Expr{parser.NewIdentExpr("name")}, nil, nil)),
+		ErrIdentNotDefined{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckExpr(scope, tc.typ, tc.expr)
+			if tc.errType == nil {
+				require.NoError(t, err)
+			} else {
+				require.IsType(t, tc.errType, err)
+			}
+		})
+	}
+}
+
+func TestChecker_CheckExprWithBoundArgs(t *testing.T) {
+	t.Parallel()
+
+	module, err := parser.Parse(strings.NewReader(cleanup(`
+	fs default(string ref) {
+		image ref
+	}
+	`)))
+	require.NoError(t, err)
+
+	err = Check(module)
+	require.NoError(t, err)
+
+	// Codegen binds the arguments of a function to its parameters as
+	// expressions, which debugger expressions are checked against.
+	fun := module.Scope.Lookup("default").Node.(*parser.FuncDecl)
+	field := fun.Params.List[0]
+	scope := parser.NewScope(fun, module.Scope)
+	scope.Insert(&parser.Object{
+		Kind:  parser.ExprKind,
+		Ident: field.Name,
+		Node:  field,
+		Data:  "alpine",
+	})
+
+	for _, tc := range []struct {
+		name    string
+		typ     parser.ObjType
+		expr    *parser.Expr
+		errType error
+	}{{
+		"bound arg",
+		parser.Str,
+		parser.NewFuncLitExpr(parser.Str, parser.NewCallStmt("ref", nil, nil, nil)),
+		nil,
+	}, {
+		"bound arg passed to call",
+		parser.Bool,
+		parser.NewFuncLitExpr(parser.Bool, parser.NewCallStmt("equal", []*parser.Expr{parser.NewIdentExpr("ref"), parser.NewStringExpr("alpine")}, nil, nil)),
+		nil,
+	}, {
+		"bound arg of wrong type",
+		parser.Bool,
+		parser.NewFuncLitExpr(parser.Bool, parser.NewCallStmt("ref", nil, nil, nil)),
+		ErrWrongArgType{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckExpr(scope, tc.typ, tc.expr)
+			if tc.errType == nil {
+				require.NoError(t, err)
+			} else {
+				require.IsType(t, tc.errType, err)
+			}
+		})
+	}
+}
@@ -49,6 +49,89 @@ func (e ErrInvalidFunc) Error() string {
 	return fmt.Sprintf("%s invalid func %s", FormatPos(e.CallStmt.Pos), e.CallStmt.Func)
 }
 
+type ErrForInOptionBlock struct {
+	For *parser.ForStmt
+}
+
+func (e ErrForInOptionBlock) Error() string {
+	return fmt.Sprintf("%s for loops cannot be used in option blocks", FormatPos(e.For.Pos))
+}
+
+type ErrListElemType struct {
+	Type *parser.Type
+}
+
+func (e ErrListElemType) Error() string {
+	return fmt.Sprintf("%s list type %s must have string elements", FormatPos(e.Type.Pos), e.Type)
+}
+
+type ErrDefaultOrder struct {
+	Field *parser.Field
+}
+
+func (e ErrDefaultOrder) Error() string {
+	return fmt.Sprintf("%s field %s without a default must come before fields with defaults", FormatPos(e.Field.Pos), e.Field.Name)
+}
+
+type ErrVariadicDefault struct {
+	Field *parser.Field
+}
+
+func (e ErrVariadicDefault) Error() string {
+	return fmt.Sprintf("%s variadic field %s cannot have a default", FormatPos(e.Field.Pos), e.Field.Name)
+}
+
+type ErrNamedArg struct {
+	Named *parser.NamedArg
+}
+
+func (e ErrNamedArg) Error() string {
+	return fmt.Sprintf("%s named arg %s can only be passed to declared functions", FormatPos(e.Named.Pos), e.Named.Name)
+}
+
+type ErrNoSuchParam struct {
+	Named *parser.NamedArg
+}
+
+func (e ErrNoSuchParam) Error() string {
+	return fmt.Sprintf("%s no param named %s", FormatPos(e.Named.Pos), e.Named.Name)
+}
+
+type ErrDuplicateArg struct {
+	Named *parser.NamedArg
+}
+
+func (e ErrDuplicateArg) Error() string {
+	return fmt.Sprintf("%s param %s already has an arg", FormatPos(e.Named.Pos), e.Named.Name)
+}
+
+type ErrPositionalAfterNamed struct {
+	Arg *parser.Expr
+}
+
+func (e ErrPositionalAfterNamed) Error() string {
+	return fmt.Sprintf("%s positional arg cannot follow named args", FormatPos(e.Arg.Pos))
+}
+
+type ErrMissingArg struct {
+	Node  parser.Node
+	Field *parser.Field
+}
+
+func (e ErrMissingArg) Error() string {
+	return fmt.Sprintf("%s missing arg for param %s", FormatPos(e.Node.Position()), e.Field.Name)
+}
+
+type ErrOptionSubtype struct {
+	Ident    *parser.Ident
+	Expected parser.ObjType
+	Found    parser.ObjType
+}
+
+func (e ErrOptionSubtype) Error() string {
+	return fmt.Sprintf("%s %s is %s and cannot be used as %s", FormatPos(e.Ident.Pos), e.Ident, e.Found, e.Expected)
+}
+
 type ErrNumArgs struct {
 	Node     parser.Node
 	Expected int
@@ -35,6 +35,7 @@ func App() *cli.App {
 		formatCommand,
 		moduleCommand,
 		langserverCommand,
+		dapCommand,
 	}
 	return app
 }
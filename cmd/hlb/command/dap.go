@@ -0,0 +1,40 @@
+package command
+
+import (
+	"log"
+	"os"
+
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/openllb/hlb/dap"
+	"github.com/openllb/hlb/solver"
+	cli "github.com/urfave/cli/v2"
+)
+
+var dapCommand = &cli.Command{
+	Name:  "dap",
+	Usage: "run a debug adapter protocol server for the hlb debugger",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "logfile",
+			Usage: "file to log output",
+			Value: "/tmp/hlb-dap.log",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		f, err := os.Create(c.String("logfile"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log.SetOutput(f)
+
+		ctx := appcontext.Context()
+		cln, err := solver.BuildkitClient(ctx, c.String("addr"))
+		if err != nil {
+			return err
+		}
+
+		s := dap.NewServer(cln)
+		return s.Listen(ctx, os.Stdin, os.Stdout)
+	},
+}
@@ -8,9 +8,11 @@ import (
 	"os"
 	"strings"
 
+	"github.com/containerd/containerd/platforms"
 	"github.com/mattn/go-isatty"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/util/appcontext"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/openllb/hlb"
 	"github.com/openllb/hlb/codegen"
 	"github.com/openllb/hlb/local"
@@ -34,10 +36,22 @@ var runCommand = &cli.Command{
 			Usage:   "specify target filesystem to solve",
 			Value:   cli.NewStringSlice("default"),
 		},
+		&cli.StringSliceFlag{
+			Name:  "platform",
+			Usage: "specify platforms to build targets for, e.g. linux/arm64",
+		},
 		&cli.BoolFlag{
 			Name:  "debug",
 			Usage: "jump into a source level debugger for hlb",
 		},
+		&cli.StringFlag{
+			Name:  "debug-script",
+			Usage: "run the debugger with commands read from a file",
+		},
+		&cli.BoolFlag{
+			Name:  "trace-codegen",
+			Usage: "print every step of codegen",
+		},
 		&cli.BoolFlag{
 			Name:  "tree",
 			Usage: "print out the request tree without solving",
@@ -62,23 +76,29 @@ var runCommand = &cli.Command{
 		}
 
 		return Run(ctx, cln, rc, RunOptions{
-			Debug:     c.Bool("debug"),
-			Tree:      c.Bool("tree"),
-			Targets:   c.StringSlice("target"),
-			LLB:       c.Bool("llb"),
-			LogOutput: c.String("log-output"),
-			Output:    os.Stdout,
+			Debug:        c.Bool("debug"),
+			DebugScript:  c.String("debug-script"),
+			TraceCodegen: c.Bool("trace-codegen"),
+			Tree:         c.Bool("tree"),
+			Targets:      c.StringSlice("target"),
+			Platforms:    c.StringSlice("platform"),
+			LLB:          c.Bool("llb"),
+			LogOutput:    c.String("log-output"),
+			Output:       os.Stdout,
 		})
 	},
 }
 
 type RunOptions struct {
-	Debug     bool
-	Tree      bool
-	Targets   []string
-	LLB       bool
-	LogOutput string
-	Output    io.Writer
+	Debug        bool
+	DebugScript  string
+	TraceCodegen bool
+	Tree         bool
+	Targets      []string
+	Platforms    []string
+	LLB          bool
+	LogOutput    string
+	Output       io.Writer
 
 	// override defaults sources as necessary
 	Environ []string
@@ -104,7 +124,28 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, opts RunOpti
 	ctx = local.WithOs(ctx, opts.Os)
 	ctx = local.WithArch(ctx, opts.Arch)
 
+	var compileOpts []hlb.CompileOption
+	if opts.DebugScript != "" {
+		f, err := os.Open(opts.DebugScript)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		// Debug scripts drive the same debugger as --debug.
+		opts.Debug = true
+		compileOpts = append(compileOpts, hlb.WithDebugScript(f))
+	}
+	if opts.TraceCodegen {
+		compileOpts = append(compileOpts, hlb.WithTrace(os.Stderr))
+	}
+
 	var progressOpts []solver.ProgressOption
+	if opts.TraceCodegen && (opts.LogOutput == "" || opts.LogOutput == "auto") {
+		// Traces are interleaved with the progress, which a tty would redraw
+		// over.
+		opts.LogOutput = "plain"
+	}
 	if opts.LogOutput == "" || opts.LogOutput == "auto" {
 		// assume plain output, will upgrade if we detect tty
 		opts.LogOutput = "plain"
@@ -121,9 +162,9 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, opts RunOpti
 	case "plain":
 		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputPlain))
 	case "json":
-		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputJSON))
+		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputJSON), solver.WithOutput(opts.Output))
 	case "raw":
-		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputRaw))
+		progressOpts = append(progressOpts, solver.WithLogOutput(solver.LogOutputRaw), solver.WithOutput(opts.Output))
 	default:
 		return fmt.Errorf("unrecognized log-output %q", opts.LogOutput)
 	}
@@ -139,6 +180,11 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, opts RunOpti
 		}
 	}
 
+	defaultPlatforms, err := parsePlatforms(opts.Platforms)
+	if err != nil {
+		return err
+	}
+
 	var targets []codegen.Target
 	for _, target := range opts.Targets {
 		r := csv.NewReader(strings.NewReader(target))
@@ -161,14 +207,23 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, opts RunOpti
 				t.Outputs = append(t.Outputs, codegen.Output{Type: codegen.OutputDownloadTarball, LocalPath: strings.TrimPrefix(field, "downloadTarball=")})
 			case strings.HasPrefix(field, "downloadOCITarball="):
 				t.Outputs = append(t.Outputs, codegen.Output{Type: codegen.OutputDownloadOCITarball, LocalPath: strings.TrimPrefix(field, "downloadOCITarball=")})
+			case strings.HasPrefix(field, "platform="):
+				ps, err := parsePlatforms([]string{strings.TrimPrefix(field, "platform=")})
+				if err != nil {
+					return err
+				}
+				t.Platforms = append(t.Platforms, ps...)
 			default:
 				return fmt.Errorf("Unknown target option %q for target %q", field, t.Name)
 			}
 		}
+		if len(t.Platforms) == 0 {
+			t.Platforms = defaultPlatforms
+		}
 		targets = append(targets, t)
 	}
 
-	solveReq, err := hlb.Compile(ctx, cln, p, targets, rc)
+	solveReq, err := hlb.Compile(ctx, cln, p, targets, rc, compileOpts...)
 	if err != nil {
 		// Ignore early exits from the debugger.
 		if err == codegen.ErrDebugExit {
@@ -208,6 +263,18 @@ func Run(ctx context.Context, cln *client.Client, rc io.ReadCloser, opts RunOpti
 	return p.Wait()
 }
 
+func parsePlatforms(values []string) ([]specs.Platform, error) {
+	var ps []specs.Platform
+	for _, value := range values {
+		p, err := platforms.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, platforms.Normalize(p))
+	}
+	return ps, nil
+}
+
 func ModuleReadCloser(args []string) (io.ReadCloser, error) {
 	if len(args) == 0 {
 		return os.Open(DefaultHLBFilename)
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -15,6 +16,7 @@ import (
 	gateway "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/session/filesync"
 	"github.com/moby/buildkit/solver/pb"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/openllb/hlb/local"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/solver"
@@ -29,6 +31,12 @@ type GroupChain func([]solver.Request) ([]solver.Request, error)
 
 type StringChain func(string) (string, error)
 
+type StringListChain func([]string) ([]string, error)
+
+type IntChain func(int) (int, error)
+
+type BoolChain func(bool) (bool, error)
+
 func (cg *CodeGen) EmitChainStmt(ctx context.Context, scope *parser.Scope, typ parser.ObjType, call *parser.CallStmt, ac aliasCallback, chainStart interface{}) (func(v interface{}) (interface{}, error), error) {
 	switch typ {
 	case parser.Filesystem:
@@ -55,6 +63,42 @@ func (cg *CodeGen) EmitChainStmt(ctx context.Context, scope *parser.Scope, typ p
 			}
 			return chain(str)
 		}, nil
+	case parser.ListOf(parser.Str):
+		chain, err := cg.EmitStringListChainStmt(ctx, scope, call.Func, call.Args, chainStart)
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) (interface{}, error) {
+			list, ok := v.([]string)
+			if !ok {
+				return list, errors.WithStack(ErrCodeGen{call, ErrBadCast})
+			}
+			return chain(list)
+		}, nil
+	case parser.Int:
+		chain, err := cg.EmitIntChainStmt(ctx, scope, call.Func, call.Args, chainStart)
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) (interface{}, error) {
+			i, ok := v.(int)
+			if !ok {
+				return i, errors.WithStack(ErrCodeGen{call, ErrBadCast})
+			}
+			return chain(i)
+		}, nil
+	case parser.Bool:
+		chain, err := cg.EmitBoolChainStmt(ctx, scope, call.Func, call.Args, chainStart)
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) (interface{}, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return b, errors.WithStack(ErrCodeGen{call, ErrBadCast})
+			}
+			return chain(b)
+		}, nil
 	case parser.Group:
 		chain, err := cg.EmitGroupChainStmt(ctx, scope, call.Func, call.Args, call.WithOpt, ac, chainStart)
 		if err != nil {
@@ -108,7 +152,7 @@ func (cg *CodeGen) EmitFilesystemChainStmt(ctx context.Context, scope *parser.Sc
 				return fc, errors.WithStack(ErrCodeGen{m, errors.Errorf("unknown obj type")})
 			}
 		case *parser.Field:
-			v = obj.Data
+			v, err = cg.EmitFieldValue(ctx, scope, obj, args, ac, chainStart)
 		default:
 			return fc, errors.WithStack(ErrCodeGen{n, errors.Errorf("unknown obj type")})
 		}
@@ -132,7 +176,7 @@ func (cg *CodeGen) EmitShellCommand(ctx context.Context, scope *parser.Scope, ar
 		return nil, nil
 	}
 
-	if len(args) == 1 {
+	if len(args) == 1 && !cg.isStringListExpr(scope, args[0]) {
 		commandStr, err := cg.EmitStringExpr(ctx, scope, args[0])
 		if err != nil {
 			return nil, err
@@ -148,15 +192,7 @@ func (cg *CodeGen) EmitShellCommand(ctx context.Context, scope *parser.Scope, ar
 
 		return []string{"/bin/sh", "-c", commandStr}, nil
 	}
-	var runArgs []string
-	for _, arg := range args {
-		runArg, err := cg.EmitStringExpr(ctx, scope, arg)
-		if err != nil {
-			return nil, err
-		}
-		runArgs = append(runArgs, runArg)
-	}
-	return runArgs, nil
+	return cg.EmitStringExprs(ctx, scope, args)
 }
 
 func (cg *CodeGen) EmitFilesystemBuiltinChainStmt(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []*parser.Expr, with *parser.WithOpt, ac aliasCallback, chainStart interface{}) (fc FilesystemChain, err error) {
@@ -348,7 +384,12 @@ func (cg *CodeGen) EmitFilesystemBuiltinChainStmt(ctx context.Context, scope *pa
 				// Mounts inside option functions cannot be aliased because they need
 				// to be in the context of a specific function run is in.
 			case with.Expr.FuncLit != nil:
-				for _, stmt := range with.Expr.FuncLit.Body.NonEmptyStmts() {
+				stmts, err := cg.expandIfStmts(ctx, scope, with.Expr.FuncLit.Body.NonEmptyStmts())
+				if err != nil {
+					return fc, err
+				}
+
+				for _, stmt := range stmts {
 					if stmt.Call.Func.Name() != "mount" || stmt.Call.Alias == nil {
 						continue
 					}
@@ -420,7 +461,85 @@ func (cg *CodeGen) EmitFilesystemBuiltinChainStmt(ctx context.Context, scope *pa
 		}
 
 		fc = func(st llb.State) (llb.State, error) {
-			return st.User(name), nil
+			return withImageConfig(ctx, st.User(name), func(config *specs.ImageConfig) {
+				config.User = name
+			})
+		}
+	case "entrypoint":
+		entrypoint, err := cg.EmitStringExprs(ctx, scope, args)
+		if err != nil {
+			return fc, err
+		}
+
+		fc = func(st llb.State) (llb.State, error) {
+			return withImageConfig(ctx, st, func(config *specs.ImageConfig) {
+				// An empty entrypoint is still set so it overrides the args.
+				config.Entrypoint = append([]string{}, entrypoint...)
+			})
+		}
+	case "cmd":
+		cmd, err := cg.EmitStringExprs(ctx, scope, args)
+		if err != nil {
+			return fc, err
+		}
+
+		fc = func(st llb.State) (llb.State, error) {
+			return withImageConfig(ctx, st, func(config *specs.ImageConfig) {
+				config.Cmd = cmd
+			})
+		}
+	case "label":
+		key, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return fc, err
+		}
+
+		value, err := cg.EmitStringExpr(ctx, scope, args[1])
+		if err != nil {
+			return fc, err
+		}
+
+		fc = func(st llb.State) (llb.State, error) {
+			return withImageConfig(ctx, st, func(config *specs.ImageConfig) {
+				config.Labels[key] = value
+			})
+		}
+	case "expose":
+		port, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return fc, err
+		}
+
+		if !strings.Contains(port, "/") {
+			port = fmt.Sprintf("%s/tcp", port)
+		}
+
+		fc = func(st llb.State) (llb.State, error) {
+			return withImageConfig(ctx, st, func(config *specs.ImageConfig) {
+				config.ExposedPorts[port] = struct{}{}
+			})
+		}
+	case "volume":
+		path, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return fc, err
+		}
+
+		fc = func(st llb.State) (llb.State, error) {
+			return withImageConfig(ctx, st, func(config *specs.ImageConfig) {
+				config.Volumes[path] = struct{}{}
+			})
+		}
+	case "stopSignal":
+		signal, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return fc, err
+		}
+
+		fc = func(st llb.State) (llb.State, error) {
+			return withImageConfig(ctx, st, func(config *specs.ImageConfig) {
+				config.StopSignal = signal
+			})
 		}
 	case "mkdir":
 		path, err := cg.EmitStringExpr(ctx, scope, args[0])
@@ -811,18 +930,33 @@ func (cg *CodeGen) EmitStringChainStmt(ctx context.Context, scope *parser.Scope,
 			return nil, err
 		}
 
+		values, err := cg.EmitStringExprs(ctx, scope, args[1:])
+		if err != nil {
+			return nil, err
+		}
+
 		var as []interface{}
-		for _, arg := range args[1:] {
-			a, err := cg.EmitStringExpr(ctx, scope, arg)
-			if err != nil {
-				return nil, err
-			}
-			as = append(as, a)
+		for _, value := range values {
+			as = append(as, value)
 		}
 
 		return func(_ string) (string, error) {
 			return fmt.Sprintf(formatStr, as...), nil
 		}, nil
+	case "join":
+		elems, err := cg.EmitStringListExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		sep, err := cg.EmitStringExpr(ctx, scope, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ string) (string, error) {
+			return strings.Join(elems, sep), nil
+		}, nil
 	case "localArch":
 		return func(_ string) (string, error) {
 			return local.Arch(ctx), nil
@@ -908,51 +1042,244 @@ func (cg *CodeGen) EmitStringChainStmt(ctx context.Context, scope *parser.Scope,
 		}, nil
 	default:
 		// Must be a named reference.
-		obj := scope.Lookup(expr.Name())
-		if obj == nil {
-			return nil, errors.WithStack(ErrCodeGen{expr.IdentNode(), ErrUndefinedReference})
+		obj, v, err := cg.EmitNamedReference(ctx, scope, expr, args, chainStart)
+		if err != nil {
+			return nil, err
 		}
+		return func(_ string) (string, error) {
+			str, ok := v.(string)
+			if !ok {
+				return str, errors.WithStack(ErrCodeGen{obj.Node, ErrBadCast})
+			}
+			return str, nil
+		}, nil
+	}
+}
 
-		var v interface{}
-		var err error
-		switch n := obj.Node.(type) {
-		case *parser.FuncDecl:
-			v, err = cg.EmitFuncDecl(ctx, scope, n, args, noopAliasCallback, chainStart)
-		case *parser.AliasDecl:
-			v, err = cg.EmitAliasDecl(ctx, scope, n, args, chainStart)
-		case *parser.ImportDecl:
-			importScope := obj.Data.(*parser.Scope)
-			importObj := importScope.Lookup(expr.Selector.Select.Name)
-			if importObj == nil {
-				return nil, errors.WithStack(ErrCodeGen{expr.Selector, ErrUndefinedReference})
+func (cg *CodeGen) EmitStringListChainStmt(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []*parser.Expr, chainStart interface{}) (StringListChain, error) {
+	switch expr.Name() {
+	case "split":
+		value, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		sep, err := cg.EmitStringExpr(ctx, scope, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ []string) ([]string, error) {
+			return strings.Split(value, sep), nil
+		}, nil
+	case "append":
+		elems, err := cg.EmitStringExprs(ctx, scope, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(list []string) ([]string, error) {
+			return append(append([]string{}, list...), elems...), nil
+		}, nil
+	default:
+		// Must be a named reference.
+		obj, v, err := cg.EmitNamedReference(ctx, scope, expr, args, chainStart)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ []string) ([]string, error) {
+			list, ok := v.([]string)
+			if !ok {
+				return list, errors.WithStack(ErrCodeGen{obj.Node, ErrBadCast})
 			}
+			return list, nil
+		}, nil
+	}
+}
 
-			switch m := importObj.Node.(type) {
-			case *parser.FuncDecl:
-				v, err = cg.EmitFuncDecl(ctx, scope, m, args, noopAliasCallback, chainStart)
-			case *parser.AliasDecl:
-				v, err = cg.EmitAliasDecl(ctx, scope, m, args, chainStart)
-			default:
-				return nil, errors.WithStack(ErrCodeGen{n, errors.Errorf("unknown obj type")})
+func (cg *CodeGen) EmitIntChainStmt(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []*parser.Expr, chainStart interface{}) (IntChain, error) {
+	switch expr.Name() {
+	case "parseInt":
+		str, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ int) (int, error) {
+			i, err := strconv.ParseInt(str, 0, 0)
+			if err != nil {
+				return 0, errors.WithStack(ErrCodeGen{args[0], err})
 			}
-		case *parser.Field:
-			v = obj.Data
-		default:
-			return nil, errors.WithStack(ErrCodeGen{n, errors.Errorf("unknown obj type")})
+			return int(i), nil
+		}, nil
+	case "add":
+		a, err := cg.EmitIntExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
 		}
+
+		b, err := cg.EmitIntExpr(ctx, scope, args[1])
 		if err != nil {
 			return nil, err
 		}
-		return func(_ string) (string, error) {
-			str, ok := v.(string)
+
+		return func(_ int) (int, error) {
+			return a + b, nil
+		}, nil
+	default:
+		// Must be a named reference.
+		obj, v, err := cg.EmitNamedReference(ctx, scope, expr, args, chainStart)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ int) (int, error) {
+			i, ok := v.(int)
 			if !ok {
-				return str, errors.WithStack(ErrCodeGen{obj.Node, ErrBadCast})
+				return i, errors.WithStack(ErrCodeGen{obj.Node, ErrBadCast})
 			}
-			return str, nil
+			return i, nil
 		}, nil
 	}
 }
 
+func (cg *CodeGen) EmitBoolChainStmt(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []*parser.Expr, chainStart interface{}) (BoolChain, error) {
+	switch expr.Name() {
+	case "equal":
+		a, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := cg.EmitStringExpr(ctx, scope, args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ bool) (bool, error) {
+			return a == b, nil
+		}, nil
+	case "localFileExists":
+		path, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		path, err = ResolvePathForNode(scope.Node, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ bool) (bool, error) {
+			_, err := os.Stat(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return false, nil
+				}
+				return false, err
+			}
+			return true, nil
+		}, nil
+	case "localEnvSet":
+		key, err := cg.EmitStringExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ bool) (bool, error) {
+			_, ok := local.LookupEnv(ctx, key)
+			return ok, nil
+		}, nil
+	case "not":
+		v, err := cg.EmitBoolExpr(ctx, scope, args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(_ bool) (bool, error) {
+			return !v, nil
+		}, nil
+	case "and":
+		v := true
+		for _, arg := range args {
+			b, err := cg.EmitBoolExpr(ctx, scope, arg)
+			if err != nil {
+				return nil, err
+			}
+			v = v && b
+		}
+
+		return func(_ bool) (bool, error) {
+			return v, nil
+		}, nil
+	case "or":
+		v := false
+		for _, arg := range args {
+			b, err := cg.EmitBoolExpr(ctx, scope, arg)
+			if err != nil {
+				return nil, err
+			}
+			v = v || b
+		}
+
+		return func(_ bool) (bool, error) {
+			return v, nil
+		}, nil
+	default:
+		// Must be a named reference.
+		obj, v, err := cg.EmitNamedReference(ctx, scope, expr, args, chainStart)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ bool) (bool, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return b, errors.WithStack(ErrCodeGen{obj.Node, ErrBadCast})
+			}
+			return b, nil
+		}, nil
+	}
+}
+
+// EmitNamedReference emits the value of a call to a function, alias or
+// parameter that isn't a builtin, and returns the object it refers to.
+func (cg *CodeGen) EmitNamedReference(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []*parser.Expr, chainStart interface{}) (*parser.Object, interface{}, error) {
+	obj := scope.Lookup(expr.Name())
+	if obj == nil {
+		return nil, nil, errors.WithStack(ErrCodeGen{expr.IdentNode(), ErrUndefinedReference})
+	}
+
+	var (
+		v   interface{}
+		err error
+	)
+	switch n := obj.Node.(type) {
+	case *parser.FuncDecl:
+		v, err = cg.EmitFuncDecl(ctx, scope, n, args, noopAliasCallback, chainStart)
+	case *parser.AliasDecl:
+		v, err = cg.EmitAliasDecl(ctx, scope, n, args, chainStart)
+	case *parser.ImportDecl:
+		importScope := obj.Data.(*parser.Scope)
+		importObj := importScope.Lookup(expr.Selector.Select.Name)
+		if importObj == nil {
+			return obj, nil, errors.WithStack(ErrCodeGen{expr.Selector, ErrUndefinedReference})
+		}
+
+		switch m := importObj.Node.(type) {
+		case *parser.FuncDecl:
+			v, err = cg.EmitFuncDecl(ctx, scope, m, args, noopAliasCallback, chainStart)
+		case *parser.AliasDecl:
+			v, err = cg.EmitAliasDecl(ctx, scope, m, args, chainStart)
+		default:
+			return obj, nil, errors.WithStack(ErrCodeGen{n, errors.Errorf("unknown obj type")})
+		}
+	case *parser.Field:
+		v, err = cg.EmitFieldValue(ctx, scope, obj, args, noopAliasCallback, chainStart)
+	default:
+		return obj, nil, errors.WithStack(ErrCodeGen{n, errors.Errorf("unknown obj type")})
+	}
+	return obj, v, err
+}
+
 func (cg *CodeGen) EmitGroupChainStmt(ctx context.Context, scope *parser.Scope, expr *parser.Expr, args []*parser.Expr, with *parser.WithOpt, ac aliasCallback, chainStart interface{}) (gc GroupChain, err error) {
 	switch expr.Name() {
 	case "parallel":
@@ -1003,7 +1330,7 @@ func (cg *CodeGen) EmitGroupChainStmt(ctx context.Context, scope *parser.Scope,
 				return gc, errors.WithStack(ErrCodeGen{m, errors.Errorf("unknown obj type")})
 			}
 		case *parser.Field:
-			v = obj.Data
+			v, err = cg.EmitFieldValue(ctx, scope, obj, args, ac, chainStart)
 		default:
 			return gc, errors.WithStack(ErrCodeGen{n, errors.Errorf("unknown obj type")})
 		}
@@ -55,6 +55,7 @@ type CodeGen struct {
 	mw        *progress.MultiWriter
 	dockerCli *command.DockerCli
 	solveOpts []solver.SolveOption
+	platforms []specs.Platform
 }
 
 type CodeGenOption func(*CodeGen) error
@@ -66,6 +67,15 @@ func WithDebugger(dbgr Debugger) CodeGenOption {
 	}
 }
 
+// WithTrace prints every step of codegen to w before yielding to the debugger
+// set by the options before it.
+func WithTrace(w io.Writer) CodeGenOption {
+	return func(i *CodeGen) error {
+		i.Debug = NewTraceDebugger(w, i.Debug)
+		return nil
+	}
+}
+
 func WithMultiWriter(mw *progress.MultiWriter) CodeGenOption {
 	return func(i *CodeGen) error {
 		i.mw = mw
@@ -118,12 +128,25 @@ func (cg *CodeGen) SolveOptions(ctx context.Context, st llb.State) (opts []solve
 		return opts, err
 	}
 
-	opts = append(opts, solver.WithImageSpec(&specs.Image{
-		Config: specs.ImageConfig{
-			Env:        env,
-			Entrypoint: args,
-			WorkingDir: dir,
+	config, err := getImageConfig(ctx, st)
+	if err != nil {
+		return opts, err
+	}
+
+	config.Env = env
+	config.WorkingDir = dir
+	if config.Entrypoint == nil {
+		config.Entrypoint = args
+	}
+
+	p := cg.platform()
+	opts = append(opts, solver.WithImageSpec(&solver.ImageSpec{
+		Image: specs.Image{
+			Architecture: p.Architecture,
+			OS:           p.OS,
+			Config:       config,
 		},
+		Variant: p.Variant,
 	}))
 
 	opts = append(opts, cg.solveOpts...)
@@ -133,9 +156,14 @@ func (cg *CodeGen) SolveOptions(ctx context.Context, st llb.State) (opts []solve
 func (cg *CodeGen) Generate(ctx context.Context, mod *parser.Module, targets []Target) (solver.Request, error) {
 	var requests []solver.Request
 
+	// The debugger solves the state of a step with the same session
+	// attachables as the final solve.
+	ctx = withSessionFunc(ctx, cg.newSession)
+
 	for _, target := range targets {
 		// Reset codegen state for next target.
 		cg.reset()
+		cg.platforms = target.Platforms
 
 		obj := mod.Scope.Lookup(target.Name)
 		if obj == nil {
@@ -233,6 +261,99 @@ func (cg *CodeGen) reset() {
 	cg.agentConfigByID = map[string]sockprovider.AgentConfig{}
 }
 
+// platform returns the first platform the current target is built for.
+func (cg *CodeGen) platform() specs.Platform {
+	if len(cg.platforms) == 0 {
+		return DefaultPlatform
+	}
+	return cg.platforms[0]
+}
+
+type imageConfigKey struct{}
+
+// getImageConfig returns a copy of the image config metadata set on a state
+// by builtins like `cmd` and `label`.
+func getImageConfig(ctx context.Context, st llb.State) (specs.ImageConfig, error) {
+	v, err := st.Value(ctx, imageConfigKey{})
+	if err != nil {
+		return specs.ImageConfig{}, err
+	}
+
+	config, _ := v.(specs.ImageConfig)
+
+	// States share metadata with the states they are derived from, so maps
+	// are copied before they are modified.
+	labels := make(map[string]string)
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	config.Labels = labels
+
+	exposedPorts := make(map[string]struct{})
+	for port := range config.ExposedPorts {
+		exposedPorts[port] = struct{}{}
+	}
+	config.ExposedPorts = exposedPorts
+
+	volumes := make(map[string]struct{})
+	for path := range config.Volumes {
+		volumes[path] = struct{}{}
+	}
+	config.Volumes = volumes
+
+	return config, nil
+}
+
+// withImageConfig returns a state with its image config metadata modified by
+// fn.
+func withImageConfig(ctx context.Context, st llb.State, fn func(config *specs.ImageConfig)) (llb.State, error) {
+	config, err := getImageConfig(ctx, st)
+	if err != nil {
+		return st, err
+	}
+
+	fn(&config)
+	return st.WithValue(imageConfigKey{}, config), nil
+}
+
+type optionSubtypeKey struct{}
+
+// withOptionSubtype returns a context with the subtype of the option block being
+// emitted, so that plain option functions called in it emit options of the same
+// subtype.
+func withOptionSubtype(ctx context.Context, op string) context.Context {
+	return context.WithValue(ctx, optionSubtypeKey{}, op)
+}
+
+// optionSubtype returns the subtype of an option type, or the subtype of the
+// option block being emitted if the type is a plain option.
+func optionSubtype(ctx context.Context, typ *parser.Type) string {
+	if typ.Secondary() != parser.None {
+		return string(typ.Secondary())
+	}
+	op, _ := ctx.Value(optionSubtypeKey{}).(string)
+	return op
+}
+
+type sessionFuncKey struct{}
+
+// withSessionFunc returns a context with a function creating sessions with the
+// attachables of the sources emitted so far, so that the debugger can solve
+// the state of a step.
+func withSessionFunc(ctx context.Context, fn func(ctx context.Context) (*session.Session, error)) context.Context {
+	return context.WithValue(ctx, sessionFuncKey{}, fn)
+}
+
+// newDebugSession returns a session created by the function of the context,
+// or a session without attachables if there is none.
+func newDebugSession(ctx context.Context) (*session.Session, error) {
+	fn, ok := ctx.Value(sessionFuncKey{}).(func(ctx context.Context) (*session.Session, error))
+	if !ok {
+		return session.NewSession(ctx, "hlb", "")
+	}
+	return fn(ctx)
+}
+
 func (cg *CodeGen) newSession(ctx context.Context) (*session.Session, error) {
 	// By default, forward docker authentication through the session.
 	attachables := []session.Attachable{authprovider.NewDockerAuthProvider(os.Stderr)}
@@ -307,6 +428,18 @@ func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, typ parse
 		if _, ok := v.(string); v == nil || !ok {
 			v = ""
 		}
+	case parser.ListOf(parser.Str):
+		if _, ok := v.([]string); v == nil || !ok {
+			v = []string{}
+		}
+	case parser.Int:
+		if _, ok := v.(int); v == nil || !ok {
+			v = 0
+		}
+	case parser.Bool:
+		if _, ok := v.(bool); v == nil || !ok {
+			v = false
+		}
 	case parser.Group:
 		if _, ok := v.([]solver.Request); v == nil || !ok {
 			v = []solver.Request{}
@@ -315,6 +448,29 @@ func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, typ parse
 
 	var err error
 	for _, stmt := range stmts {
+		if stmt.If != nil {
+			// Only the statements of the branch taken are emitted, continuing the
+			// chain of the enclosing block.
+			branch, err := cg.EmitIfStmt(ctx, scope, stmt.If)
+			if err != nil {
+				return v, err
+			}
+
+			v, err = cg.EmitBlock(ctx, scope, typ, branch, ac, v)
+			if err != nil {
+				return v, err
+			}
+			continue
+		}
+
+		if stmt.For != nil {
+			v, err = cg.EmitForStmt(ctx, scope, typ, stmt.For, ac, v)
+			if err != nil {
+				return v, err
+			}
+			continue
+		}
+
 		call := stmt.Call
 		if isBreakpoint(call) {
 			err = cg.Debug(ctx, scope, call, v)
@@ -346,7 +502,7 @@ func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, typ parse
 			}
 		}
 		if cerr != nil {
-			return v, err
+			return v, cerr
 		}
 
 		if call.Alias != nil {
@@ -361,6 +517,132 @@ func (cg *CodeGen) EmitBlock(ctx context.Context, scope *parser.Scope, typ parse
 	return v, nil
 }
 
+// EmitIfStmt evaluates the condition of an if statement and returns the
+// statements of the branch taken.
+func (cg *CodeGen) EmitIfStmt(ctx context.Context, scope *parser.Scope, ifStmt *parser.IfStmt) ([]*parser.Stmt, error) {
+	var (
+		cond bool
+		err  error
+	)
+	switch {
+	case ifStmt.Cond.Ident != nil, ifStmt.Cond.Selector != nil:
+		var bc BoolChain
+		bc, err = cg.EmitBoolChainStmt(ctx, scope, ifStmt.Cond, ifStmt.Args, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		cond, err = bc(false)
+	default:
+		cond, err = cg.EmitBoolExpr(ctx, scope, ifStmt.Cond)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cond:
+		return ifStmt.Body.NonEmptyStmts(), nil
+	case ifStmt.Else == nil:
+		return nil, nil
+	case ifStmt.Else.If != nil:
+		return cg.EmitIfStmt(ctx, scope, ifStmt.Else.If)
+	default:
+		return ifStmt.Else.Body.NonEmptyStmts(), nil
+	}
+}
+
+// EmitForStmt unrolls a for loop, emitting its statements once for every
+// element of the list. In group blocks, the statements emitted for each element
+// are solved in parallel.
+func (cg *CodeGen) EmitForStmt(ctx context.Context, scope *parser.Scope, typ parser.ObjType, forStmt *parser.ForStmt, ac aliasCallback, chainStart interface{}) (interface{}, error) {
+	var (
+		list []string
+		err  error
+	)
+	switch {
+	case forStmt.List.Ident != nil, forStmt.List.Selector != nil:
+		var lc StringListChain
+		lc, err = cg.EmitStringListChainStmt(ctx, scope, forStmt.List, forStmt.Args, nil)
+		if err != nil {
+			return chainStart, err
+		}
+
+		list, err = lc([]string{})
+	default:
+		list, err = cg.EmitStringListExpr(ctx, scope, forStmt.List)
+	}
+	if err != nil {
+		return chainStart, err
+	}
+
+	var (
+		v        = chainStart
+		requests []solver.Request
+	)
+	for _, elem := range list {
+		iterScope := parser.NewScope(forStmt, scope)
+		iterScope.Insert(&parser.Object{
+			Kind:  parser.ExprKind,
+			Ident: forStmt.Var,
+			Node: &parser.Field{
+				Pos:  forStmt.Var.Pos,
+				Type: parser.NewType(parser.Str),
+				Name: forStmt.Var,
+			},
+			Data: elem,
+		})
+
+		if typ != parser.Group {
+			v, err = cg.EmitBlock(ctx, iterScope, typ, forStmt.Body.NonEmptyStmts(), ac, v)
+			if err != nil {
+				return v, err
+			}
+			continue
+		}
+
+		request, err := cg.EmitGroupBlock(ctx, iterScope, forStmt.Body, ac, nil)
+		if err != nil {
+			return v, err
+		}
+		requests = append(requests, request)
+	}
+
+	if len(requests) > 0 {
+		parent, ok := v.([]solver.Request)
+		if !ok {
+			return v, errors.WithStack(ErrCodeGen{forStmt, ErrBadCast})
+		}
+		v = append(parent, solver.Parallel(requests...))
+	}
+	return v, nil
+}
+
+// expandIfStmts replaces every if statement with the statements of the branch
+// taken. This is used by blocks that are not chained, like option blocks.
+func (cg *CodeGen) expandIfStmts(ctx context.Context, scope *parser.Scope, stmts []*parser.Stmt) ([]*parser.Stmt, error) {
+	var expanded []*parser.Stmt
+	for _, stmt := range stmts {
+		if stmt.If == nil {
+			expanded = append(expanded, stmt)
+			continue
+		}
+
+		branch, err := cg.EmitIfStmt(ctx, scope, stmt.If)
+		if err != nil {
+			return nil, err
+		}
+
+		branch, err = cg.expandIfStmts(ctx, scope, branch)
+		if err != nil {
+			return nil, err
+		}
+
+		expanded = append(expanded, branch...)
+	}
+	return expanded, nil
+}
+
 func (cg *CodeGen) EmitFilesystemBlock(ctx context.Context, scope *parser.Scope, body *parser.BlockStmt, ac aliasCallback, chainStart interface{}) (st llb.State, err error) {
 	v, err := cg.EmitBlock(ctx, scope, parser.Filesystem, body.NonEmptyStmts(), ac, chainStart)
 	if err != nil {
@@ -387,6 +669,45 @@ func (cg *CodeGen) EmitStringBlock(ctx context.Context, scope *parser.Scope, bod
 	return
 }
 
+func (cg *CodeGen) EmitStringListBlock(ctx context.Context, scope *parser.Scope, body *parser.BlockStmt, chainStart interface{}) (list []string, err error) {
+	v, err := cg.EmitBlock(ctx, scope, parser.ListOf(parser.Str), body.NonEmptyStmts(), noopAliasCallback, chainStart)
+	if err != nil {
+		return
+	}
+
+	list, ok := v.([]string)
+	if !ok {
+		return list, errors.WithStack(ErrCodeGen{body, ErrBadCast})
+	}
+	return
+}
+
+func (cg *CodeGen) EmitIntBlock(ctx context.Context, scope *parser.Scope, body *parser.BlockStmt, chainStart interface{}) (i int, err error) {
+	v, err := cg.EmitBlock(ctx, scope, parser.Int, body.NonEmptyStmts(), noopAliasCallback, chainStart)
+	if err != nil {
+		return
+	}
+
+	i, ok := v.(int)
+	if !ok {
+		return i, errors.WithStack(ErrCodeGen{body, ErrBadCast})
+	}
+	return
+}
+
+func (cg *CodeGen) EmitBoolBlock(ctx context.Context, scope *parser.Scope, body *parser.BlockStmt, chainStart interface{}) (b bool, err error) {
+	v, err := cg.EmitBlock(ctx, scope, parser.Bool, body.NonEmptyStmts(), noopAliasCallback, chainStart)
+	if err != nil {
+		return
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return b, errors.WithStack(ErrCodeGen{body, ErrBadCast})
+	}
+	return
+}
+
 func (cg *CodeGen) EmitGroupBlock(ctx context.Context, scope *parser.Scope, body *parser.BlockStmt, ac aliasCallback, chainStart interface{}) (solver.Request, error) {
 	v, err := cg.EmitBlock(ctx, scope, parser.Group, body.NonEmptyStmts(), ac, chainStart)
 	if err != nil {
@@ -405,12 +726,16 @@ func (cg *CodeGen) EmitGroupBlock(ctx context.Context, scope *parser.Scope, body
 
 func (cg *CodeGen) EmitFuncLit(ctx context.Context, scope *parser.Scope, lit *parser.FuncLit, op string, ac aliasCallback) (interface{}, error) {
 	switch lit.Type.Primary() {
-	case parser.Int, parser.Bool:
-		panic("unimplemented")
 	case parser.Filesystem:
 		return cg.EmitFilesystemBlock(ctx, scope, lit.Body, ac, nil)
 	case parser.Str:
 		return cg.EmitStringBlock(ctx, scope, lit.Body, nil)
+	case parser.ListOf(parser.Str):
+		return cg.EmitStringListBlock(ctx, scope, lit.Body, nil)
+	case parser.Int:
+		return cg.EmitIntBlock(ctx, scope, lit.Body, nil)
+	case parser.Bool:
+		return cg.EmitBoolBlock(ctx, scope, lit.Body, nil)
 	case parser.Option:
 		return cg.EmitOptionBlock(ctx, scope, op, lit.Body, ac)
 	case parser.Group:
@@ -421,7 +746,12 @@ func (cg *CodeGen) EmitFuncLit(ctx context.Context, scope *parser.Scope, lit *pa
 }
 
 func (cg *CodeGen) EmitOptionBlock(ctx context.Context, scope *parser.Scope, op string, body *parser.BlockStmt, ac aliasCallback) (opts []interface{}, err error) {
-	stmts := body.NonEmptyStmts()
+	ctx = withOptionSubtype(ctx, op)
+	stmts, err := cg.expandIfStmts(ctx, scope, body.NonEmptyStmts())
+	if err != nil {
+		return opts, err
+	}
+
 	switch op {
 	case "image":
 		return cg.EmitImageOptions(ctx, scope, op, stmts)
@@ -471,6 +801,12 @@ func (cg *CodeGen) EmitImageOptions(ctx context.Context, scope *parser.Scope, op
 				if v {
 					opts = append(opts, imagemetaresolver.WithDefault)
 				}
+			case "platform":
+				p, err := cg.EmitPlatformExpr(ctx, scope, args[0])
+				if err != nil {
+					return opts, err
+				}
+				opts = append(opts, llb.Platform(p))
 			default:
 				iopts, err := cg.EmitOptionLookup(ctx, scope, stmt.Call.Func, args, op)
 				if err != nil {
@@ -489,10 +825,18 @@ func (cg *CodeGen) EmitOptionLookup(ctx context.Context, scope *parser.Scope, ex
 		return opts, errors.WithStack(ErrCodeGen{expr.IdentNode(), ErrUndefinedReference})
 	}
 
+	// Option functions are flattened into the options of the calling option
+	// block, so they must have the same subtype.
+	optionType := parser.ObjType(fmt.Sprintf("%s::%s", parser.Option, op))
+
 	switch obj.Kind {
 	case parser.DeclKind:
 		switch n := obj.Node.(type) {
 		case *parser.FuncDecl:
+			err = checker.CheckOptionSubtype(expr.IdentNode(), optionType, n.Type)
+			if err != nil {
+				return opts, err
+			}
 			return cg.EmitOptionFuncDecl(ctx, scope, n, args)
 		case *parser.ImportDecl:
 			importScope := obj.Data.(*parser.Scope)
@@ -503,6 +847,10 @@ func (cg *CodeGen) EmitOptionLookup(ctx context.Context, scope *parser.Scope, ex
 
 			switch m := importObj.Node.(type) {
 			case *parser.FuncDecl:
+				err = checker.CheckOptionSubtype(expr.Selector.Select, optionType, m.Type)
+				if err != nil {
+					return opts, err
+				}
 				return cg.EmitOptionFuncDecl(ctx, scope, m, args)
 			default:
 				return opts, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown option decl kind")})
@@ -514,7 +862,16 @@ func (cg *CodeGen) EmitOptionLookup(ctx context.Context, scope *parser.Scope, ex
 		// we will get here with a variadic argument that is used with zero values
 		return nil, nil
 	case parser.ExprKind:
-		return obj.Data.([]interface{}), nil
+		v, err := cg.EmitFieldValue(ctx, scope, obj, args, noopAliasCallback, nil)
+		if err != nil {
+			return opts, err
+		}
+
+		opts, ok := v.([]interface{})
+		if !ok {
+			return opts, errors.WithStack(ErrCodeGen{expr, ErrBadCast})
+		}
+		return opts, nil
 	default:
 		return opts, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown obj type")})
 	}
@@ -586,30 +943,21 @@ func (cg *CodeGen) EmitLocalOptions(ctx context.Context, scope *parser.Scope, op
 			args := stmt.Call.Args
 			switch stmt.Call.Func.Name() {
 			case "includePatterns":
-				patterns := make([]string, len(args))
-				for i, arg := range args {
-					patterns[i], err = cg.EmitStringExpr(ctx, scope, arg)
-					if err != nil {
-						return opts, err
-					}
+				patterns, err := cg.EmitStringExprs(ctx, scope, args)
+				if err != nil {
+					return opts, err
 				}
 				opts = append(opts, llb.IncludePatterns(patterns))
 			case "excludePatterns":
-				patterns := make([]string, len(args))
-				for i, arg := range args {
-					patterns[i], err = cg.EmitStringExpr(ctx, scope, arg)
-					if err != nil {
-						return opts, err
-					}
+				patterns, err := cg.EmitStringExprs(ctx, scope, args)
+				if err != nil {
+					return opts, err
 				}
 				opts = append(opts, llb.ExcludePatterns(patterns))
 			case "followPaths":
-				paths := make([]string, len(args))
-				for i, arg := range args {
-					paths[i], err = cg.EmitStringExpr(ctx, scope, arg)
-					if err != nil {
-						return opts, err
-					}
+				paths, err := cg.EmitStringExprs(ctx, scope, args)
+				if err != nil {
+					return opts, err
 				}
 				opts = append(opts, llb.FollowPaths(paths))
 			default:
@@ -652,7 +1000,7 @@ func (cg *CodeGen) EmitFrontendOptions(ctx context.Context, scope *parser.Scope,
 				if err != nil {
 					return opts, err
 				}
-				def, err := st.Marshal(ctx, llb.LinuxAmd64)
+				def, err := st.Marshal(ctx, llb.Platform(cg.platform()))
 				if err != nil {
 					return opts, err
 				}
@@ -1015,6 +1363,12 @@ func (cg *CodeGen) EmitExecOptions(ctx context.Context, scope *parser.Scope, op
 				if v {
 					opts = append(opts, llb.ReadonlyRootFS())
 				}
+			case "platform":
+				p, err := cg.EmitPlatformExpr(ctx, scope, args[0])
+				if err != nil {
+					return opts, err
+				}
+				opts = append(opts, llb.Platform(p))
 			case "env":
 				key, err := cg.EmitStringExpr(ctx, scope, args[0])
 				if err != nil {
@@ -1430,12 +1784,12 @@ func (cg *CodeGen) EmitSSHOptions(ctx context.Context, scope *parser.Scope, op s
 				}
 				sopt.mode = os.FileMode(mode)
 			case "localPaths":
-				for _, arg := range args {
-					localPath, err := cg.EmitStringExpr(ctx, scope, arg)
-					if err != nil {
-						return opts, err
-					}
+				localPaths, err := cg.EmitStringExprs(ctx, scope, args)
+				if err != nil {
+					return opts, err
+				}
 
+				for _, localPath := range localPaths {
 					localPath, err = ResolvePathForNode(scope.Node, localPath)
 					if err != nil {
 						return opts, err
@@ -1519,21 +1873,15 @@ func (cg *CodeGen) EmitSecretOptions(ctx context.Context, scope *parser.Scope, o
 				}
 				sopt.mode = os.FileMode(mode)
 			case "includePatterns":
-				patterns := make([]string, len(args))
-				for i, arg := range args {
-					patterns[i], err = cg.EmitStringExpr(ctx, scope, arg)
-					if err != nil {
-						return opts, err
-					}
+				patterns, err := cg.EmitStringExprs(ctx, scope, args)
+				if err != nil {
+					return opts, err
 				}
 				opts = append(opts, &secretIncludePatterns{patterns})
 			case "excludePatterns":
-				patterns := make([]string, len(args))
-				for i, arg := range args {
-					patterns[i], err = cg.EmitStringExpr(ctx, scope, arg)
-					if err != nil {
-						return opts, err
-					}
+				patterns, err := cg.EmitStringExprs(ctx, scope, args)
+				if err != nil {
+					return opts, err
 				}
 				opts = append(opts, &secretExcludePatterns{patterns})
 			default:
@@ -12,9 +12,11 @@ import (
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/solver/pb"
 	"github.com/moby/buildkit/util/entitlements"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/openllb/hlb/checker"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/solver"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"github.com/xlab/treeprint"
 )
@@ -197,6 +199,25 @@ func TestCodeGen(t *testing.T) {
 		func(t *testing.T, cg *CodeGen) solver.Request {
 			return Expect(t, llb.Scratch().User("testUser").Run(llb.Shlex("echo Hello")).Root())
 		},
+	}, {
+		"image config",
+		[]string{"default"},
+		`
+		fs default() {
+			scratch
+			entrypoint "/bin/app"
+			cmd "--help"
+			label "maintainer" "hlb"
+			expose "8080"
+			volume "/data"
+			stopSignal "SIGINT"
+			run "echo Hello" with shlex
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			// Image config metadata doesn't change the LLB of the state.
+			return Expect(t, llb.Scratch().Run(llb.Shlex("echo Hello")).Root())
+		},
 	}, {
 		"basic mkfile",
 		[]string{"default"},
@@ -396,6 +417,26 @@ func TestCodeGen(t *testing.T) {
 				llb.ReadonlyRootFS(),
 			).Root())
 		},
+	}, {
+		"platform options",
+		[]string{"default"},
+		`
+		fs default() {
+			image "alpine" with option {
+				platform "linux/arm64"
+			}
+			run "uname -m" with option {
+				platform "linux/arm64"
+			}
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			arm64 := llb.Platform(specs.Platform{OS: "linux", Architecture: "arm64"})
+			return Expect(t, llb.Image("alpine", arm64).Run(
+				llb.Args([]string{"/bin/sh", "-c", "uname -m"}),
+				arm64,
+			).Root())
+		},
 	}, {
 		"empty group",
 		[]string{"default"},
@@ -662,6 +703,279 @@ func TestCodeGen(t *testing.T) {
 				}),
 			))
 		},
+	}, {
+		"composing user defined option::run funcs",
+		[]string{"default"},
+		`
+		fs default() {
+			image "alpine"
+			run "go build" with option {
+				generic goEnv
+				all goos cgo
+				if false {
+					dir "/tmp"
+				}
+			}
+		}
+
+		option::run all(variadic option::run opts) {
+			opts
+		}
+
+		option generic(option opt) {
+			opt
+		}
+
+		option::run goEnv() {
+			env "GOPATH" "/go"
+		}
+
+		option::run goos() {
+			env "GOOS" "linux"
+		}
+
+		option::run cgo() {
+			env "CGO_ENABLED" "0"
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("alpine").Run(
+				llb.Args([]string{"/bin/sh", "-c", "go build"}),
+				llb.AddEnv("GOPATH", "/go"),
+				llb.AddEnv("GOOS", "linux"),
+				llb.AddEnv("CGO_ENABLED", "0"),
+			).Root())
+		},
+	}, {
+		"if else",
+		[]string{"default"},
+		`
+		fs default() {
+			if equal "linux" "darwin" {
+				image "alpine"
+			} else if false {
+				scratch
+			} else {
+				image "busybox"
+			}
+			run "echo hi" with option {
+				if true {
+					dir "/tmp"
+				}
+			}
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("busybox").Run(
+				llb.Args([]string{"/bin/sh", "-c", "echo hi"}),
+				llb.Dir("/tmp"),
+			).Root())
+		},
+	}, {
+		"bool params",
+		[]string{"default"},
+		`
+		fs default() {
+			build true false
+		}
+		fs build(bool readonly, bool tmp) {
+			image "busybox"
+			run "echo hi" with option {
+				readonlyRootfs readonly
+				if or tmp notDarwin {
+					dir "/tmp"
+				}
+			}
+		}
+		bool notDarwin() {
+			not bool { equal "linux" "darwin"; }
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("busybox").Run(
+				llb.Args([]string{"/bin/sh", "-c", "echo hi"}),
+				llb.ReadonlyRootFS(),
+				llb.Dir("/tmp"),
+			).Root())
+		},
+	}, {
+		"int funcs",
+		[]string{"default"},
+		`
+		fs default() {
+			scratch
+			mkdir "testDir" dirMode
+			mkfile "testFile" int { add 0o600 0o044; } "Hello"
+		}
+		int dirMode() {
+			parseInt "0o755"
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Scratch().File(
+				llb.Mkdir("testDir", os.FileMode(0755)),
+			).File(
+				llb.Mkfile("testFile", os.FileMode(0644), []byte("Hello")),
+			))
+		},
+	}, {
+		"string lists",
+		[]string{"default"},
+		`
+		fs default() {
+			image "busybox"
+			run cmd "three"
+			mkfile "/args" 0o644 string { join cmd ","; }
+		}
+		[]string cmd() {
+			split "echo one" " "
+			append ["two"]
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("busybox").Run(
+				llb.Args([]string{"echo", "one", "two", "three"}),
+			).Root().File(
+				llb.Mkfile("/args", os.FileMode(0644), []byte("echo,one,two")),
+			))
+		},
+	}, {
+		"variadic string param with a list",
+		[]string{"default"},
+		`
+		fs default() {
+			build ["echo", "one"]
+		}
+		fs build(variadic string args) {
+			image "busybox"
+			run args
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("busybox").Run(
+				llb.Args([]string{"echo", "one"}),
+			).Root())
+		},
+	}, {
+		"variadic string param with many args",
+		[]string{"default"},
+		`
+		fs default() {
+			build "echo" "two" cmd
+		}
+		fs build(variadic string args) {
+			image "busybox"
+			run args
+			mkfile "/args" 0o644 string { join args ","; }
+		}
+		[]string cmd() {
+			split "three four" " "
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("busybox").Run(
+				llb.Args([]string{"echo", "two", "three", "four"}),
+			).Root().File(
+				llb.Mkfile("/args", os.FileMode(0644), []byte("echo,two,three,four")),
+			))
+		},
+	}, {
+		"for loop",
+		[]string{"default"},
+		`
+		fs default() {
+			scratch
+			for name in ["foo", "bar"] {
+				mkfile name 0o644 name
+			}
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Scratch().File(
+				llb.Mkfile("foo", os.FileMode(0644), []byte("foo")),
+			).File(
+				llb.Mkfile("bar", os.FileMode(0644), []byte("bar")),
+			))
+		},
+	}, {
+		"for loop in group",
+		[]string{"default"},
+		`
+		group default() {
+			for ref in split "alpine,busybox" "," {
+				parallel fs { image ref; }
+			}
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return solver.Parallel(
+				Expect(t, llb.Image("alpine")),
+				Expect(t, llb.Image("busybox")),
+			)
+		},
+	}, {
+		"function values",
+		[]string{"default"},
+		`
+		fs default() {
+			build fs { image "alpine"; } addCerts
+		}
+		fs build(fs() base, fs(fs) transform) {
+			transform base
+		}
+		fs addCerts(fs base) {
+			base
+			mkdir "/etc/ssl" 0o755
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("alpine").File(
+				llb.Mkdir("/etc/ssl", os.FileMode(0755)),
+			))
+		},
+	}, {
+		"option function values",
+		[]string{"default"},
+		`
+		fs default() {
+			build withGoos
+		}
+		fs build(option::run(string) target) {
+			image "golang"
+			run "go build" with option {
+				target "linux"
+			}
+		}
+		option::run withGoos(string goos) {
+			env "GOOS" goos
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Image("golang").Run(
+				llb.Args([]string{"/bin/sh", "-c", "go build"}),
+				llb.AddEnv("GOOS", "linux"),
+			).Root())
+		},
+	}, {
+		"default values and named args",
+		[]string{"default"},
+		`
+		fs default() {
+			scratch
+			touch "foo"
+			touch "bar" mode=0o600
+		}
+		fs touch(string name, int mode = 0o644, string content = "") {
+			mkfile name mode content
+		}
+		`,
+		func(t *testing.T, cg *CodeGen) solver.Request {
+			return Expect(t, llb.Scratch().File(
+				llb.Mkfile("foo", os.FileMode(0644), []byte("")),
+			).File(
+				llb.Mkfile("bar", os.FileMode(0600), []byte("")),
+			))
+		},
 	}, {
 		"localRun",
 		[]string{"default"},
@@ -739,3 +1053,217 @@ func TestCodeGen(t *testing.T) {
 		})
 	}
 }
+
+func TestCodeGen_SolveOptions(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name      string
+		platforms []specs.Platform
+		input     string
+		expected  *solver.ImageSpec
+	}
+
+	for _, tc := range []testCase{{
+		"default platform",
+		nil,
+		"",
+		&solver.ImageSpec{
+			Image: specs.Image{
+				Architecture: DefaultPlatform.Architecture,
+				OS:           DefaultPlatform.OS,
+				Config: specs.ImageConfig{
+					ExposedPorts: map[string]struct{}{},
+					Env:          []string{},
+					Volumes:      map[string]struct{}{},
+					WorkingDir:   "/",
+					Labels:       map[string]string{},
+				},
+			},
+		},
+	}, {
+		"platform with variant",
+		[]specs.Platform{{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		"",
+		&solver.ImageSpec{
+			Image: specs.Image{
+				Architecture: "arm",
+				OS:           "linux",
+				Config: specs.ImageConfig{
+					ExposedPorts: map[string]struct{}{},
+					Env:          []string{},
+					Volumes:      map[string]struct{}{},
+					WorkingDir:   "/",
+					Labels:       map[string]string{},
+				},
+			},
+			Variant: "v7",
+		},
+	}, {
+		"image config",
+		nil,
+		`
+		fs default() {
+			scratch
+			entrypoint "/bin/app" "serve"
+			cmd "--help"
+			label "maintainer" "hlb"
+			expose "8080"
+			expose "53/udp"
+			volume "/data"
+			stopSignal "SIGINT"
+			user "app"
+			env "FOO" "bar"
+			dir "/src"
+			run "echo Hello" with shlex
+		}
+		`,
+		&solver.ImageSpec{
+			Image: specs.Image{
+				Architecture: DefaultPlatform.Architecture,
+				OS:           DefaultPlatform.OS,
+				Config: specs.ImageConfig{
+					User:         "app",
+					ExposedPorts: map[string]struct{}{"8080/tcp": {}, "53/udp": {}},
+					Env:          []string{"FOO=bar"},
+					Entrypoint:   []string{"/bin/app", "serve"},
+					Cmd:          []string{"--help"},
+					Volumes:      map[string]struct{}{"/data": {}},
+					WorkingDir:   "/src",
+					Labels:       map[string]string{"maintainer": "hlb"},
+					StopSignal:   "SIGINT",
+				},
+			},
+		},
+	}, {
+		"default entrypoint",
+		nil,
+		`
+		fs default() {
+			scratch
+			run "echo Hello" with shlex
+		}
+		`,
+		// The command of run is not used as the entrypoint by default.
+		&solver.ImageSpec{
+			Image: specs.Image{
+				Architecture: DefaultPlatform.Architecture,
+				OS:           DefaultPlatform.OS,
+				Config: specs.ImageConfig{
+					ExposedPorts: map[string]struct{}{},
+					Env:          []string{},
+					Volumes:      map[string]struct{}{},
+					WorkingDir:   "/",
+					Labels:       map[string]string{},
+				},
+			},
+		},
+	}, {
+		"empty entrypoint",
+		nil,
+		`
+		fs default() {
+			scratch
+			entrypoint
+			cmd "/bin/sh"
+		}
+		`,
+		&solver.ImageSpec{
+			Image: specs.Image{
+				Architecture: DefaultPlatform.Architecture,
+				OS:           DefaultPlatform.OS,
+				Config: specs.ImageConfig{
+					ExposedPorts: map[string]struct{}{},
+					Env:          []string{},
+					Entrypoint:   []string{},
+					Cmd:          []string{"/bin/sh"},
+					Volumes:      map[string]struct{}{},
+					WorkingDir:   "/",
+					Labels:       map[string]string{},
+				},
+			},
+		},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			cg, err := New()
+			require.NoError(t, err)
+			cg.platforms = tc.platforms
+
+			st := llb.Scratch()
+			if tc.input != "" {
+				mod, err := parser.Parse(strings.NewReader(cleanup(tc.input)))
+				require.NoError(t, err)
+
+				err = checker.Check(mod)
+				require.NoError(t, err)
+
+				fun := mod.Scope.Lookup("default").Node.(*parser.FuncDecl)
+				v, err := cg.EmitFuncDecl(ctx, mod.Scope, fun, nil, noopAliasCallback, nil)
+				require.NoError(t, err)
+				st = v.(llb.State)
+			}
+
+			opts, err := cg.SolveOptions(ctx, st)
+			require.NoError(t, err)
+
+			var info solver.SolveInfo
+			for _, opt := range opts {
+				err = opt(&info)
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.expected, info.ImageSpec)
+		})
+	}
+}
+
+func TestCodeGen_Errors(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		input    string
+		expected string
+	}
+
+	for _, tc := range []testCase{{
+		"invalid int in block",
+		`
+		fs default() {
+			mkdir "/x" int { parseInt "abc"; }
+		}
+		`,
+		`strconv.ParseInt: parsing "abc": invalid syntax`,
+	}, {
+		"invalid int in func",
+		`
+		fs default() {
+			mkdir "/x" mode
+		}
+
+		int mode() {
+			parseInt "0o7z"
+		}
+		`,
+		`strconv.ParseInt: parsing "0o7z": invalid syntax`,
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cg, err := New()
+			require.NoError(t, err)
+
+			mod, err := parser.Parse(strings.NewReader(cleanup(tc.input)))
+			require.NoError(t, err)
+
+			err = checker.Check(mod)
+			require.NoError(t, err)
+
+			_, err = cg.Generate(context.Background(), mod, []Target{{Name: "default"}})
+			require.Error(t, err)
+			require.EqualError(t, errors.Cause(err), tc.expected)
+		})
+	}
+}
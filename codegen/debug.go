@@ -6,8 +6,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -15,12 +19,16 @@ import (
 	"github.com/logrusorgru/aurora"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/solver/pb"
 	digest "github.com/opencontainers/go-digest"
+	"github.com/openllb/hlb/builtin"
 	"github.com/openllb/hlb/checker"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/report"
+	"github.com/openllb/hlb/solver"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -35,10 +43,187 @@ func NewNoopDebugger() Debugger {
 	}
 }
 
-type snapshot struct {
-	scope *parser.Scope
-	node  parser.Node
-	value interface{}
+// NewScriptDebugger returns a debugger that reads its commands from a script
+// instead of a terminal. Commands are echoed after the prompt as they are read
+// so the output reads like an interactive session.
+func NewScriptDebugger(c *client.Client, w io.Writer, script io.Reader, ibs map[string]*report.IndexedBuffer) Debugger {
+	return NewDebugger(c, w, bufio.NewReader(&echoReader{
+		r: bufio.NewReader(script),
+		w: w,
+	}), ibs)
+}
+
+// echoReader reads a line at a time and writes every line it reads to w.
+// Since the debugger reads a line whenever its buffer is empty, lines are
+// echoed when the debugger reads them.
+type echoReader struct {
+	r    *bufio.Reader
+	w    io.Writer
+	line []byte
+}
+
+func (er *echoReader) Read(p []byte) (int, error) {
+	if len(er.line) == 0 {
+		line, err := er.r.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+
+		if line[len(line)-1] != '\n' {
+			line = append(line, '\n')
+		}
+
+		_, err = er.w.Write(line)
+		if err != nil {
+			return 0, err
+		}
+		er.line = line
+	}
+
+	n := copy(p, er.line)
+	er.line = er.line[n:]
+	return n, nil
+}
+
+// NewTraceDebugger returns a debugger that prints every step of codegen to w:
+// the kind of node, its position, the function it is in and a summary of the
+// value so far. It then yields to dbgr.
+func NewTraceDebugger(w io.Writer, dbgr Debugger) Debugger {
+	var prev *Snapshot
+	return func(ctx context.Context, scope *parser.Scope, node parser.Node, value interface{}) error {
+		s := NewSnapshot(prev, scope, node, value)
+		prev = s
+
+		var kind string
+		switch node.(type) {
+		case *parser.Module:
+			kind = "module"
+		case *parser.FuncDecl:
+			kind = "func"
+		case *parser.CallStmt:
+			kind = "call"
+		default:
+			kind = fmt.Sprintf("%T", node)
+		}
+
+		fmt.Fprintf(w, "trace: %s %s = %s\n", kind, formatStep(s.Func(), node), summarizeValue(ctx, value))
+		return dbgr(ctx, scope, node, value)
+	}
+}
+
+// summarizeValue returns a single line description of a value emitted by
+// codegen.
+func summarizeValue(ctx context.Context, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case string, []string:
+		return fmt.Sprintf("%q", v)
+	case llb.State:
+		ops, err := DescribeState(ctx, v)
+		if err != nil {
+			return fmt.Sprintf("fs <%s>", err)
+		}
+
+		if len(ops) == 0 {
+			return "fs scratch"
+		}
+		return fmt.Sprintf("fs (%d ops) %s", len(ops), ops[len(ops)-1])
+	case []interface{}:
+		return fmt.Sprintf("option (%d)", len(v))
+	default:
+		return fmt.Sprintf("%T %v", v, v)
+	}
+}
+
+// Snapshot is the state of the program at a step of the debugger.
+type Snapshot struct {
+	Scope  *parser.Scope
+	Node   parser.Node
+	Value  interface{}
+	Frames []Frame
+}
+
+// NewSnapshot returns the snapshot of the step following prev, which is nil
+// for the first step of the program.
+func NewSnapshot(prev *Snapshot, scope *parser.Scope, node parser.Node, value interface{}) *Snapshot {
+	var frames []Frame
+	if prev != nil {
+		frames = prev.Frames
+	}
+	return &Snapshot{
+		Scope:  scope,
+		Node:   node,
+		Value:  value,
+		Frames: nextFrames(frames, scope, node),
+	}
+}
+
+// Func returns the function the snapshot is stopped in, or nil if the program
+// has not started yet.
+func (s *Snapshot) Func() *parser.FuncDecl {
+	if len(s.Frames) == 0 {
+		return nil
+	}
+	return s.Frames[len(s.Frames)-1].Func
+}
+
+// Frame is a function on the call stack of the program being debugged and the
+// node it is stopped at. The node of a caller is the call statement that
+// entered the function above it.
+type Frame struct {
+	Func *parser.FuncDecl
+	Node parser.Node
+}
+
+// Stepper decides whether a debugger stops at a step of the program, based on
+// how the program was last resumed. The zero value stops at every step.
+type Stepper struct {
+	cont      bool
+	stepDepth int
+}
+
+// Break stops at the next step, as when a breakpoint is hit.
+func (st *Stepper) Break() {
+	st.cont = false
+	st.stepDepth = 0
+}
+
+// Continue runs until the next breakpoint or the end of the program.
+func (st *Stepper) Continue() {
+	st.cont = true
+	st.stepDepth = 0
+}
+
+// StepIn stops at the next step of any function.
+func (st *Stepper) StepIn() {
+	st.Break()
+}
+
+// Next stops at the next step of the function s is stopped in.
+func (st *Stepper) Next(s *Snapshot) {
+	st.cont = false
+	st.stepDepth = len(s.Frames)
+}
+
+// StepOut stops at the next step of the caller of the function s is stopped
+// in. Stepping out of the outermost function runs to the end of the program.
+func (st *Stepper) StepOut(s *Snapshot) {
+	st.stepDepth = len(s.Frames) - 1
+	st.cont = st.stepDepth <= 0
+}
+
+// Stops returns true if the debugger stops at s.
+func (st *Stepper) Stops(s *Snapshot) bool {
+	// Skip over steps deeper in the call stack than the frame we're stepping
+	// over or out of.
+	if st.stepDepth > 0 {
+		if len(s.Frames) > st.stepDepth {
+			return false
+		}
+		st.stepDepth = 0
+	}
+	return !st.cont
 }
 
 func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]*report.IndexedBuffer) Debugger {
@@ -47,83 +232,82 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 	var (
 		mod               *parser.Module
 		fun               *parser.FuncDecl
-		next              *parser.FuncDecl
-		history           []*snapshot
+		stepper           Stepper
+		history           []*Snapshot
 		historyIndex      = -1
 		reverseStep       bool
-		cont              bool
 		staticBreakpoints []*Breakpoint
 		breakpoints       []*Breakpoint
+		watchpoints       []*Watchpoint
 	)
 
 	return func(ctx context.Context, scope *parser.Scope, node parser.Node, value interface{}) error {
 		// Store a snapshot of the current debug step so we can backtrack.
+		var prev *Snapshot
+		if len(history) > 0 {
+			prev = history[len(history)-1]
+		}
+
 		historyIndex++
-		history = append(history, &snapshot{scope, node, value})
+		history = append(history, NewSnapshot(prev, scope, node, value))
 
-		debug := func(s *snapshot) error {
+		debug := func(s *Snapshot) error {
 			showList := true
 
 			// Keep track of whether we're in global scope or a lexical scope.
-			switch n := s.scope.Node.(type) {
-			case *parser.Module:
+			if n, ok := s.Scope.Node.(*parser.Module); ok {
 				// Don't print source code on the first debug section.
 				showList = false
 				mod = n
 				if len(staticBreakpoints) == 0 {
-					staticBreakpoints = findStaticBreakpoints(mod)
+					staticBreakpoints = FindStaticBreakpoints(mod)
 					breakpoints = append(breakpoints, staticBreakpoints...)
 				}
-			case *parser.FuncDecl:
-				fun = n
 			}
 
-			switch n := s.node.(type) {
-			case *parser.FuncDecl:
-				for _, bp := range breakpoints {
-					if bp.Call != nil {
-						continue
-					}
-					if bp.Func == n {
-						cont = false
-					}
-				}
-			case *parser.CallStmt:
-				for _, bp := range breakpoints {
-					if bp.Call == nil {
-						continue
-					}
-					if bp.Call == n {
-						cont = false
-					}
-				}
-			}
+			fun = s.Func()
 
-			if showList && !cont {
-				err := printList(color, ibs, w, s.node)
+			for _, bp := range breakpoints {
+				hit, err := bp.Hit(ctx, c, s)
 				if err != nil {
-					return err
+					fmt.Fprintf(w, "err: breakpoint condition %q: %s\n", bp.Condition, err)
+				}
+				if hit || err != nil {
+					stepper.Break()
 				}
 			}
 
-			if next != nil {
-				// If nment is not in the same function scope, skip over it.
-				if next != fun {
-					return nil
+			for i, wp := range watchpoints {
+				old, changed := wp.Update(ctx, c, s)
+				if changed {
+					fmt.Fprintf(w, "Watchpoint %d %s changed from %s to %s\n", i, wp, old, wp.value)
+					stepper.Break()
 				}
-				next = nil
 			}
 
 			// Continue until we find a breakpoint or end of program.
-			if cont {
+			if !stepper.Stops(s) {
 				return nil
 			}
 
+			if showList {
+				err := printList(color, ibs, w, s.Node)
+				if err != nil {
+					return err
+				}
+			}
+
 			for {
 				fmt.Fprint(w, "(hlb) ")
 
+				// The end of input exits the debugger, so that scripts don't need
+				// to end with an exit command.
 				command, err := r.ReadString('\n')
-				if err != nil {
+				if err == io.EOF && command == "" {
+					fmt.Fprintln(w)
+					return ErrDebugExit
+				}
+				if err != nil && err != io.EOF {
 					return err
 				}
 
@@ -139,11 +323,46 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 				}
 
 				switch args[0] {
+				case "backtrace", "bt":
+					if len(s.Frames) == 0 {
+						fmt.Fprintf(w, "Program has not started yet\n")
+						continue
+					}
+
+					for i := len(s.Frames) - 1; i >= 0; i-- {
+						frame := s.Frames[i]
+						fmt.Fprintf(w, "%d %s\n", len(s.Frames)-1-i, formatStep(frame.Func, frame.Node))
+					}
 				case "break", "b":
-					var bp *Breakpoint
+					var (
+						bp  *Breakpoint
+						loc string
+					)
+
+					// Conditions are expressions that may contain spaces, so the
+					// location and condition are split on the raw command.
+					input := strings.TrimSpace(strings.TrimPrefix(command, args[0]))
+					cond := ""
+					switch {
+					case input == "if" || strings.HasPrefix(input, "if "):
+						cond = strings.TrimPrefix(input, "if")
+					case strings.Contains(input, " if "):
+						i := strings.Index(input, " if ")
+						loc, cond = input[:i], input[i+len(" if "):]
+					case strings.HasSuffix(input, " if"):
+						loc = strings.TrimSuffix(input, " if")
+					default:
+						loc = input
+					}
 
-					if len(args) == 1 {
-						switch n := s.node.(type) {
+					cond = strings.TrimSpace(cond)
+					if cond == "" && loc != input {
+						fmt.Fprintf(w, "break if requires a condition\n")
+						continue
+					}
+
+					if loc == "" {
+						switch n := s.Node.(type) {
 						case *parser.FuncDecl:
 							bp = &Breakpoint{
 								Func: n,
@@ -158,44 +377,76 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 								Func: fun,
 								Call: n,
 							}
+						default:
+							fmt.Fprintf(w, "Program has not started yet\n")
+							continue
 						}
 					} else {
-						fmt.Fprintf(w, "unimplemented")
-						continue
+						bp, err = FindBreakpoint(mod, s.Node, loc)
+						if err != nil {
+							fmt.Fprintf(w, "err: %s\n", err)
+							continue
+						}
 					}
+					bp.Condition = cond
 					breakpoints = append(breakpoints, bp)
+					fmt.Fprintf(w, "Breakpoint %d set for %s\n", len(breakpoints)-1, bp)
 				case "breakpoints":
 					for i, bp := range breakpoints {
-						pos := bp.Func.Pos
-						if bp.Call != nil {
-							pos = bp.Call.Pos
-						}
+						fmt.Fprintf(w, "Breakpoint %d for %s\n", i, bp)
+					}
+					for i, wp := range watchpoints {
+						fmt.Fprintf(w, "Watchpoint %d for %s\n", i, wp)
+					}
+				case "cat":
+					st, ok := s.Value.(llb.State)
+					if !ok {
+						fmt.Fprintf(w, "current step is not in a fs scope\n")
+						continue
+					}
+
+					if len(args) != 2 {
+						fmt.Fprintf(w, "cat requires a path\n")
+						continue
+					}
 
-						msg := fmt.Sprintf("Breakpoint %d for %s%s %s",
-							i,
-							bp.Func.Name,
-							bp.Func.Params,
-							checker.FormatPos(pos))
+					err = solveReference(ctx, c, st, func(ctx context.Context, ref gateway.Reference) error {
+						filename, err := statePath(ctx, st, args[1])
+						if err != nil {
+							return err
+						}
 
-						if bp.Call != nil {
-							bp.Call.StmtEnd = nil
-							msg = fmt.Sprintf("%s %s", msg, bp.Call)
+						dt, err := ref.ReadFile(ctx, gateway.ReadRequest{
+							Filename: filename,
+						})
+						if err != nil {
+							return err
 						}
 
-						fmt.Fprintf(w, "%s\n", msg)
+						_, err = w.Write(dt)
+						return err
+					})
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
 					}
 				case "clear":
-					if len(args) == 0 {
+					if len(args) == 1 {
 						breakpoints = append([]*Breakpoint{}, staticBreakpoints...)
 					} else {
-						fmt.Fprintf(w, "unimplemented\n")
-						continue
+						i, err := strconv.Atoi(args[1])
+						if err != nil || i < 0 || i >= len(breakpoints) {
+							fmt.Fprintf(w, "no breakpoint with index %s\n", args[1])
+							continue
+						}
+
+						breakpoints = append(breakpoints[:i], breakpoints[i+1:]...)
+						fmt.Fprintf(w, "Breakpoint %d cleared\n", i)
 					}
 				case "continue", "c":
-					cont = true
+					stepper.Continue()
 					return nil
 				case "dir":
-					st, ok := s.value.(llb.State)
+					st, ok := s.Value.(llb.State)
 					if !ok {
 						fmt.Fprintf(w, "current step is not in a fs scope\n")
 						continue
@@ -209,7 +460,7 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 
 					fmt.Fprintf(w, "Working directory %q\n", dir)
 				case "dot":
-					st, ok := s.value.(llb.State)
+					st, ok := s.Value.(llb.State)
 					if !ok {
 						fmt.Fprintf(w, "current step is not in a fs scope\n")
 						continue
@@ -225,8 +476,29 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 						fmt.Fprintf(w, "err: %s\n", err)
 					}
 					continue
+				case "download":
+					st, ok := s.Value.(llb.State)
+					if !ok {
+						fmt.Fprintf(w, "current step is not in a fs scope\n")
+						continue
+					}
+
+					if len(args) != 2 {
+						fmt.Fprintf(w, "download requires a local directory\n")
+						continue
+					}
+
+					err = solveReference(ctx, c, st, func(ctx context.Context, ref gateway.Reference) error {
+						return downloadReference(ctx, ref, "/", args[1])
+					})
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+						continue
+					}
+
+					fmt.Fprintf(w, "Downloaded to %q\n", args[1])
 				case "env":
-					st, ok := s.value.(llb.State)
+					st, ok := s.Value.(llb.State)
 					if !ok {
 						fmt.Fprintf(w, "current step is not in a fs scope\n")
 						continue
@@ -242,7 +514,7 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 				case "exit":
 					return ErrDebugExit
 				case "funcs":
-					for _, obj := range s.scope.Defined(parser.DeclKind) {
+					for _, obj := range s.Scope.Defined(parser.DeclKind) {
 						switch n := obj.Node.(type) {
 						case *parser.FuncDecl:
 							fmt.Fprintf(w, "%s\n", n.Name)
@@ -254,16 +526,19 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 					fmt.Fprintf(w, "# Inspect\n")
 					fmt.Fprintf(w, "help - shows this help message\n")
 					fmt.Fprintf(w, "list - show source code\n")
-					fmt.Fprintf(w, "print - print evaluate an expression\n")
+					fmt.Fprintf(w, "backtrace - print the call stack\n")
+					fmt.Fprintf(w, "print <expr> - evaluate and print an expression\n")
 					fmt.Fprintf(w, "funcs - print list of functions\n")
 					fmt.Fprintf(w, "locals - print local variables\n")
 					fmt.Fprintf(w, "types - print list of types\n")
-					fmt.Fprintf(w, "whatis - print type of an expression\n")
+					fmt.Fprintf(w, "whatis <expr> - print type of an expression\n")
 					fmt.Fprintf(w, "# Movement\n")
 					fmt.Fprintf(w, "exit - exit the debugger\n")
-					fmt.Fprintf(w, "break [ <symbol> | <linespec> ] - sets a breakpoint\n")
-					fmt.Fprintf(w, "breakpoints - print out info for active breakpoints\n")
+					fmt.Fprintf(w, "break [ <symbol> | <linespec> ] [ if <expr> ] - sets a breakpoint\n")
+					fmt.Fprintf(w, "breakpoints - print out info for active breakpoints and watchpoints\n")
 					fmt.Fprintf(w, "clear [ <breakpoint-index> ] - deletes breakpoint\n")
+					fmt.Fprintf(w, "watch [ <expr> ] - stop when a string or the env and dir of a filesystem changes\n")
+					fmt.Fprintf(w, "unwatch [ <watchpoint-index> ] - deletes watchpoint\n")
 					fmt.Fprintf(w, "continue - run until breakpoint or program termination\n")
 					fmt.Fprintf(w, "next - step over to next source line\n")
 					fmt.Fprintf(w, "step - single step through program\n")
@@ -275,9 +550,12 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 					fmt.Fprintf(w, "env - print environment\n")
 					fmt.Fprintf(w, "network - print network mode\n")
 					fmt.Fprintf(w, "security - print security mode\n")
+					fmt.Fprintf(w, "ls [ <path> ] - list directory contents\n")
+					fmt.Fprintf(w, "cat <path> - print file contents\n")
+					fmt.Fprintf(w, "download <localdir> - download the filesystem\n")
 				case "list", "l":
 					if showList {
-						err = printList(color, ibs, w, s.node)
+						err = printList(color, ibs, w, s.Node)
 						if err != nil {
 							return err
 						}
@@ -288,7 +566,7 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 					if fun != nil {
 						args := fun.Params.List
 						for _, arg := range args {
-							obj := s.scope.Lookup(arg.Name.Name)
+							obj := s.Scope.Lookup(arg.Name.Name)
 							if obj == nil {
 								fmt.Fprintf(w, "err: %s\n", errors.WithStack(ErrCodeGen{arg, ErrUndefinedReference}))
 								continue
@@ -296,11 +574,48 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 							fmt.Fprintf(w, "%s %s = %#v\n", arg.Type, arg.Name, obj.Data)
 						}
 					}
+				case "ls":
+					st, ok := s.Value.(llb.State)
+					if !ok {
+						fmt.Fprintf(w, "current step is not in a fs scope\n")
+						continue
+					}
+
+					var path string
+					if len(args) == 2 {
+						path = args[1]
+					}
+
+					err = solveReference(ctx, c, st, func(ctx context.Context, ref gateway.Reference) error {
+						dir, err := statePath(ctx, st, path)
+						if err != nil {
+							return err
+						}
+
+						stats, err := ref.ReadDir(ctx, gateway.ReadDirRequest{
+							Path: dir,
+						})
+						if err != nil {
+							return err
+						}
+
+						for _, stat := range stats {
+							name := stat.Path
+							if stat.Linkname != "" {
+								name = fmt.Sprintf("%s -> %s", name, stat.Linkname)
+							}
+							fmt.Fprintf(w, "%s %d:%d %8d %s\n", os.FileMode(stat.Mode), stat.Uid, stat.Gid, stat.Size_, name)
+						}
+						return nil
+					})
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+					}
 				case "next", "n":
-					next = fun
+					stepper.Next(s)
 					return nil
 				case "network":
-					st, ok := s.value.(llb.State)
+					st, ok := s.Value.(llb.State)
 					if !ok {
 						fmt.Fprintf(w, "current step is not in a fs scope\n")
 						continue
@@ -313,8 +628,23 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 					}
 
 					fmt.Fprintf(w, "Network %s\n", network)
-				case "print":
-					fmt.Fprintf(w, "print\n")
+				case "print", "p":
+					if len(args) == 1 {
+						fmt.Fprintf(w, "print requires an expression\n")
+						continue
+					}
+
+					input := strings.TrimSpace(strings.TrimPrefix(command, args[0]))
+					_, v, err := Evaluate(ctx, c, s.Scope, fun, input)
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+						continue
+					}
+
+					err = PrintValue(ctx, w, v)
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+					}
 				case "restart", "r":
 					reverseStep = true
 					historyIndex = 1
@@ -327,7 +657,7 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 						return nil
 					}
 				case "security":
-					st, ok := s.value.(llb.State)
+					st, ok := s.Value.(llb.State)
 					if !ok {
 						fmt.Fprintf(w, "current step is not in a fs scope\n")
 						continue
@@ -341,15 +671,60 @@ func NewDebugger(c *client.Client, w io.Writer, r *bufio.Reader, ibs map[string]
 
 					fmt.Fprintf(w, "Security %s\n", security)
 				case "step", "s":
+					stepper.StepIn()
+					return nil
+				case "stepout", "so":
+					if len(s.Frames) < 2 {
+						fmt.Fprintf(w, "Cannot step out of the outermost function\n")
+						continue
+					}
+
+					stepper.StepOut(s)
 					return nil
-				case "stepout":
-					fmt.Fprintf(w, "unimplemented\n")
 				case "types":
 					for _, typ := range report.Types {
 						fmt.Fprintf(w, "%s\n", typ)
 					}
+				case "unwatch":
+					if len(args) == 1 {
+						watchpoints = nil
+					} else {
+						i, err := strconv.Atoi(args[1])
+						if err != nil || i < 0 || i >= len(watchpoints) {
+							fmt.Fprintf(w, "no watchpoint with index %s\n", args[1])
+							continue
+						}
+
+						watchpoints = append(watchpoints[:i], watchpoints[i+1:]...)
+						fmt.Fprintf(w, "Watchpoint %d cleared\n", i)
+					}
+				case "watch":
+					wp := &Watchpoint{
+						Expr: strings.TrimSpace(strings.TrimPrefix(command, args[0])),
+					}
+
+					err = wp.Init(ctx, c, s)
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+						continue
+					}
+
+					watchpoints = append(watchpoints, wp)
+					fmt.Fprintf(w, "Watchpoint %d set for %s\n", len(watchpoints)-1, wp)
 				case "whatis":
-					fmt.Fprintf(w, "unimplemented\n")
+					if len(args) == 1 {
+						fmt.Fprintf(w, "whatis requires an expression\n")
+						continue
+					}
+
+					input := strings.TrimSpace(strings.TrimPrefix(command, args[0]))
+					typ, _, err := checkDebugExpr(s.Scope, blockType(fun), input)
+					if err != nil {
+						fmt.Fprintf(w, "err: %s\n", err)
+						continue
+					}
+
+					fmt.Fprintf(w, "%s\n", typ)
 				default:
 					fmt.Fprintf(w, "unrecognized command %s\n", command)
 				}
@@ -452,22 +827,342 @@ func printList(color aurora.Aurora, ibs map[string]*report.IndexedBuffer, w io.W
 type Breakpoint struct {
 	Func *parser.FuncDecl
 	Call *parser.CallStmt
+
+	// Condition is a bool expression evaluated in the scope of the step, which
+	// must be true for the breakpoint to stop. Empty conditions always stop.
+	Condition string
+}
+
+func (bp *Breakpoint) String() string {
+	step := formatStep(bp.Func, bp.node())
+	if bp.Condition == "" {
+		return step
+	}
+	return fmt.Sprintf("%s if %s", step, bp.Condition)
+}
+
+// node returns the node the breakpoint stops at.
+func (bp *Breakpoint) node() parser.Node {
+	if bp.Call != nil {
+		return bp.Call
+	}
+	return bp.Func
+}
+
+// Matches returns whether the breakpoint stops at node.
+func (bp *Breakpoint) Matches(node parser.Node) bool {
+	switch n := node.(type) {
+	case *parser.FuncDecl:
+		return bp.Call == nil && bp.Func == n
+	case *parser.CallStmt:
+		return bp.Call == n
+	default:
+		return false
+	}
+}
+
+// Hit returns whether the breakpoint stops at the step of a snapshot. It
+// returns an error if the condition cannot be evaluated or isn't a bool.
+func (bp *Breakpoint) Hit(ctx context.Context, c *client.Client, s *Snapshot) (bool, error) {
+	if !bp.Matches(s.Node) {
+		return false, nil
+	}
+
+	if bp.Condition == "" {
+		return true, nil
+	}
+
+	typ, v, err := Evaluate(ctx, c, s.Scope, s.Func(), bp.Condition)
+	if err != nil {
+		return false, err
+	}
+
+	hit, ok := v.(bool)
+	if !ok {
+		return false, errors.Errorf("condition must be bool, got %s", typ)
+	}
+	return hit, nil
+}
+
+// Watchpoint stops the debugger when the value of an expression changes
+// between steps. Strings are compared by value and filesystems by their env
+// and working directory. An empty expression watches the filesystem of the
+// current step.
+type Watchpoint struct {
+	Expr string
+
+	value string
+}
+
+func (wp *Watchpoint) String() string {
+	if wp.Expr == "" {
+		return "<fs>"
+	}
+	return wp.Expr
 }
 
-func findStaticBreakpoints(mod *parser.Module) []*Breakpoint {
+// Init records the value of the watchpoint at the step of a snapshot.
+func (wp *Watchpoint) Init(ctx context.Context, c *client.Client, s *Snapshot) error {
+	value, err := wp.evaluate(ctx, c, s)
+	if err != nil {
+		return err
+	}
+
+	wp.value = value
+	return nil
+}
+
+// Update records the value of the watchpoint at the step of a snapshot and
+// returns the previous value and whether it changed. Steps where the
+// expression cannot be evaluated, like steps out of the scope of its
+// identifiers, are skipped.
+func (wp *Watchpoint) Update(ctx context.Context, c *client.Client, s *Snapshot) (string, bool) {
+	value, err := wp.evaluate(ctx, c, s)
+	if err != nil {
+		return wp.value, false
+	}
+
+	old := wp.value
+	wp.value = value
+	return old, old != value
+}
+
+func (wp *Watchpoint) evaluate(ctx context.Context, c *client.Client, s *Snapshot) (string, error) {
+	v := s.Value
+	if wp.Expr != "" {
+		var err error
+		_, v, err = Evaluate(ctx, c, s.Scope, s.Func(), wp.Expr)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v), nil
+	case llb.State:
+		dir, err := v.GetDir(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		env, err := v.Env(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("dir %q env %q", dir, env), nil
+	default:
+		if wp.Expr == "" {
+			return "", errors.Errorf("current step is not in a fs scope")
+		}
+		return "", errors.Errorf("%s is not a string or fs", wp.Expr)
+	}
+}
+
+// FindBreakpoint returns a breakpoint for a location, which is either a symbol
+// or a linespec. Symbols are functions or aliases of the module, or functions
+// selected from its imports like `module.func`. Linespecs are `<file>:<line>`,
+// or `<line>` for the file of the current node, and break at the first call
+// statement on that line, or the function declared there.
+func FindBreakpoint(mod *parser.Module, current parser.Node, loc string) (*Breakpoint, error) {
+	if mod == nil {
+		return nil, errors.Errorf("program has not started yet")
+	}
+
+	filename := current.Position().Filename
+	lineStr := loc
+	if i := strings.LastIndex(loc, ":"); i >= 0 {
+		filename, lineStr = loc[:i], loc[i+1:]
+	}
+
+	line, err := strconv.Atoi(lineStr)
+	if err != nil {
+		return findSymbolBreakpoint(mod, loc)
+	}
+
+	for _, m := range importedModules(mod) {
+		name := m.Pos.Filename
+		if name != filename && !strings.HasSuffix(name, string(filepath.Separator)+filename) {
+			continue
+		}
+
+		bp := findLineBreakpoint(m, line)
+		if bp == nil {
+			return nil, errors.Errorf("no function or call statement at %s:%d", name, line)
+		}
+		return bp, nil
+	}
+
+	return nil, errors.Errorf("no module with filename %s", filename)
+}
+
+func findSymbolBreakpoint(mod *parser.Module, symbol string) (*Breakpoint, error) {
+	parts := strings.SplitN(symbol, ".", 2)
+
+	obj := mod.Scope.Lookup(parts[0])
+	if obj == nil || obj.Kind != parser.DeclKind {
+		return nil, errors.Errorf("no function named %s", parts[0])
+	}
+
+	if len(parts) == 2 {
+		if _, ok := obj.Node.(*parser.ImportDecl); !ok {
+			return nil, errors.Errorf("%s is not an import", parts[0])
+		}
+
+		importScope, ok := obj.Data.(*parser.Scope)
+		if !ok {
+			return nil, errors.Errorf("import %s is not loaded", parts[0])
+		}
+
+		obj = importScope.Lookup(parts[1])
+		if obj == nil || obj.Kind != parser.DeclKind {
+			return nil, errors.Errorf("no function named %s in %s", parts[1], parts[0])
+		}
+	}
+
+	switch n := obj.Node.(type) {
+	case *parser.FuncDecl:
+		return &Breakpoint{Func: n}, nil
+	case *parser.AliasDecl:
+		return &Breakpoint{Func: n.Func, Call: n.Call}, nil
+	default:
+		return nil, errors.Errorf("%s is not a function", symbol)
+	}
+}
+
+func findLineBreakpoint(mod *parser.Module, line int) *Breakpoint {
+	var (
+		fun *parser.FuncDecl
+		bp  *Breakpoint
+	)
+	parser.Inspect(mod, func(node parser.Node) bool {
+		if bp != nil && bp.Call != nil {
+			return false
+		}
+
+		switch n := node.(type) {
+		case *parser.ImportDecl:
+			return false
+		case *parser.FuncDecl:
+			fun = n
+			if bp == nil && n.Pos.Line == line {
+				bp = &Breakpoint{Func: n}
+			}
+
+			// Statements of option blocks are not stepped through.
+			return n.Type.Primary() != parser.Option
+		case *parser.FuncLit:
+			return n.Type.Primary() != parser.Option
+		case *parser.CallStmt:
+			if n.Pos.Line == line && !isBreakpoint(n) {
+				bp = &Breakpoint{Func: fun, Call: n}
+			}
+		}
+		return true
+	})
+	return bp
+}
+
+// importedModules returns the module and the modules it imports, recursively.
+func importedModules(mod *parser.Module) []*parser.Module {
+	var (
+		mods    []*parser.Module
+		visited = make(map[*parser.Module]struct{})
+		visit   func(mod *parser.Module)
+	)
+	visit = func(mod *parser.Module) {
+		if _, ok := visited[mod]; ok {
+			return
+		}
+		visited[mod] = struct{}{}
+		mods = append(mods, mod)
+
+		for _, obj := range mod.Scope.Defined(parser.DeclKind) {
+			if _, ok := obj.Node.(*parser.ImportDecl); !ok {
+				continue
+			}
+
+			importScope, ok := obj.Data.(*parser.Scope)
+			if !ok {
+				continue
+			}
+
+			if m, ok := importScope.Node.(*parser.Module); ok {
+				visit(m)
+			}
+		}
+	}
+	visit(mod)
+	return mods
+}
+
+// nextFrames returns the call stack after the debugger is invoked for a node.
+// Functions are pushed when they are entered. There is no step when a function
+// returns, so a call statement pops the frames above the function it belongs
+// to.
+func nextFrames(frames []Frame, scope *parser.Scope, node parser.Node) []Frame {
+	switch n := node.(type) {
+	case *parser.FuncDecl:
+		return append(frames[:len(frames):len(frames)], Frame{Func: n, Node: n})
+	case *parser.CallStmt:
+		fun := enclosingFunc(scope)
+		for i := len(frames) - 1; i >= 0; i-- {
+			if frames[i].Func == fun {
+				return append(frames[:i:i], Frame{Func: fun, Node: n})
+			}
+		}
+		return []Frame{{Func: fun, Node: n}}
+	default:
+		return nil
+	}
+}
+
+// enclosingFunc returns the function of a lexical scope.
+func enclosingFunc(scope *parser.Scope) *parser.FuncDecl {
+	for ; scope != nil; scope = scope.Outer {
+		if fun, ok := scope.Node.(*parser.FuncDecl); ok {
+			return fun
+		}
+	}
+	return nil
+}
+
+// formatStep formats a node the debugger stops at along with its function.
+func formatStep(fun *parser.FuncDecl, node parser.Node) string {
+	msg := checker.FormatPos(node.Position())
+	if fun != nil {
+		msg = fmt.Sprintf("%s%s %s", fun.Name, fun.Params, msg)
+	}
+
+	if call, ok := node.(*parser.CallStmt); ok {
+		stmt := *call
+		stmt.StmtEnd = nil
+		msg = fmt.Sprintf("%s %s", msg, &stmt)
+	}
+	return msg
+}
+
+// FindStaticBreakpoints returns the breakpoints of the `breakpoint` calls in a
+// module.
+func FindStaticBreakpoints(mod *parser.Module) []*Breakpoint {
 	var breakpoints []*Breakpoint
 
+	var fun *parser.FuncDecl
 	parser.Inspect(mod, func(node parser.Node) bool {
-		if n, ok := node.(*parser.FuncDecl); ok {
-			for _, stmt := range n.Body.NonEmptyStmts() {
-				fun := stmt.Call.Func
-				if fun.Ident != nil && fun.Ident.Name == "breakpoint" {
-					bp := &Breakpoint{
-						Func: n,
-						Call: stmt.Call,
-					}
-					breakpoints = append(breakpoints, bp)
+		switch n := node.(type) {
+		case *parser.ImportDecl:
+			return false
+		case *parser.FuncDecl:
+			fun = n
+		case *parser.CallStmt:
+			// Breakpoints may also be inside the branches of if statements.
+			if n.Func.Ident != nil && n.Func.Ident.Name == "breakpoint" {
+				bp := &Breakpoint{
+					Func: fun,
+					Call: n,
 				}
+				breakpoints = append(breakpoints, bp)
 			}
 		}
 		return true
@@ -476,8 +1171,351 @@ func findStaticBreakpoints(mod *parser.Module) []*Breakpoint {
 	return breakpoints
 }
 
+// blockType returns the type of the block being debugged, which is the type
+// builtins are looked up by first.
+func blockType(fun *parser.FuncDecl) parser.ObjType {
+	if fun == nil {
+		return parser.Filesystem
+	}
+	return fun.Type.ObjType
+}
+
+// checkDebugExpr parses an expression entered in the debugger and checks it
+// against the scope of the current step. The expression may be a literal or an
+// identifier, selector or call of a builtin with its arguments. It returns the
+// type of the expression and the expression to emit, where calls are wrapped
+// in a function literal of that type.
+func checkDebugExpr(scope *parser.Scope, prefer parser.ObjType, input string) (parser.ObjType, *parser.Expr, error) {
+	mod, err := parser.Parse(&parser.NamedReader{
+		Reader: strings.NewReader(fmt.Sprintf("fs expr() {\n%s\n}", input)),
+		Value:  "<expr>",
+	})
+	if err != nil {
+		return parser.None, nil, err
+	}
+
+	if len(mod.Decls) != 1 || mod.Decls[0].Func == nil {
+		return parser.None, nil, errors.Errorf("invalid expression %q", input)
+	}
+
+	stmts := mod.Decls[0].Func.Body.NonEmptyStmts()
+	if len(stmts) != 1 || stmts[0].Call == nil {
+		return parser.None, nil, errors.Errorf("invalid expression %q", input)
+	}
+
+	call := stmts[0].Call
+	typ, err := debugExprType(scope, prefer, call.Func)
+	if err != nil {
+		return parser.None, nil, err
+	}
+
+	expr := call.Func
+	switch {
+	case expr.Ident != nil, expr.Selector != nil:
+		call.StmtEnd = nil
+		expr = parser.NewFuncLitExpr(typ, &parser.Stmt{Call: call})
+	case len(call.Args) > 0 || call.WithOpt != nil:
+		return parser.None, nil, errors.WithStack(ErrCodeGen{expr, errors.Errorf("literal cannot be called")})
+	}
+
+	err = checker.CheckExpr(scope, typ, expr)
+	if err != nil {
+		return parser.None, nil, err
+	}
+
+	return typ, expr, nil
+}
+
+// debugExprType returns the type of the value an expression evaluates to.
+// Builtins of the same name may exist for several types, so builtins of the
+// preferred type are resolved first.
+func debugExprType(scope *parser.Scope, prefer parser.ObjType, expr *parser.Expr) (parser.ObjType, error) {
+	switch {
+	case expr.BasicLit != nil:
+		return expr.BasicLit.ObjType(), nil
+	case expr.ListLit != nil:
+		return parser.ListOf(parser.Str), nil
+	case expr.FuncLit != nil:
+		return expr.FuncLit.Type.ObjType, nil
+	case expr.Ident == nil && expr.Selector == nil:
+		return parser.None, errors.WithStack(ErrCodeGen{expr, errors.Errorf("invalid expression")})
+	}
+
+	name := expr.Name()
+	if expr.Selector == nil {
+		if _, ok := builtin.Lookup.ByType[prefer].Func[name]; ok {
+			return prefer, nil
+		}
+	}
+
+	obj := scope.Lookup(name)
+	if obj == nil {
+		var types []string
+		for typ, lookup := range builtin.Lookup.ByType {
+			if _, ok := lookup.Func[name]; ok {
+				types = append(types, string(typ))
+			}
+		}
+		sort.Strings(types)
+
+		switch len(types) {
+		case 0:
+			return parser.None, errors.WithStack(ErrCodeGen{expr, ErrUndefinedReference})
+		case 1:
+			return parser.ObjType(types[0]), nil
+		default:
+			return parser.None, errors.WithStack(ErrCodeGen{expr, errors.Errorf("%s is a builtin of types %s", name, strings.Join(types, ", "))})
+		}
+	}
+
+	node := obj.Node
+	if _, ok := node.(*parser.ImportDecl); ok && expr.Selector != nil {
+		importScope, ok := obj.Data.(*parser.Scope)
+		if !ok {
+			return parser.None, errors.WithStack(ErrCodeGen{expr, errors.Errorf("import %s is not loaded", name)})
+		}
+
+		importObj := importScope.Lookup(expr.Selector.Select.Name)
+		if importObj == nil {
+			return parser.None, errors.WithStack(ErrCodeGen{expr.Selector.Select, ErrUndefinedReference})
+		}
+		node = importObj.Node
+	}
+
+	switch n := node.(type) {
+	case *parser.FuncDecl:
+		return n.Type.ObjType, nil
+	case *parser.AliasDecl:
+		return n.Func.Type.ObjType, nil
+	case *parser.Field:
+		// Function values are typed by the value they return.
+		ret, _ := n.Type.Signature()
+		return ret, nil
+	default:
+		return parser.None, errors.WithStack(ErrCodeGen{expr, errors.Errorf("%s is not a value", name)})
+	}
+}
+
+// Evaluate checks and evaluates an expression entered in the debugger in the
+// scope of a step in fun, returning its type and value.
+func Evaluate(ctx context.Context, c *client.Client, scope *parser.Scope, fun *parser.FuncDecl, input string) (parser.ObjType, interface{}, error) {
+	typ, expr, err := checkDebugExpr(scope, blockType(fun), input)
+	if err != nil {
+		return typ, nil, err
+	}
+
+	cg, err := New(WithClient(c))
+	if err != nil {
+		return typ, nil, err
+	}
+
+	v, err := cg.emitDebugExpr(ctx, scope, typ, expr)
+	return typ, v, err
+}
+
+// emitDebugExpr evaluates an expression checked by checkDebugExpr.
+func (cg *CodeGen) emitDebugExpr(ctx context.Context, scope *parser.Scope, typ parser.ObjType, expr *parser.Expr) (interface{}, error) {
+	if expr.FuncLit != nil {
+		return cg.EmitFuncLit(ctx, scope, expr.FuncLit, string(expr.FuncLit.Type.Secondary()), noopAliasCallback)
+	}
+
+	switch typ {
+	case parser.Str:
+		return cg.EmitStringExpr(ctx, scope, expr)
+	case parser.ListOf(parser.Str):
+		return cg.EmitStringListExpr(ctx, scope, expr)
+	case parser.Int:
+		return cg.EmitIntExpr(ctx, scope, expr)
+	case parser.Bool:
+		return cg.EmitBoolExpr(ctx, scope, expr)
+	default:
+		return nil, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown expr type %s", typ)})
+	}
+}
+
+// PrintValue prints a value emitted by the debugger. Filesystems are printed
+// as the LLB ops they marshal to.
+func PrintValue(ctx context.Context, w io.Writer, v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		fmt.Fprintf(w, "%q\n", v)
+	case []string:
+		fmt.Fprintf(w, "%q\n", v)
+	case llb.State:
+		ops, err := DescribeState(ctx, v)
+		if err != nil {
+			return err
+		}
+
+		if len(ops) == 0 {
+			fmt.Fprintf(w, "scratch\n")
+		}
+
+		for _, op := range ops {
+			fmt.Fprintf(w, "%s\n", op)
+		}
+	case []interface{}:
+		for i, opt := range v {
+			fmt.Fprintf(w, "%d: %T %+v\n", i, opt, opt)
+		}
+	default:
+		fmt.Fprintf(w, "%#v\n", v)
+	}
+	return nil
+}
+
+// DescribeState returns the digest and a description of every LLB op the
+// state marshals to.
+func DescribeState(ctx context.Context, st llb.State) ([]string, error) {
+	if st.Output() == nil {
+		return nil, nil
+	}
+
+	def, err := st.Marshal(ctx, llb.Platform(DefaultPlatform))
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := loadLLB(def)
+	if err != nil {
+		return nil, err
+	}
+
+	var descs []string
+	for _, op := range ops {
+		// The last op only references the output of the graph.
+		if op.Op.Op == nil {
+			continue
+		}
+
+		name, _ := attr(op.Digest, op.Op)
+		descs = append(descs, fmt.Sprintf("%s %s", op.Digest, name))
+	}
+	return descs, nil
+}
+
+// solveReference solves a state and calls fn with its reference before the
+// build is done. Local sources emitted before the current step are synced
+// through the session of the context.
+func solveReference(ctx context.Context, c *client.Client, st llb.State, fn func(ctx context.Context, ref gateway.Reference) error) error {
+	def, err := st.Marshal(ctx, llb.Platform(DefaultPlatform))
+	if err != nil {
+		return err
+	}
+
+	s, err := newDebugSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		return s.Run(ctx, c.Dialer())
+	})
+
+	g.Go(func() error {
+		defer s.Close()
+		return solver.Build(ctx, c, s, nil, func(ctx context.Context, gc gateway.Client) (*gateway.Result, error) {
+			res, err := gc.Solve(ctx, gateway.SolveRequest{
+				Definition: def.ToPB(),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			ref, err := res.SingleRef()
+			if err != nil {
+				return nil, err
+			}
+
+			// Solving scratch results in a nil reference.
+			if ref == nil {
+				return nil, errors.Errorf("cannot read from scratch")
+			}
+
+			return gateway.NewResult(), fn(ctx, ref)
+		})
+	})
+
+	return g.Wait()
+}
+
+// statePath returns a path in a state, where relative paths are relative to
+// the working directory of the state.
+func statePath(ctx context.Context, st llb.State, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	dir, err := st.GetDir(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if dir == "" {
+		dir = "/"
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// downloadReference copies the directory dir of a reference to localDir.
+func downloadReference(ctx context.Context, ref gateway.Reference, dir, localDir string) error {
+	err := os.MkdirAll(localDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	stats, err := ref.ReadDir(ctx, gateway.ReadDirRequest{
+		Path: dir,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range stats {
+		path := filepath.Join(dir, stat.Path)
+		localPath := filepath.Join(localDir, stat.Path)
+
+		mode := os.FileMode(stat.Mode)
+
+		// Existing entries are replaced rather than written through, so a
+		// symlink already in localDir cannot redirect the download elsewhere.
+		var fi os.FileInfo
+		fi, err = os.Lstat(localPath)
+		switch {
+		case err == nil && !(fi.IsDir() && mode.IsDir()):
+			err = os.RemoveAll(localPath)
+		case os.IsNotExist(err):
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case mode.IsDir():
+			err = downloadReference(ctx, ref, path, localPath)
+		case mode&os.ModeSymlink != 0:
+			err = os.Symlink(stat.Linkname, localPath)
+		case mode.IsRegular():
+			var dt []byte
+			dt, err = ref.ReadFile(ctx, gateway.ReadRequest{
+				Filename: path,
+			})
+			if err == nil {
+				err = ioutil.WriteFile(localPath, dt, mode.Perm())
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func printGraph(ctx context.Context, st llb.State, sh string) error {
-	def, err := st.Marshal(ctx, llb.LinuxAmd64)
+	def, err := st.Marshal(ctx, llb.Platform(DefaultPlatform))
 	if err != nil {
 		return err
 	}
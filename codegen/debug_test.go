@@ -0,0 +1,456 @@
+package codegen
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/report"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+var (
+	// colorPattern matches the escape codes of colored output.
+	colorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+	// listingPattern matches the lines of source and carets printed when the
+	// debugger stops, but not their header.
+	listingPattern = regexp.MustCompile(`^\s*(\d+\s*\||⫶)`)
+)
+
+// debugScript generates the default target of a module with a debugger that
+// reads its commands from a script. It returns the output of the debugger
+// without the source listings printed when it stops.
+func debugScript(t *testing.T, input, script string, opts ...CodeGenOption) string {
+	ib := report.NewIndexedBuffer()
+	mod, err := parser.Parse(&parser.NamedReader{
+		Reader: io.TeeReader(strings.NewReader(cleanup(input)), ib),
+		Value:  "build.hlb",
+	})
+	require.NoError(t, err)
+
+	err = checker.Check(mod)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	ibs := map[string]*report.IndexedBuffer{"build.hlb": ib}
+	opts = append([]CodeGenOption{WithDebugger(NewScriptDebugger(nil, &buf, strings.NewReader(strings.TrimSpace(cleanup(script))), ibs))}, opts...)
+
+	cg, err := New(opts...)
+	require.NoError(t, err)
+
+	_, err = cg.Generate(context.Background(), mod, []Target{{Name: "default"}})
+	if err != nil {
+		require.Equal(t, ErrDebugExit, errors.Cause(err))
+	}
+
+	var lines []string
+	for _, line := range strings.Split(colorPattern.ReplaceAllString(buf.String(), ""), "\n") {
+		if !listingPattern.MatchString(line) {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func TestDebugger(t *testing.T) {
+	t.Parallel()
+
+	input := `
+	fs default() {
+		base "alpine"
+		env "FOO" "bar"
+	}
+
+	fs base(string ref) {
+		image ref
+		dir "/src"
+	}
+	`
+
+	type testCase struct {
+		name     string
+		script   string
+		expected string
+	}
+
+	for _, tc := range []testCase{{
+		"symbol breakpoint",
+		`
+		break base
+		continue
+		`,
+		`
+		(hlb) break base
+		Breakpoint 0 set for base(string ref) build.hlb:7:1:
+		(hlb) continue
+		--> build.hlb:7:1:
+		(hlb)
+		`,
+	}, {
+		"linespec breakpoints",
+		`
+		break build.hlb:9
+		break 4
+		continue
+		continue
+		`,
+		`
+		(hlb) break build.hlb:9
+		Breakpoint 0 set for base(string ref) build.hlb:9:2: dir "/src"
+		(hlb) break 4
+		Breakpoint 1 set for default() build.hlb:4:2: env "FOO" "bar"
+		(hlb) continue
+		--> build.hlb:9:2:
+		(hlb) continue
+		--> build.hlb:4:2:
+		(hlb)
+		`,
+	}, {
+		"invalid breakpoints",
+		`
+		break build.hlb:6
+		break missing
+		break other.hlb:1
+		`,
+		`
+		(hlb) break build.hlb:6
+		err: no function or call statement at build.hlb:6
+		(hlb) break missing
+		err: no function named missing
+		(hlb) break other.hlb:1
+		err: no module with filename other.hlb
+		(hlb)
+		`,
+	}, {
+		"clear by index",
+		`
+		break base
+		break build.hlb:9
+		clear 2
+		clear 0
+		breakpoints
+		continue
+		`,
+		`
+		(hlb) break base
+		Breakpoint 0 set for base(string ref) build.hlb:7:1:
+		(hlb) break build.hlb:9
+		Breakpoint 1 set for base(string ref) build.hlb:9:2: dir "/src"
+		(hlb) clear 2
+		no breakpoint with index 2
+		(hlb) clear 0
+		Breakpoint 0 cleared
+		(hlb) breakpoints
+		Breakpoint 0 for base(string ref) build.hlb:9:2: dir "/src"
+		(hlb) continue
+		--> build.hlb:9:2:
+		(hlb)
+		`,
+	}, {
+		"stepout and backtrace",
+		`
+		backtrace
+		break build.hlb:9
+		continue
+		backtrace
+		stepout
+		backtrace
+		stepout
+		`,
+		`
+		(hlb) backtrace
+		Program has not started yet
+		(hlb) break build.hlb:9
+		Breakpoint 0 set for base(string ref) build.hlb:9:2: dir "/src"
+		(hlb) continue
+		--> build.hlb:9:2:
+		(hlb) backtrace
+		0 base(string ref) build.hlb:9:2: dir "/src"
+		1 default() build.hlb:3:2: base "alpine"
+		(hlb) stepout
+		--> build.hlb:4:2:
+		(hlb) backtrace
+		0 default() build.hlb:4:2: env "FOO" "bar"
+		(hlb) stepout
+		Cannot step out of the outermost function
+		(hlb)
+		`,
+	}, {
+		"conditional breakpoints",
+		`
+		break base if equal ref "busybox"
+		break build.hlb:9 if equal ref "alpine"
+		continue
+		print ref
+		`,
+		`
+		(hlb) break base if equal ref "busybox"
+		Breakpoint 0 set for base(string ref) build.hlb:7:1: if equal ref "busybox"
+		(hlb) break build.hlb:9 if equal ref "alpine"
+		Breakpoint 1 set for base(string ref) build.hlb:9:2: dir "/src" if equal ref "alpine"
+		(hlb) continue
+		--> build.hlb:9:2:
+		(hlb) print ref
+		"alpine"
+		(hlb)
+		`,
+	}, {
+		"invalid conditions",
+		`
+		break base if
+		break base if ref
+		continue
+		`,
+		`
+		(hlb) break base if
+		break if requires a condition
+		(hlb) break base if ref
+		Breakpoint 0 set for base(string ref) build.hlb:7:1: if ref
+		(hlb) continue
+		err: breakpoint condition "ref": condition must be bool, got string
+		--> build.hlb:7:1:
+		(hlb)
+		`,
+	}, {
+		"watchpoints",
+		`
+		watch
+		break build.hlb:8
+		continue
+		watch
+		watch ref
+		unwatch 2
+		breakpoints
+		continue
+		`,
+		`
+		(hlb) watch
+		err: current step is not in a fs scope
+		(hlb) break build.hlb:8
+		Breakpoint 0 set for base(string ref) build.hlb:8:2: image ref
+		(hlb) continue
+		--> build.hlb:8:2:
+		(hlb) watch
+		Watchpoint 0 set for <fs>
+		(hlb) watch ref
+		Watchpoint 1 set for ref
+		(hlb) unwatch 2
+		no watchpoint with index 2
+		(hlb) breakpoints
+		Breakpoint 0 for base(string ref) build.hlb:8:2: image ref
+		Watchpoint 0 for <fs>
+		Watchpoint 1 for ref
+		(hlb) continue
+		Watchpoint 0 <fs> changed from dir "/" env [] to dir "/src" env []
+		--> build.hlb:4:2:
+		(hlb)
+		`,
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := debugScript(t, input, tc.script)
+			require.Equal(t, strings.TrimSpace(cleanup(tc.expected)), actual)
+		})
+	}
+}
+
+// testReference is a gateway.Reference backed by a map of paths to file
+// contents. Directories are implied by the paths of their files, and contents
+// starting with "->" are symlinks.
+type testReference map[string]string
+
+func (r testReference) ToState() (llb.State, error) {
+	return llb.Scratch(), nil
+}
+
+func (r testReference) ReadFile(ctx context.Context, req gateway.ReadRequest) ([]byte, error) {
+	return []byte(r[req.Filename]), nil
+}
+
+func (r testReference) StatFile(ctx context.Context, req gateway.StatRequest) (*fstypes.Stat, error) {
+	return nil, os.ErrNotExist
+}
+
+func (r testReference) ReadDir(ctx context.Context, req gateway.ReadDirRequest) ([]*fstypes.Stat, error) {
+	prefix := strings.TrimSuffix(req.Path, "/") + "/"
+
+	stats := make(map[string]*fstypes.Stat)
+	for path, content := range r {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(path, prefix), "/", 2)
+		switch {
+		case len(parts) == 2:
+			stats[parts[0]] = &fstypes.Stat{Path: parts[0], Mode: uint32(os.ModeDir | 0755)}
+		case strings.HasPrefix(content, "->"):
+			stats[parts[0]] = &fstypes.Stat{Path: parts[0], Mode: uint32(os.ModeSymlink | 0777), Linkname: strings.TrimPrefix(content, "->")}
+		default:
+			stats[parts[0]] = &fstypes.Stat{Path: parts[0], Mode: 0644}
+		}
+	}
+
+	var sorted []*fstypes.Stat
+	for _, stat := range stats {
+		sorted = append(sorted, stat)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	return sorted, nil
+}
+
+func TestDownloadReference(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "hlb-download")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Existing entries in the destination are replaced, including symlinks
+	// that would otherwise redirect writes outside of it.
+	outside := filepath.Join(dir, "outside")
+	err = ioutil.WriteFile(outside, []byte("untouched"), 0644)
+	require.NoError(t, err)
+
+	localDir := filepath.Join(dir, "download")
+	err = os.MkdirAll(filepath.Join(localDir, "etc"), 0755)
+	require.NoError(t, err)
+	err = os.Symlink(outside, filepath.Join(localDir, "etc", "hosts"))
+	require.NoError(t, err)
+	err = os.Symlink(dir, filepath.Join(localDir, "lib"))
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(localDir, "link"), []byte("stale"), 0644)
+	require.NoError(t, err)
+
+	ref := testReference{
+		"/etc/hosts":        "127.0.0.1 localhost\n",
+		"/lib/outside":      "overwritten",
+		"/link":             "->etc/hosts",
+		"/usr/bin/hello.sh": "echo hello\n",
+	}
+
+	err = downloadReference(context.Background(), ref, "/", localDir)
+	require.NoError(t, err)
+
+	dt, err := ioutil.ReadFile(outside)
+	require.NoError(t, err)
+	require.Equal(t, "untouched", string(dt))
+
+	for path, expected := range map[string]string{
+		"etc/hosts":        "127.0.0.1 localhost\n",
+		"lib/outside":      "overwritten",
+		"link":             "127.0.0.1 localhost\n",
+		"usr/bin/hello.sh": "echo hello\n",
+	} {
+		dt, err := ioutil.ReadFile(filepath.Join(localDir, path))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(dt), path)
+	}
+
+	fi, err := os.Lstat(filepath.Join(localDir, "lib"))
+	require.NoError(t, err)
+	require.True(t, fi.IsDir())
+
+	linkname, err := os.Readlink(filepath.Join(localDir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "etc/hosts", linkname)
+}
+
+func TestScriptDebugger(t *testing.T) {
+	t.Parallel()
+
+	// Blank lines prompt again, and the end of the script exits the debugger
+	// so codegen stops at the step it is at.
+	var trace bytes.Buffer
+	actual := debugScript(t, `
+	fs default() {
+		base "alpine"
+		env "FOO" "bar"
+	}
+
+	fs base(string ref) {
+		image ref
+	}
+	`, `
+	step
+
+	step
+	`, WithTrace(&trace))
+
+	require.Equal(t, strings.TrimSpace(cleanup(`
+	(hlb) step
+	--> build.hlb:2:1:
+	(hlb)
+	(hlb) step
+	--> build.hlb:3:2:
+	(hlb)
+	`)), actual)
+
+	require.Equal(t, strings.TrimLeft(cleanup(`
+	trace: module build.hlb:1:1: = <nil>
+	trace: func default() build.hlb:2:1: = <nil>
+	trace: call default() build.hlb:3:2: base "alpine" = fs scratch
+	`), "\n"), trace.String())
+}
+
+func TestStepper(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		resume   func(st *Stepper, s *Snapshot)
+		depths   []int
+		expected []bool
+	}
+
+	for _, tc := range []testCase{{
+		"step in",
+		func(st *Stepper, s *Snapshot) { st.StepIn() },
+		[]int{2, 3, 2, 1},
+		[]bool{true, true, true, true},
+	}, {
+		"next",
+		func(st *Stepper, s *Snapshot) { st.Next(s) },
+		[]int{3, 2, 3, 2},
+		[]bool{false, true, true, true},
+	}, {
+		"step out",
+		func(st *Stepper, s *Snapshot) { st.StepOut(s) },
+		[]int{2, 3, 2, 1},
+		[]bool{false, false, false, true},
+	}, {
+		"continue",
+		func(st *Stepper, s *Snapshot) { st.Continue() },
+		[]int{2, 3, 2, 1},
+		[]bool{false, false, false, false},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			// The program is stopped in a function called by another function.
+			var st Stepper
+			tc.resume(&st, &Snapshot{Frames: make([]Frame, 2)})
+
+			var stops []bool
+			for _, depth := range tc.depths {
+				stops = append(stops, st.Stops(&Snapshot{Frames: make([]Frame, depth)}))
+			}
+			require.Equal(t, tc.expected, stops)
+		})
+	}
+}
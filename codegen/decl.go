@@ -11,13 +11,13 @@ import (
 )
 
 func (cg *CodeGen) EmitFuncDecl(ctx context.Context, scope *parser.Scope, fun *parser.FuncDecl, args []*parser.Expr, ac aliasCallback, chainStart interface{}) (interface{}, error) {
-	nonVariadicArgs := 0
+	requiredArgs := 0
 	for _, field := range fun.Params.List {
-		if field.Variadic == nil {
-			nonVariadicArgs++
+		if field.Variadic == nil && field.Default == nil {
+			requiredArgs++
 		}
 	}
-	if len(args) < nonVariadicArgs {
+	if len(args) < requiredArgs {
 		return nil, errors.WithStack(errors.Errorf("%s expected args %s, found %s", fun.Name, fun.Params.List, args))
 	}
 
@@ -36,9 +36,15 @@ func (cg *CodeGen) EmitFuncDecl(ctx context.Context, scope *parser.Scope, fun *p
 	case parser.Filesystem:
 		return cg.EmitFilesystemBlock(ctx, fun.Scope, fun.Body, ac, chainStart)
 	case parser.Option:
-		return cg.EmitOptionBlock(ctx, fun.Scope, string(fun.Type.Secondary()), fun.Body, ac)
+		return cg.EmitOptionBlock(ctx, fun.Scope, optionSubtype(ctx, fun.Type), fun.Body, ac)
 	case parser.Str:
 		return cg.EmitStringBlock(ctx, fun.Scope, fun.Body, chainStart)
+	case parser.ListOf(parser.Str):
+		return cg.EmitStringListBlock(ctx, fun.Scope, fun.Body, chainStart)
+	case parser.Int:
+		return cg.EmitIntBlock(ctx, fun.Scope, fun.Body, chainStart)
+	case parser.Bool:
+		return cg.EmitBoolBlock(ctx, fun.Scope, fun.Body, chainStart)
 	case parser.Group:
 		return cg.EmitGroupBlock(ctx, fun.Scope, fun.Body, ac, chainStart)
 	default:
@@ -168,48 +174,75 @@ func (cg *CodeGen) EmitGroupAliasDecl(ctx context.Context, scope *parser.Scope,
 }
 
 func (cg *CodeGen) ParameterizedScope(ctx context.Context, scope *parser.Scope, fun *parser.FuncDecl, args []*parser.Expr, ac aliasCallback) error {
+	args = positionalArgs(fun.Params.List, args)
 	for i, field := range fun.Params.List {
 		var (
 			data interface{}
 			err  error
 		)
 
+		// Defaults are evaluated in the scope of the module the function was
+		// declared in.
+		argScope := scope
+		if field.Default != nil && args[i] == field.Default.Value {
+			argScope = fun.Scope.Root()
+		}
+
 		typ := field.Type.Primary()
+		if field.Type.IsFunc() {
+			// Function values are not evaluated until they are called, so only the
+			// function they refer to is resolved.
+			typ = parser.None
+			data, err = cg.EmitFuncValueExpr(ctx, argScope, args[i])
+		}
+
 		switch typ {
 		case parser.Str:
-			var v string
-			v, err = cg.EmitStringExpr(ctx, scope, args[i])
+			if field.Variadic != nil {
+				// Variadic string fields are bound to the list of their arguments,
+				// with lists spread into their elements.
+				var v []string
+				v, err = cg.EmitStringExprs(ctx, argScope, args[i:])
+				data = v
+			} else {
+				var v string
+				v, err = cg.EmitStringExpr(ctx, argScope, args[i])
+				data = v
+			}
+		case parser.ListOf(parser.Str):
+			var v []string
+			v, err = cg.EmitStringListExpr(ctx, argScope, args[i])
 			data = v
 		case parser.Int:
 			var v int
-			v, err = cg.EmitIntExpr(ctx, scope, args[i])
+			v, err = cg.EmitIntExpr(ctx, argScope, args[i])
 			data = v
 		case parser.Bool:
 			var v bool
-			v, err = cg.EmitBoolExpr(ctx, scope, args[i])
+			v, err = cg.EmitBoolExpr(ctx, argScope, args[i])
 			data = v
 		case parser.Filesystem:
 			var v llb.State
-			v, err = cg.EmitFilesystemExpr(ctx, scope, args[i], ac)
+			v, err = cg.EmitFilesystemExpr(ctx, argScope, args[i], ac)
 			data = v
 		case parser.Option:
 			var v []interface{}
 			if field.Variadic != nil {
 				for j := i; j < len(args); j++ {
 					var vv []interface{}
-					vv, err = cg.EmitOptionExpr(ctx, scope, args[i], nil, string(field.Type.Secondary()))
+					vv, err = cg.EmitOptionExpr(ctx, argScope, args[j], nil, optionSubtype(ctx, field.Type))
 					if err != nil {
 						break
 					}
 					v = append(v, vv...)
 				}
 			} else {
-				v, err = cg.EmitOptionExpr(ctx, scope, args[i], nil, string(field.Type.Secondary()))
+				v, err = cg.EmitOptionExpr(ctx, argScope, args[i], nil, optionSubtype(ctx, field.Type))
 			}
 			data = v
 		case parser.Group:
 			var v solver.Request
-			v, err = cg.EmitGroupExpr(ctx, scope, args[i], ac)
+			v, err = cg.EmitGroupExpr(ctx, argScope, args[i], ac)
 			data = v
 		}
 		if err != nil {
@@ -225,3 +258,109 @@ func (cg *CodeGen) ParameterizedScope(ctx context.Context, scope *parser.Scope,
 	}
 	return nil
 }
+
+// positionalArgs returns the arguments in the order of the fields they are
+// passed to. Named arguments are moved to the position of their field, and
+// fields without an argument are passed their default.
+func positionalArgs(fields []*parser.Field, args []*parser.Expr) []*parser.Expr {
+	var (
+		positional []*parser.Expr
+		named      = make(map[string]*parser.Expr)
+	)
+	for _, arg := range args {
+		if arg.Named != nil {
+			named[arg.Named.Name.Name] = arg
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	if len(named) == 0 && len(positional) >= len(fields) {
+		return args
+	}
+
+	var bound []*parser.Expr
+	for i, field := range fields {
+		switch {
+		case i < len(positional):
+			if field.Variadic != nil {
+				bound = append(bound, positional[i:]...)
+			} else {
+				bound = append(bound, positional[i])
+			}
+		case named[field.Name.Name] != nil:
+			bound = append(bound, named[field.Name.Name])
+		case field.Default != nil:
+			bound = append(bound, field.Default.Value)
+		}
+	}
+	return bound
+}
+
+// funcValue is the value of a parameter with a function type. It refers to
+// either a function declaration or a function literal along with the scope it
+// was passed from.
+type funcValue struct {
+	scope *parser.Scope
+	fun   *parser.FuncDecl
+	lit   *parser.FuncLit
+}
+
+func (cg *CodeGen) EmitFuncValueExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr) (*funcValue, error) {
+	switch {
+	case expr.FuncLit != nil:
+		return &funcValue{scope: scope, lit: expr.FuncLit}, nil
+	case expr.Ident != nil, expr.Selector != nil:
+		obj := scope.Lookup(expr.Name())
+		if obj == nil {
+			return nil, errors.WithStack(ErrCodeGen{expr.IdentNode(), ErrUndefinedReference})
+		}
+
+		switch n := obj.Node.(type) {
+		case *parser.FuncDecl:
+			return &funcValue{fun: n}, nil
+		case *parser.ImportDecl:
+			importScope := obj.Data.(*parser.Scope)
+			importObj := importScope.Lookup(expr.Selector.Select.Name)
+			if importObj == nil {
+				return nil, errors.WithStack(ErrCodeGen{expr.Selector, ErrUndefinedReference})
+			}
+
+			fun, ok := importObj.Node.(*parser.FuncDecl)
+			if !ok {
+				return nil, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown func value")})
+			}
+			return &funcValue{fun: fun}, nil
+		case *parser.Field:
+			// Function values may be passed along by parameters of the calling
+			// function.
+			fv, ok := obj.Data.(*funcValue)
+			if !ok {
+				return nil, errors.WithStack(ErrCodeGen{expr, ErrBadCast})
+			}
+			return fv, nil
+		}
+	}
+	return nil, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown func value")})
+}
+
+// EmitFieldValue returns the value of a parameter. If the parameter is a
+// function value, the function is called with the given arguments.
+func (cg *CodeGen) EmitFieldValue(ctx context.Context, scope *parser.Scope, obj *parser.Object, args []*parser.Expr, ac aliasCallback, chainStart interface{}) (interface{}, error) {
+	fv, ok := obj.Data.(*funcValue)
+	if !ok {
+		return obj.Data, nil
+	}
+
+	if fv.fun != nil {
+		return cg.EmitFuncDecl(ctx, scope, fv.fun, args, ac, chainStart)
+	}
+
+	switch fv.lit.Type.Primary() {
+	case parser.Option:
+		return cg.EmitOptionBlock(ctx, fv.scope, optionSubtype(ctx, fv.lit.Type), fv.lit.Body, ac)
+	case parser.Group:
+		return cg.EmitGroupBlock(ctx, fv.scope, fv.lit.Body, ac, chainStart)
+	}
+	return cg.EmitBlock(ctx, fv.scope, fv.lit.Type.ObjType, fv.lit.Body.NonEmptyStmts(), ac, chainStart)
+}
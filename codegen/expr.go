@@ -3,7 +3,10 @@ package codegen
 import (
 	"context"
 
+	"github.com/containerd/containerd/platforms"
 	"github.com/moby/buildkit/client/llb"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openllb/hlb/builtin"
 	"github.com/openllb/hlb/parser"
 	"github.com/openllb/hlb/solver"
 	"github.com/pkg/errors"
@@ -30,22 +33,113 @@ func (cg *CodeGen) EmitStringExpr(ctx context.Context, scope *parser.Scope, expr
 	}
 }
 
-func (cg *CodeGen) EmitIntExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr) (int, error) {
+func (cg *CodeGen) EmitStringListExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr) ([]string, error) {
 	switch {
-	case expr.Ident != nil:
+	case expr.Ident != nil, expr.Selector != nil:
+		lc, err := cg.EmitStringListChainStmt(ctx, scope, expr, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return lc([]string{})
+	case expr.ListLit != nil:
+		list := []string{}
+		for _, elem := range expr.ListLit.Elems {
+			str, err := cg.EmitStringExpr(ctx, scope, elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	case expr.FuncLit != nil:
+		return cg.EmitStringListBlock(ctx, scope, expr.FuncLit.Body, nil)
+	default:
+		return nil, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown string list expr")})
+	}
+}
+
+// EmitStringExprs emits the arguments of a variadic string param. Lists of
+// strings are spread into their elements.
+func (cg *CodeGen) EmitStringExprs(ctx context.Context, scope *parser.Scope, args []*parser.Expr) ([]string, error) {
+	var strs []string
+	for _, arg := range args {
+		if cg.isStringListExpr(scope, arg) {
+			list, err := cg.EmitStringListExpr(ctx, scope, arg)
+			if err != nil {
+				return nil, err
+			}
+			strs = append(strs, list...)
+			continue
+		}
+
+		str, err := cg.EmitStringExpr(ctx, scope, arg)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+// isStringListExpr returns whether the expression is statically typed as a
+// list of strings.
+func (cg *CodeGen) isStringListExpr(scope *parser.Scope, expr *parser.Expr) bool {
+	var typ *parser.Type
+	switch {
+	case expr.ListLit != nil:
+		return true
+	case expr.FuncLit != nil:
+		typ = expr.FuncLit.Type
+	case expr.Ident != nil, expr.Selector != nil:
 		obj := scope.Lookup(expr.Name())
 		if obj == nil {
-			return 0, errors.WithStack(ErrCodeGen{expr.IdentNode(), ErrUndefinedReference})
+			_, ok := builtin.Lookup.ByType[parser.ListOf(parser.Str)].Func[expr.Name()]
+			return ok
 		}
 
-		switch obj.Kind {
-		case parser.DeclKind:
-			panic("unimplemented")
-		case parser.ExprKind:
-			return obj.Data.(int), nil
-		default:
-			return 0, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown obj type")})
+		node := obj.Node
+		if _, ok := node.(*parser.ImportDecl); ok && expr.Selector != nil {
+			importScope, ok := obj.Data.(*parser.Scope)
+			if !ok {
+				return false
+			}
+
+			importObj := importScope.Lookup(expr.Selector.Select.Name)
+			if importObj == nil {
+				return false
+			}
+			node = importObj.Node
+		}
+
+		switch n := node.(type) {
+		case *parser.FuncDecl:
+			typ = n.Type
+		case *parser.AliasDecl:
+			typ = n.Func.Type
+		case *parser.Field:
+			// Variadic string fields are bound to the list of their arguments.
+			if n.Variadic != nil && n.Type.ObjType == parser.Str {
+				return true
+			}
+
+			// Function values are typed by the value they return.
+			ret, _ := n.Type.Signature()
+			typ = parser.NewType(ret)
+		}
+	}
+	return typ != nil && typ.ObjType == parser.ListOf(parser.Str)
+}
+
+func (cg *CodeGen) EmitIntExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr) (int, error) {
+	switch {
+	case expr.Ident != nil, expr.Selector != nil:
+		ic, err := cg.EmitIntChainStmt(ctx, scope, expr, nil, nil)
+		if err != nil {
+			return 0, err
 		}
+
+		return ic(0)
 	case expr.BasicLit != nil:
 		switch {
 		case expr.BasicLit.Decimal != nil:
@@ -56,7 +150,7 @@ func (cg *CodeGen) EmitIntExpr(ctx context.Context, scope *parser.Scope, expr *p
 			return 0, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown int basic lit")})
 		}
 	case expr.FuncLit != nil:
-		panic("unimplemented")
+		return cg.EmitIntBlock(ctx, scope, expr.FuncLit.Body, nil)
 	default:
 		return 0, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown int expr")})
 	}
@@ -64,24 +158,17 @@ func (cg *CodeGen) EmitIntExpr(ctx context.Context, scope *parser.Scope, expr *p
 
 func (cg *CodeGen) EmitBoolExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr) (bool, error) {
 	switch {
-	case expr.Ident != nil:
-		obj := scope.Lookup(expr.Name())
-		if obj == nil {
-			return false, errors.WithStack(ErrCodeGen{expr.IdentNode(), ErrUndefinedReference})
+	case expr.Ident != nil, expr.Selector != nil:
+		bc, err := cg.EmitBoolChainStmt(ctx, scope, expr, nil, nil)
+		if err != nil {
+			return false, err
 		}
 
-		switch obj.Kind {
-		case parser.DeclKind:
-			panic("unimplemented")
-		case parser.ExprKind:
-			return obj.Data.(bool), nil
-		default:
-			return false, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown obj type")})
-		}
+		return bc(false)
 	case expr.BasicLit != nil:
-		return *expr.BasicLit.Bool, nil
+		return bool(*expr.BasicLit.Bool), nil
 	case expr.FuncLit != nil:
-		panic("unimplemented")
+		return cg.EmitBoolBlock(ctx, scope, expr.FuncLit.Body, nil)
 	default:
 		return false, errors.WithStack(ErrCodeGen{expr, errors.Errorf("unknown bool expr")})
 	}
@@ -99,6 +186,21 @@ func (cg *CodeGen) MaybeEmitBoolExpr(ctx context.Context, scope *parser.Scope, a
 	return v, nil
 }
 
+// EmitPlatformExpr emits a string expression and parses it as a platform
+// specifier like `linux/arm64`.
+func (cg *CodeGen) EmitPlatformExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr) (specs.Platform, error) {
+	value, err := cg.EmitStringExpr(ctx, scope, expr)
+	if err != nil {
+		return specs.Platform{}, err
+	}
+
+	p, err := platforms.Parse(value)
+	if err != nil {
+		return p, errors.WithStack(ErrCodeGen{expr, err})
+	}
+	return platforms.Normalize(p), nil
+}
+
 func (cg *CodeGen) EmitFilesystemExpr(ctx context.Context, scope *parser.Scope, expr *parser.Expr, ac aliasCallback) (st llb.State, err error) {
 	switch {
 	case expr.Ident != nil, expr.Selector != nil:
@@ -11,13 +11,19 @@ import (
 	"github.com/docker/cli/cli/flags"
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/session/filesync"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/openllb/hlb/solver"
 	"github.com/pkg/errors"
 )
 
+// DefaultPlatform is the platform targets are built for when none are
+// specified.
+var DefaultPlatform = specs.Platform{OS: "linux", Architecture: "amd64"}
+
 type Target struct {
-	Name    string
-	Outputs []Output
+	Name      string
+	Outputs   []Output
+	Platforms []specs.Platform
 }
 
 type Output struct {
@@ -48,6 +54,13 @@ func (cg *CodeGen) outputRequest(ctx context.Context, st llb.State, output Outpu
 		return nil, err
 	}
 
+	if len(cg.platforms) > 1 {
+		switch output.Type {
+		case OutputDockerLoad, OutputDownloadDockerTarball:
+			return nil, errors.WithStack(errors.Errorf("docker exporter does not support multiple platforms"))
+		}
+	}
+
 	switch output.Type {
 	case OutputDockerPush:
 		opts = append(opts, solver.WithPushImage(output.Ref))
@@ -114,7 +127,20 @@ func (cg *CodeGen) outputRequest(ctx context.Context, st llb.State, output Outpu
 		}
 	}
 
-	def, err := st.Marshal(ctx, llb.LinuxAmd64)
+	if len(cg.platforms) > 1 {
+		var pdefs []solver.PlatformDefinition
+		for _, p := range cg.platforms {
+			def, err := st.Marshal(ctx, llb.Platform(p))
+			if err != nil {
+				return nil, err
+			}
+			pdefs = append(pdefs, solver.PlatformDefinition{Platform: p, Def: def})
+		}
+
+		return solver.Single(&solver.Params{Platforms: pdefs, SolveOpts: opts, Session: s}), nil
+	}
+
+	def, err := st.Marshal(ctx, llb.Platform(cg.platform()))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,944 @@
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/openllb/hlb"
+	"github.com/openllb/hlb/codegen"
+	"github.com/openllb/hlb/local"
+	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/solver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// threadID is the only thread, which is the one running codegen.
+	threadID = 1
+
+	contentLengthHeader = "Content-Length:"
+)
+
+var (
+	ErrNotStopped = errors.New("program is not stopped")
+)
+
+type message interface {
+	setSeq(seq int)
+}
+
+// breakpoint is a breakpoint set by the client. Its location is resolved to a
+// codegen breakpoint when the module of the program is available.
+type breakpoint struct {
+	Breakpoint
+	location  string
+	function  bool
+	condition string
+	bp        *codegen.Breakpoint
+}
+
+type Server struct {
+	cln *client.Client
+
+	wmu sync.Mutex
+	w   io.Writer
+	seq int
+
+	mu              sync.Mutex
+	linesStartAt1   bool
+	columnsStartAt1 bool
+	launch          *LaunchRequestArguments
+	configured      bool
+	started         bool
+	cancel          context.CancelFunc
+	mod             *parser.Module
+	breakpoints     []*breakpoint
+	breakpointID    int
+	staticBps       []*codegen.Breakpoint
+	last            *codegen.Snapshot
+	current         *codegen.Snapshot
+	entry           bool
+	stepper         codegen.Stepper
+	resume          chan bool
+	variables       map[int]func(ctx context.Context) ([]Variable, error)
+}
+
+func NewServer(cln *client.Client) *Server {
+	return &Server{
+		cln:             cln,
+		linesStartAt1:   true,
+		columnsStartAt1: true,
+		resume:          make(chan bool, 1),
+		cancel:          func() {},
+	}
+}
+
+// Listen reads requests from r and writes responses and events to w until the
+// client disconnects.
+func (s *Server) Listen(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+
+	br := bufio.NewReader(r)
+	for {
+		dt, err := readMessage(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var req Request
+		err = json.Unmarshal(dt, &req)
+		if err != nil {
+			return err
+		}
+
+		if s.handle(ctx, &req) {
+			return nil
+		}
+	}
+}
+
+// handle responds to a request and returns true if the client disconnected.
+func (s *Server) handle(ctx context.Context, req *Request) bool {
+	log.Printf("request %q", req.Command)
+
+	var (
+		body interface{}
+		err  error
+	)
+	switch req.Command {
+	case "initialize":
+		body, err = s.initializeHandler(req.Arguments)
+	case "launch":
+		body, err = s.launchHandler(ctx, req.Arguments)
+	case "setBreakpoints":
+		body, err = s.setBreakpointsHandler(req.Arguments)
+	case "setFunctionBreakpoints":
+		body, err = s.setFunctionBreakpointsHandler(req.Arguments)
+	case "configurationDone":
+		body, err = s.configurationDoneHandler(ctx)
+	case "threads":
+		body = ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "codegen"}}}
+	case "stackTrace":
+		body, err = s.stackTraceHandler(req.Arguments)
+	case "scopes":
+		body, err = s.scopesHandler(req.Arguments)
+	case "variables":
+		body, err = s.variablesHandler(ctx, req.Arguments)
+	case "evaluate":
+		body, err = s.evaluateHandler(ctx, req.Arguments)
+	case "continue":
+		body, err = s.continueHandler()
+	case "next", "stepIn", "stepOut":
+		err = s.step(req.Command)
+	case "disconnect", "terminate":
+		s.disconnect()
+	default:
+		err = errors.Errorf("unsupported request %q", req.Command)
+	}
+
+	resp := &Response{
+		ProtocolMessage: ProtocolMessage{Type: "response"},
+		RequestSeq:      req.Seq,
+		Success:         err == nil,
+		Command:         req.Command,
+		Body:            body,
+	}
+	if err != nil {
+		log.Printf("request %q failed: %s", req.Command, err)
+		resp.Message = err.Error()
+	}
+	s.send(resp)
+
+	if err != nil {
+		return false
+	}
+
+	// Events that follow a request are sent after its response.
+	switch req.Command {
+	case "initialize":
+		s.sendEvent("initialized", nil)
+	case "continue", "next", "stepIn", "stepOut":
+		s.resume <- true
+	case "disconnect", "terminate":
+		return true
+	}
+	return false
+}
+
+func (s *Server) initializeHandler(raw json.RawMessage) (interface{}, error) {
+	var args InitializeRequestArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if args.LinesStartAt1 != nil {
+		s.linesStartAt1 = *args.LinesStartAt1
+	}
+	if args.ColumnsStartAt1 != nil {
+		s.columnsStartAt1 = *args.ColumnsStartAt1
+	}
+
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsFunctionBreakpoints:      true,
+		SupportsEvaluateForHovers:        true,
+		SupportsConditionalBreakpoints:   true,
+	}, nil
+}
+
+func (s *Server) launchHandler(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args LaunchRequestArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Program == "" {
+		return nil, errors.New("launch requires a program")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.launch = &args
+	s.entry = args.StopOnEntry
+	if !args.StopOnEntry {
+		s.stepper.Continue()
+	}
+	s.start(ctx)
+	return nil, nil
+}
+
+func (s *Server) configurationDoneHandler(ctx context.Context) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configured = true
+	s.start(ctx)
+	return nil, nil
+}
+
+// start runs the program once it has been launched and the client is done
+// setting breakpoints. The lock must be held.
+func (s *Server) start(ctx context.Context) {
+	if s.launch == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.run(ctx, *s.launch)
+}
+
+func (s *Server) run(ctx context.Context, args LaunchRequestArguments) {
+	exitCode := 0
+	err := s.compile(ctx, args)
+	if err != nil && err != codegen.ErrDebugExit {
+		exitCode = 1
+		s.sendEvent("output", OutputEventBody{
+			Category: "stderr",
+			Output:   fmt.Sprintf("%s\n", err),
+		})
+	}
+
+	s.sendEvent("exited", ExitedEventBody{ExitCode: exitCode})
+	s.sendEvent("terminated", nil)
+}
+
+func (s *Server) compile(ctx context.Context, args LaunchRequestArguments) error {
+	// Breakpoints are set on absolute paths, so the program is opened by its
+	// absolute path for the filenames of its nodes to match.
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	cwd := args.Cwd
+	if cwd == "" {
+		cwd = filepath.Dir(program)
+	}
+
+	ctx, err = local.WithCwd(ctx, cwd)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(program)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	names := args.Targets
+	if len(names) == 0 {
+		names = []string{"default"}
+	}
+
+	var targets []codegen.Target
+	for _, name := range names {
+		targets = append(targets, codegen.Target{Name: name})
+	}
+
+	p := solver.NewDebugProgress(ctx)
+	_, err = hlb.Compile(ctx, s.cln, p, targets, f, hlb.WithDebugger(s.debug))
+	p.Release()
+	werr := p.Wait()
+	if err != nil {
+		return err
+	}
+	return werr
+}
+
+// debug is the codegen.Debugger of the program. It takes a snapshot of every
+// step and blocks when the program stops until the client resumes it.
+func (s *Server) debug(ctx context.Context, scope *parser.Scope, node parser.Node, value interface{}) error {
+	s.mu.Lock()
+
+	// Only the last snapshot is kept, as the call stack of a step follows
+	// from the one before it.
+	snapshot := codegen.NewSnapshot(s.last, scope, node, value)
+	s.last = snapshot
+
+	var changed []Breakpoint
+	if mod, ok := node.(*parser.Module); ok && s.mod == nil {
+		s.mod = mod
+		s.staticBps = codegen.FindStaticBreakpoints(mod)
+		for _, b := range s.breakpoints {
+			s.resolveBreakpoint(b)
+			changed = append(changed, b.Breakpoint)
+		}
+	}
+
+	bps := make([]*breakpoint, len(s.breakpoints))
+	copy(bps, s.breakpoints)
+	s.mu.Unlock()
+
+	// Conditions are evaluated without the lock as they may need to solve,
+	// which would block the client's requests in the meantime.
+	hit := s.hitBreakpoints(ctx, bps, snapshot)
+
+	s.mu.Lock()
+	reason := s.stopReason(snapshot, hit)
+	if reason != "" {
+		s.current = snapshot
+		s.variables = make(map[int]func(ctx context.Context) ([]Variable, error))
+	}
+	s.mu.Unlock()
+
+	for _, b := range changed {
+		s.sendEvent("breakpoint", BreakpointEventBody{Reason: "changed", Breakpoint: b})
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	s.sendEvent("stopped", StoppedEventBody{
+		Reason:            reason,
+		ThreadID:          threadID,
+		AllThreadsStopped: true,
+		HitBreakpointIDs:  hit,
+	})
+
+	select {
+	case cont := <-s.resume:
+		if !cont {
+			return codegen.ErrDebugExit
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// hitBreakpoints returns the IDs of the breakpoints that stop at a snapshot.
+func (s *Server) hitBreakpoints(ctx context.Context, bps []*breakpoint, snapshot *codegen.Snapshot) []int {
+	var hit []int
+	for _, b := range bps {
+		if b.bp == nil {
+			continue
+		}
+
+		// Breakpoints with conditions that fail to evaluate stop so the
+		// condition can be fixed.
+		ok, err := b.bp.Hit(ctx, s.cln, snapshot)
+		if err != nil {
+			s.sendEvent("output", OutputEventBody{
+				Category: "stderr",
+				Output:   fmt.Sprintf("breakpoint condition %q: %s\n", b.condition, err),
+			})
+		}
+		if ok || err != nil {
+			hit = append(hit, b.ID)
+		}
+	}
+	return hit
+}
+
+// stopReason returns why the program stops at a snapshot given the IDs of the
+// breakpoints hit, or an empty string if it doesn't stop. The lock must be
+// held.
+func (s *Server) stopReason(snapshot *codegen.Snapshot, hit []int) string {
+	static := false
+	for _, bp := range s.staticBps {
+		if bp.Matches(snapshot.Node) {
+			static = true
+		}
+	}
+
+	_, isFunc := snapshot.Node.(*parser.FuncDecl)
+	switch {
+	case len(hit) > 0 || static:
+		s.stepper.Break()
+		return "breakpoint"
+	case s.entry && isFunc:
+		s.entry = false
+		return "entry"
+	case len(snapshot.Frames) == 0, !s.stepper.Stops(snapshot):
+		return ""
+	default:
+		return "step"
+	}
+}
+
+func (s *Server) continueHandler() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return nil, ErrNotStopped
+	}
+
+	s.stepper.Continue()
+	s.current = nil
+	return ContinueResponseBody{AllThreadsContinued: true}, nil
+}
+
+// step resumes the program until the next step of the current function for
+// next, the next step of any function for stepIn, or the next step of the
+// caller for stepOut.
+func (s *Server) step(command string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return ErrNotStopped
+	}
+
+	switch command {
+	case "next":
+		s.stepper.Next(s.current)
+	case "stepIn":
+		s.stepper.StepIn()
+	case "stepOut":
+		s.stepper.StepOut(s.current)
+	}
+	s.current = nil
+	return nil
+}
+
+func (s *Server) disconnect() {
+	s.mu.Lock()
+	stopped := s.current != nil
+	s.current = nil
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if stopped {
+		s.resume <- false
+	}
+	cancel()
+}
+
+func (s *Server) setBreakpointsHandler(raw json.RawMessage) (interface{}, error) {
+	var args SetBreakpointsArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Breakpoints replace the previous breakpoints of the source.
+	var breakpoints []*breakpoint
+	for _, b := range s.breakpoints {
+		if b.function || b.Source == nil || b.Source.Path != args.Source.Path {
+			breakpoints = append(breakpoints, b)
+		}
+	}
+
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	for _, sbp := range args.Breakpoints {
+		b := s.newBreakpoint(fmt.Sprintf("%s:%d", args.Source.Path, s.fromClientLine(sbp.Line)), false)
+		b.Source = &args.Source
+		b.Line = sbp.Line
+		b.condition = sbp.Condition
+		s.resolveBreakpoint(b)
+
+		breakpoints = append(breakpoints, b)
+		body.Breakpoints = append(body.Breakpoints, b.Breakpoint)
+	}
+	s.breakpoints = breakpoints
+
+	return body, nil
+}
+
+func (s *Server) setFunctionBreakpointsHandler(raw json.RawMessage) (interface{}, error) {
+	var args SetFunctionBreakpointsArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var breakpoints []*breakpoint
+	for _, b := range s.breakpoints {
+		if !b.function {
+			breakpoints = append(breakpoints, b)
+		}
+	}
+
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	for _, fbp := range args.Breakpoints {
+		b := s.newBreakpoint(fbp.Name, true)
+		b.condition = fbp.Condition
+		s.resolveBreakpoint(b)
+
+		breakpoints = append(breakpoints, b)
+		body.Breakpoints = append(body.Breakpoints, b.Breakpoint)
+	}
+	s.breakpoints = breakpoints
+
+	return body, nil
+}
+
+// newBreakpoint returns a breakpoint for a linespec or symbol location. The
+// lock must be held.
+func (s *Server) newBreakpoint(location string, function bool) *breakpoint {
+	s.breakpointID++
+	return &breakpoint{
+		Breakpoint: Breakpoint{ID: s.breakpointID},
+		location:   location,
+		function:   function,
+	}
+}
+
+// resolveBreakpoint resolves the location of a breakpoint in the module of the
+// program, if it has been parsed. The lock must be held.
+func (s *Server) resolveBreakpoint(b *breakpoint) {
+	if s.mod == nil {
+		b.Verified = false
+		b.Message = "breakpoint is resolved when the program starts"
+		return
+	}
+
+	bp, err := codegen.FindBreakpoint(s.mod, s.mod, b.location)
+	if err != nil {
+		b.Verified = false
+		b.Message = err.Error()
+		return
+	}
+
+	bp.Condition = b.condition
+
+	var node parser.Node = bp.Func
+	if bp.Call != nil {
+		node = bp.Call
+	}
+	pos := node.Position()
+
+	b.bp = bp
+	b.Verified = true
+	b.Message = ""
+	b.Source = newSource(pos.Filename)
+	b.Line = s.toClientLine(pos.Line)
+	b.Column = s.toClientColumn(pos.Column)
+}
+
+func (s *Server) stackTraceHandler(raw json.RawMessage) (interface{}, error) {
+	var args StackTraceArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body := StackTraceResponseBody{StackFrames: []StackFrame{}}
+	if s.current == nil {
+		return body, nil
+	}
+
+	frames := s.current.Frames
+	for i := len(frames) - 1 - args.StartFrame; i >= 0; i-- {
+		if args.Levels > 0 && len(body.StackFrames) == args.Levels {
+			break
+		}
+
+		frame := frames[i]
+		pos := frame.Node.Position()
+
+		name := "<module>"
+		if frame.Func != nil {
+			name = fmt.Sprintf("%s%s", frame.Func.Name, frame.Func.Params)
+		}
+
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     len(frames) - i,
+			Name:   name,
+			Source: newSource(pos.Filename),
+			Line:   s.toClientLine(pos.Line),
+			Column: s.toClientColumn(pos.Column),
+		})
+	}
+	body.TotalFrames = len(frames)
+
+	return body, nil
+}
+
+// frameScope returns the scope and function of a stack frame. Frames are
+// numbered from 1 for the innermost frame. The lock must be held.
+func (s *Server) frameScope(frameID int) (*parser.Scope, *parser.FuncDecl, error) {
+	if s.current == nil {
+		return nil, nil, ErrNotStopped
+	}
+
+	frames := s.current.Frames
+	if frameID <= 1 {
+		var fun *parser.FuncDecl
+		if len(frames) > 0 {
+			fun = frames[len(frames)-1].Func
+		}
+		return s.current.Scope, fun, nil
+	}
+
+	if frameID > len(frames) {
+		return nil, nil, errors.Errorf("no frame with id %d", frameID)
+	}
+
+	// The scope of a function holds the arguments it was last called with,
+	// which are those of its frame as functions aren't recursive.
+	fun := frames[len(frames)-frameID].Func
+	return fun.Scope, fun, nil
+}
+
+func (s *Server) scopesHandler(raw json.RawMessage) (interface{}, error) {
+	var args ScopesArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scope, _, err := s.frameScope(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := ScopesResponseBody{
+		Scopes: []Scope{{
+			Name: "Locals",
+			VariablesReference: s.newVariables(func(ctx context.Context) ([]Variable, error) {
+				return s.localVariables(ctx, scope)
+			}),
+		}},
+	}
+
+	// The value being emitted is only known for the innermost frame.
+	if args.FrameID <= 1 && s.current.Value != nil {
+		value := s.current.Value
+		body.Scopes = append(body.Scopes, Scope{
+			Name: "Value",
+			VariablesReference: s.newVariables(func(ctx context.Context) ([]Variable, error) {
+				return s.valueVariables(ctx, value)
+			}),
+		})
+	}
+
+	return body, nil
+}
+
+func (s *Server) variablesHandler(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args VariablesArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	fn, ok := s.variables[args.VariablesReference]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errors.Errorf("no variables with reference %d", args.VariablesReference)
+	}
+
+	variables, err := fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return VariablesResponseBody{Variables: variables}, nil
+}
+
+func (s *Server) evaluateHandler(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var args EvaluateArguments
+	err := unmarshalArguments(raw, &args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	scope, fun, err := s.frameScope(args.FrameID)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	typ, v, err := codegen.Evaluate(ctx, s.cln, scope, fun, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	variable, err := s.newVariable(ctx, args.Expression, string(typ), v)
+	if err != nil {
+		return nil, err
+	}
+
+	return EvaluateResponseBody{
+		Result:             variable.Value,
+		Type:               variable.Type,
+		VariablesReference: variable.VariablesReference,
+	}, nil
+}
+
+// newVariables registers a function returning the variables of a reference
+// until the program resumes, and returns the reference.
+func (s *Server) newVariables(fn func(ctx context.Context) ([]Variable, error)) int {
+	ref := len(s.variables) + 1
+	s.variables[ref] = fn
+	return ref
+}
+
+// localVariables returns the parameters of the function and loop variables in
+// scope.
+func (s *Server) localVariables(ctx context.Context, scope *parser.Scope) ([]Variable, error) {
+	variables := []Variable{}
+	for _, kind := range []parser.ObjKind{parser.FieldKind, parser.ExprKind} {
+		for _, obj := range scope.Defined(kind) {
+			field, ok := obj.Node.(*parser.Field)
+			if !ok {
+				continue
+			}
+
+			variable, err := s.newVariable(ctx, obj.Ident.Name, field.Type.String(), obj.Data)
+			if err != nil {
+				return nil, err
+			}
+			variables = append(variables, variable)
+		}
+	}
+	return variables, nil
+}
+
+// valueVariables returns the variables describing the value of the current
+// step. Filesystems are described like the dir, env, network and security
+// commands of the debugger, along with their LLB.
+func (s *Server) valueVariables(ctx context.Context, value interface{}) ([]Variable, error) {
+	st, ok := value.(llb.State)
+	if !ok {
+		variable, err := s.newVariable(ctx, "value", "", value)
+		if err != nil {
+			return nil, err
+		}
+		return []Variable{variable}, nil
+	}
+
+	dir, err := st.GetDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := st.Env(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := st.GetNetwork(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	security, err := st.GetSecurity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.newVariable(ctx, "llb", string(parser.Filesystem), st)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Variable{
+		{Name: "dir", Value: strconv.Quote(dir)},
+		{Name: "env", Value: fmt.Sprintf("%q", env)},
+		{Name: "network", Value: network.String()},
+		{Name: "security", Value: security.String()},
+		state,
+	}, nil
+}
+
+// newVariable returns a variable for a value emitted by codegen. Filesystems
+// have the LLB ops they marshal to as children.
+func (s *Server) newVariable(ctx context.Context, name, typ string, v interface{}) (Variable, error) {
+	variable := Variable{Name: name, Type: typ}
+
+	st, ok := v.(llb.State)
+	if !ok {
+		var buf bytes.Buffer
+		err := codegen.PrintValue(ctx, &buf, v)
+		if err != nil {
+			return variable, err
+		}
+		variable.Value = strings.TrimSpace(buf.String())
+		return variable, nil
+	}
+
+	ops, err := codegen.DescribeState(ctx, st)
+	if err != nil {
+		return variable, err
+	}
+
+	if len(ops) == 0 {
+		variable.Value = "scratch"
+		return variable, nil
+	}
+
+	variable.Value = fmt.Sprintf("%d ops", len(ops))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	variable.VariablesReference = s.newVariables(func(ctx context.Context) ([]Variable, error) {
+		var variables []Variable
+		for i, op := range ops {
+			variables = append(variables, Variable{
+				Name:  fmt.Sprintf("[%d]", i),
+				Value: op,
+			})
+		}
+		return variables, nil
+	})
+	return variable, nil
+}
+
+func (s *Server) fromClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line + 1
+}
+
+func (s *Server) toClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line - 1
+}
+
+func (s *Server) toClientColumn(column int) int {
+	if s.columnsStartAt1 {
+		return column
+	}
+	return column - 1
+}
+
+func (s *Server) sendEvent(event string, body interface{}) {
+	s.send(&Event{
+		ProtocolMessage: ProtocolMessage{Type: "event"},
+		Event:           event,
+		Body:            body,
+	})
+}
+
+func (s *Server) send(msg message) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.seq++
+	msg.setSeq(s.seq)
+
+	dt, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("failed to marshal message: %s", err)
+		return
+	}
+
+	_, err = fmt.Fprintf(s.w, "%s %d\r\n\r\n%s", contentLengthHeader, len(dt), dt)
+	if err != nil {
+		log.Printf("failed to write message: %s", err)
+	}
+}
+
+// readMessage reads the content of a message, which is preceded by headers
+// like HTTP.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(line, contentLengthHeader) {
+			length, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, contentLengthHeader)))
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid content length")
+			}
+		}
+	}
+
+	if length < 0 {
+		return nil, errors.New("missing content length")
+	}
+
+	dt := make([]byte, length)
+	_, err := io.ReadFull(r, dt)
+	return dt, err
+}
+
+func unmarshalArguments(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func newSource(filename string) *Source {
+	return &Source{
+		Name: filepath.Base(filename),
+		Path: filename,
+	}
+}
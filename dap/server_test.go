@@ -0,0 +1,329 @@
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMessage(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		input    string
+		expected string
+		err      string
+	}
+
+	for _, tc := range []testCase{{
+		"content length",
+		"Content-Length: 2\r\n\r\n{}",
+		"{}",
+		"",
+	}, {
+		"other headers",
+		"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 2\r\n\r\n{}",
+		"{}",
+		"",
+	}, {
+		"missing content length",
+		"Content-Type: application/vscode-jsonrpc\r\n\r\n{}",
+		"",
+		"missing content length",
+	}, {
+		"invalid content length",
+		"Content-Length: two\r\n\r\n{}",
+		"",
+		`invalid content length: strconv.Atoi: parsing "two": invalid syntax`,
+	}, {
+		"truncated content",
+		"Content-Length: 10\r\n\r\n{}",
+		"",
+		"unexpected EOF",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dt, err := readMessage(bufio.NewReader(strings.NewReader(tc.input)))
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(dt))
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	s := NewServer(nil)
+	s.w = &buf
+
+	s.sendEvent("initialized", nil)
+	s.send(&Response{
+		ProtocolMessage: ProtocolMessage{Type: "response"},
+		RequestSeq:      1,
+		Success:         true,
+		Command:         "threads",
+		Body:            ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "codegen"}}},
+	})
+
+	// Messages are framed so that they can be read back one at a time.
+	r := bufio.NewReader(&buf)
+	for _, expected := range []string{
+		`{"seq":1,"type":"event","event":"initialized"}`,
+		`{"seq":2,"type":"response","request_seq":1,"success":true,"command":"threads","body":{"threads":[{"id":1,"name":"codegen"}]}}`,
+	} {
+		dt, err := readMessage(r)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(dt))
+	}
+
+	_, err := readMessage(r)
+	require.Equal(t, io.EOF, err)
+}
+
+// testClient drives a server over pipes, framing the requests it sends and
+// reading the responses and events sent back.
+type testClient struct {
+	t   *testing.T
+	w   io.Writer
+	r   *bufio.Reader
+	seq int
+}
+
+type testMessage struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c *testClient) request(command string, args interface{}) {
+	raw, err := json.Marshal(args)
+	require.NoError(c.t, err)
+
+	c.seq++
+	dt, err := json.Marshal(&Request{
+		ProtocolMessage: ProtocolMessage{Seq: c.seq, Type: "request"},
+		Command:         command,
+		Arguments:       raw,
+	})
+	require.NoError(c.t, err)
+
+	_, err = fmt.Fprintf(c.w, "%s %d\r\n\r\n%s", contentLengthHeader, len(dt), dt)
+	require.NoError(c.t, err)
+}
+
+// expect reads messages until the response to a command or an event of a
+// type, and unmarshals its body into v if it isn't nil. Events are sent by
+// the program as it runs, so messages in between are skipped.
+func (c *testClient) expect(typ, name string, v interface{}) testMessage {
+	for {
+		dt, err := readMessage(c.r)
+		require.NoError(c.t, err)
+
+		var msg testMessage
+		err = json.Unmarshal(dt, &msg)
+		require.NoError(c.t, err)
+
+		if msg.Type != typ || (msg.Command != name && msg.Event != name) {
+			continue
+		}
+
+		if v != nil {
+			err = json.Unmarshal(msg.Body, v)
+			require.NoError(c.t, err)
+		}
+		return msg
+	}
+}
+
+// startSession starts a server over pipes and returns a client of the server
+// that has initialized it and a channel of the error Listen returns.
+func startSession(t *testing.T) (*testClient, <-chan error) {
+	sr, cw := io.Pipe()
+	cr, sw := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(nil).Listen(context.Background(), sr, sw)
+		sw.Close()
+		cw.Close()
+	}()
+
+	c := &testClient{t: t, w: cw, r: bufio.NewReader(cr)}
+
+	c.request("initialize", InitializeRequestArguments{AdapterID: "hlb"})
+	var capabilities Capabilities
+	msg := c.expect("response", "initialize", &capabilities)
+	require.True(t, msg.Success)
+	require.True(t, capabilities.SupportsConfigurationDoneRequest)
+	c.expect("event", "initialized", nil)
+
+	return c, done
+}
+
+// writeProgram writes a program to a temporary directory and returns its
+// path and a function that removes it.
+func writeProgram(t *testing.T, input string) (string, func()) {
+	dir, err := ioutil.TempDir("", "hlb-dap")
+	require.NoError(t, err)
+
+	program := filepath.Join(dir, "build.hlb")
+	err = ioutil.WriteFile(program, []byte(input), 0644)
+	require.NoError(t, err)
+
+	return program, func() { os.RemoveAll(dir) }
+}
+
+const testProgram = `fs default() {
+	base "alpine"
+	env "FOO" "bar"
+}
+
+fs base(string ref) {
+	image ref
+	dir "/src"
+}
+`
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	program, cleanup := writeProgram(t, testProgram)
+	defer cleanup()
+
+	c, done := startSession(t)
+
+	// Breakpoints set before the program starts are resolved once its module
+	// is parsed.
+	c.request("setFunctionBreakpoints", SetFunctionBreakpointsArguments{
+		Breakpoints: []FunctionBreakpoint{{Name: "base"}},
+	})
+	var breakpoints SetBreakpointsResponseBody
+	c.expect("response", "setFunctionBreakpoints", &breakpoints)
+	require.Len(t, breakpoints.Breakpoints, 1)
+	require.False(t, breakpoints.Breakpoints[0].Verified)
+
+	c.request("launch", LaunchRequestArguments{Program: program})
+	msg := c.expect("response", "launch", nil)
+	require.True(t, msg.Success, msg.Message)
+
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone", nil)
+
+	var changed BreakpointEventBody
+	c.expect("event", "breakpoint", &changed)
+	require.True(t, changed.Breakpoint.Verified)
+	require.Equal(t, 6, changed.Breakpoint.Line)
+
+	var stopped StoppedEventBody
+	c.expect("event", "stopped", &stopped)
+	require.Equal(t, "breakpoint", stopped.Reason)
+	require.Equal(t, []int{breakpoints.Breakpoints[0].ID}, stopped.HitBreakpointIDs)
+
+	c.request("stackTrace", StackTraceArguments{ThreadID: threadID})
+	var stackTrace StackTraceResponseBody
+	c.expect("response", "stackTrace", &stackTrace)
+	require.Len(t, stackTrace.StackFrames, 2)
+	require.Equal(t, "base(string ref)", stackTrace.StackFrames[0].Name)
+	require.Equal(t, 6, stackTrace.StackFrames[0].Line)
+	require.Equal(t, "default()", stackTrace.StackFrames[1].Name)
+	require.Equal(t, 2, stackTrace.StackFrames[1].Line)
+
+	c.request("continue", nil)
+	c.expect("response", "continue", nil)
+
+	var exited ExitedEventBody
+	c.expect("event", "exited", &exited)
+	require.Equal(t, 0, exited.ExitCode)
+	c.expect("event", "terminated", nil)
+
+	c.request("unknown", nil)
+	msg = c.expect("response", "unknown", nil)
+	require.False(t, msg.Success)
+	require.Equal(t, `unsupported request "unknown"`, msg.Message)
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect", nil)
+	require.NoError(t, <-done)
+}
+
+func TestServer_ConditionalBreakpoints(t *testing.T) {
+	t.Parallel()
+
+	program, cleanup := writeProgram(t, testProgram)
+	defer cleanup()
+
+	c, done := startSession(t)
+
+	c.request("setFunctionBreakpoints", SetFunctionBreakpointsArguments{
+		Breakpoints: []FunctionBreakpoint{{Name: "base", Condition: `equal ref "busybox"`}},
+	})
+	c.expect("response", "setFunctionBreakpoints", nil)
+
+	// Conditions that fail to evaluate stop so that they can be fixed.
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source: Source{Path: program},
+		Breakpoints: []SourceBreakpoint{
+			{Line: 7, Condition: `equal ref "alpine"`},
+			{Line: 8, Condition: "ref"},
+		},
+	})
+	var breakpoints SetBreakpointsResponseBody
+	c.expect("response", "setBreakpoints", &breakpoints)
+	require.Len(t, breakpoints.Breakpoints, 2)
+
+	c.request("launch", LaunchRequestArguments{Program: program})
+	c.expect("response", "launch", nil)
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone", nil)
+
+	var stopped StoppedEventBody
+	c.expect("event", "stopped", &stopped)
+	require.Equal(t, "breakpoint", stopped.Reason)
+	require.Equal(t, []int{breakpoints.Breakpoints[0].ID}, stopped.HitBreakpointIDs)
+
+	c.request("evaluate", EvaluateArguments{Expression: "ref"})
+	var evaluated EvaluateResponseBody
+	c.expect("response", "evaluate", &evaluated)
+	require.Equal(t, `"alpine"`, evaluated.Result)
+
+	c.request("continue", nil)
+	c.expect("response", "continue", nil)
+
+	var output OutputEventBody
+	c.expect("event", "output", &output)
+	require.Equal(t, "stderr", output.Category)
+	require.Equal(t, "breakpoint condition \"ref\": condition must be bool, got string\n", output.Output)
+
+	c.expect("event", "stopped", &stopped)
+	require.Equal(t, []int{breakpoints.Breakpoints[1].ID}, stopped.HitBreakpointIDs)
+
+	c.request("continue", nil)
+	c.expect("response", "continue", nil)
+
+	var exited ExitedEventBody
+	c.expect("event", "exited", &exited)
+	require.Equal(t, 0, exited.ExitCode)
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect", nil)
+	require.NoError(t, <-done)
+}
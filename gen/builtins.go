@@ -24,6 +24,7 @@ type BuiltinData struct {
 type ParsedFunc struct {
 	Name   string
 	Params []*parser.Field
+	Doc    string
 }
 
 func GenerateBuiltins(r io.Reader) ([]byte, error) {
@@ -39,10 +40,21 @@ func GenerateBuiltins(r io.Reader) ([]byte, error) {
 			continue
 		}
 
+		group, err := parseDoc(fun)
+		if err != nil {
+			return nil, err
+		}
+
+		var doc string
+		if group != nil {
+			doc = strings.TrimSpace(group.Doc)
+		}
+
 		typ := fun.Type.ObjType
 		funcsByType[typ] = append(funcsByType[typ], ParsedFunc{
 			Name:   fun.Name.Name,
 			Params: fun.Params.List,
+			Doc:    doc,
 		})
 	}
 
@@ -82,6 +94,22 @@ var tmplFunctions = template.FuncMap{
 			return template.HTML(strconv.Quote(string(typ)))
 		}
 	},
+	"quote": func(s string) template.HTML {
+		return template.HTML(strconv.Quote(s))
+	},
+	"expr": func(expr *parser.Expr) (template.HTML, error) {
+		if expr.BasicLit != nil {
+			switch {
+			case expr.BasicLit.Str != nil:
+				return template.HTML(fmt.Sprintf("parser.NewStringExpr(%s)", strconv.Quote(expr.BasicLit.Str.Unquoted()))), nil
+			case expr.BasicLit.Decimal != nil:
+				return template.HTML(fmt.Sprintf("parser.NewDecimalExpr(%d)", *expr.BasicLit.Decimal)), nil
+			case expr.BasicLit.Bool != nil:
+				return template.HTML(fmt.Sprintf("parser.NewBoolExpr(%t)", *expr.BasicLit.Bool)), nil
+			}
+		}
+		return "", fmt.Errorf("unsupported default value %s", expr)
+	},
 }
 
 var referenceTmpl = template.Must(template.New("reference").Funcs(tmplFunctions).Parse(`
@@ -101,6 +129,7 @@ type LookupByType struct {
 
 type FuncLookup struct {
 	Params []*parser.Field
+	Doc    string
 }
 
 var (
@@ -110,9 +139,10 @@ var (
 				Func: map[string]FuncLookup{
 					{{range $i, $func := $funcs}}"{{$func.Name}}": FuncLookup{
 						Params: []*parser.Field{
-							{{range $i, $param := $func.Params}}parser.NewField({{objType $param.Type.ObjType}}, "{{$param.Name}}", {{if $param.Variadic}}true{{else}}false{{end}}),
+							{{range $i, $param := $func.Params}}{{if $param.Default}}parser.NewDefaultField({{objType $param.Type.ObjType}}, "{{$param.Name}}", {{expr $param.Default.Value}}),{{else}}parser.NewField({{objType $param.Type.ObjType}}, "{{$param.Name}}", {{if $param.Variadic}}true{{else}}false{{end}}),{{end}}
 							{{end}}
 						},
+						Doc: {{quote $func.Doc}},
 					},
 					{{end}}
 				},
@@ -59,17 +59,9 @@ func GenerateDocumentation(r io.Reader) (*Documentation, error) {
 			fields []Field
 		)
 
-		if fun.Doc != nil {
-			var commentBlock []string
-			for _, comment := range fun.Doc.List {
-				text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "#"))
-				commentBlock = append(commentBlock, fmt.Sprintf("%s\n", text))
-			}
-
-			group, err = doxygen.Parse(strings.NewReader(strings.Join(commentBlock, "")))
-			if err != nil {
-				return nil, err
-			}
+		group, err = parseDoc(fun)
+		if err != nil {
+			return nil, err
 		}
 
 		if fun.Type != nil {
@@ -145,7 +137,7 @@ func GenerateDocumentation(r io.Reader) (*Documentation, error) {
 
 	var doc Documentation
 
-	for _, typ := range []string{"fs", "string"} {
+	for _, typ := range []string{"fs", "string", "[]string", "int", "bool"} {
 		funcs := funcsByType[typ]
 		for _, fun := range funcs {
 			fun := fun
@@ -169,3 +161,18 @@ func GenerateDocumentation(r io.Reader) (*Documentation, error) {
 
 	return &doc, nil
 }
+
+// parseDoc parses the doxygen comment block of a function, if any.
+func parseDoc(fun *parser.FuncDecl) (*doxygen.Group, error) {
+	if fun.Doc == nil {
+		return nil, nil
+	}
+
+	var commentBlock []string
+	for _, comment := range fun.Doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "#"))
+		commentBlock = append(commentBlock, fmt.Sprintf("%s\n", text))
+	}
+
+	return doxygen.Parse(strings.NewReader(strings.Join(commentBlock, "")))
+}
@@ -4,6 +4,7 @@ go 1.12
 
 require (
 	github.com/alecthomas/participle v0.4.2-0.20191230055107-1fbf95471489
+	github.com/containerd/containerd v1.4.0-0
 	github.com/creachadair/jrpc2 v0.8.1
 	github.com/docker/buildx v0.3.2-0.20200410204309-f4ac640252b8
 	github.com/docker/cli v1.14.0-0.20190523191156-ab688a9a79a1
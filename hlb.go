@@ -25,7 +25,49 @@ func DefaultParseOpts() []ParseOption {
 	return opts
 }
 
-func Compile(ctx context.Context, cln *client.Client, p solver.Progress, targets []codegen.Target, r io.Reader) (solver.Request, error) {
+type CompileOption func(*CompileInfo) error
+
+type CompileInfo struct {
+	Debugger    codegen.Debugger
+	DebugScript io.Reader
+	Trace       io.Writer
+}
+
+// WithDebugger sets the debugger invoked for every step of codegen instead of
+// the debugger reading commands from stdin.
+func WithDebugger(dbgr codegen.Debugger) CompileOption {
+	return func(i *CompileInfo) error {
+		i.Debugger = dbgr
+		return nil
+	}
+}
+
+// WithDebugScript sets the script the debugger reads commands from instead of
+// stdin.
+func WithDebugScript(r io.Reader) CompileOption {
+	return func(i *CompileInfo) error {
+		i.DebugScript = r
+		return nil
+	}
+}
+
+// WithTrace prints every step of codegen to w.
+func WithTrace(w io.Writer) CompileOption {
+	return func(i *CompileInfo) error {
+		i.Trace = w
+		return nil
+	}
+}
+
+func Compile(ctx context.Context, cln *client.Client, p solver.Progress, targets []codegen.Target, r io.Reader, opts ...CompileOption) (solver.Request, error) {
+	var info CompileInfo
+	for _, opt := range opts {
+		err := opt(&info)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	mod, ib, err := Parse(r, DefaultParseOpts()...)
 	if err != nil {
 		return nil, err
@@ -69,12 +111,21 @@ func Compile(ctx context.Context, cln *client.Client, p solver.Progress, targets
 		names = append(names, target.Name)
 	}
 
-	var opts []codegen.CodeGenOption
-	if mw != nil {
-		opts = append(opts, codegen.WithMultiWriter(mw), codegen.WithClient(cln))
-	} else {
+	var cgOpts []codegen.CodeGenOption
+	switch {
+	case info.Debugger != nil:
+		cgOpts = append(cgOpts, codegen.WithDebugger(info.Debugger), codegen.WithClient(cln))
+	case info.DebugScript != nil:
+		cgOpts = append(cgOpts, codegen.WithDebugger(codegen.NewScriptDebugger(cln, os.Stderr, info.DebugScript, ibs)))
+	case mw != nil:
+		cgOpts = append(cgOpts, codegen.WithMultiWriter(mw), codegen.WithClient(cln))
+	default:
 		r := bufio.NewReader(os.Stdin)
-		opts = append(opts, codegen.WithDebugger(codegen.NewDebugger(cln, os.Stderr, r, ibs)))
+		cgOpts = append(cgOpts, codegen.WithDebugger(codegen.NewDebugger(cln, os.Stderr, r, ibs)))
+	}
+
+	if info.Trace != nil {
+		cgOpts = append(cgOpts, codegen.WithTrace(info.Trace))
 	}
 
 	var request solver.Request
@@ -83,7 +134,7 @@ func Compile(ctx context.Context, cln *client.Client, p solver.Progress, targets
 	p.Write("codegen", fmt.Sprintf("compiling %s", names), func(ctx context.Context) error {
 		defer close(done)
 
-		cg, err := codegen.New(opts...)
+		cg, err := codegen.New(cgOpts...)
 		if err != nil {
 			return err
 		}
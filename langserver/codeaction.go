@@ -0,0 +1,341 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/report"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentCodeActionHandler(ctx context.Context, params lsp.CodeActionParams) ([]CodeAction, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document code action %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []CodeAction{}
+	if td.Module == nil || td.Module.Scope == nil {
+		return actions, nil
+	}
+
+	for _, err := range semanticErrors(td.Err) {
+		var ident *parser.Ident
+		switch e := err.(type) {
+		case checker.ErrIdentNotDefined:
+			ident = e.Ident
+		case checker.ErrIdentUndefined:
+			ident = e.Ident
+		default:
+			continue
+		}
+
+		if ident == nil || !isRangeOverlapping(newRangeFromNode(td.Text, ident), params.Range) {
+			continue
+		}
+
+		diagnostic := newDiagnosticFromError(td, err)
+		actions = append(actions, undefinedIdentActions(td, ident, diagnostic)...)
+	}
+
+	if action := extractOptionAction(td, params.Range); action != nil {
+		actions = append(actions, *action)
+	}
+
+	return actions, nil
+}
+
+// semanticErrors returns the errors found by the checker.
+func semanticErrors(err error) []error {
+	switch e := err.(type) {
+	case nil, report.Error:
+		return nil
+	case checker.ErrSemantic:
+		return e.Errs
+	default:
+		return []error{err}
+	}
+}
+
+// undefinedIdentActions returns quick fixes for an identifier that isn't
+// defined: replacing it with the closest name in scope, or importing the
+// module it selects from.
+func undefinedIdentActions(td TextDocument, ident *parser.Ident, diagnostic lsp.Diagnostic) []CodeAction {
+	var actions []CodeAction
+	uri := td.Identifier.URI
+
+	typ, fun := blockTypeAt(td, newPosition(td.Text, ident.Position()))
+
+	scope := td.Module.Scope
+	if fun != nil && fun.Scope != nil {
+		scope = fun.Scope
+	}
+
+	var names []string
+	for name := range builtin.Lookup.ByType[typ].Func {
+		names = append(names, name)
+	}
+	for _, kind := range []parser.ObjKind{parser.DeclKind, parser.FieldKind} {
+		for _, obj := range scope.Defined(kind) {
+			names = append(names, obj.Ident.Name)
+		}
+	}
+	sort.Strings(names)
+
+	if suggestion, ok := report.Suggest(names, ident.Name); ok {
+		actions = append(actions, CodeAction{
+			Title:       fmt.Sprintf("Change to %s", suggestion),
+			Kind:        lsp.CAKQuickFix,
+			Diagnostics: []lsp.Diagnostic{diagnostic},
+			IsPreferred: true,
+			Edit: newWorkspaceEdit(uri, lsp.TextEdit{
+				Range:   newRangeFromNode(td.Text, ident),
+				NewText: suggestion,
+			}),
+		})
+	}
+
+	if !isSelectorIdent(td.Module, ident) {
+		return actions
+	}
+
+	// Only offer to import modules that exist next to the document.
+	filename := fmt.Sprintf("%s.hlb", ident.Name)
+	dir := filepath.Dir(strings.TrimPrefix(string(uri), "file://"))
+	if _, err := os.Stat(filepath.Join(dir, filename)); err != nil {
+		return actions
+	}
+
+	decl := &parser.ImportDecl{
+		Import:     &parser.Import{Keyword: "import"},
+		Ident:      ident,
+		ImportPath: &parser.ImportPath{Path: parser.QuotedString(fmt.Sprintf("./%s", filename))},
+	}
+
+	actions = append(actions, CodeAction{
+		Title:       fmt.Sprintf("Add import %s", ident.Name),
+		Kind:        lsp.CAKQuickFix,
+		Diagnostics: []lsp.Diagnostic{diagnostic},
+		Edit:        newWorkspaceEdit(uri, newImportEdit(td, decl)),
+	})
+
+	return actions
+}
+
+// isSelectorIdent returns true if ident is the module of a selector.
+func isSelectorIdent(mod *parser.Module, ident *parser.Ident) bool {
+	found := false
+	parser.Inspect(mod, func(node parser.Node) bool {
+		if node == nil || found {
+			return false
+		}
+
+		if n, ok := node.(*parser.Selector); ok && n.Ident == ident {
+			found = true
+		}
+		return true
+	})
+	return found
+}
+
+// newImportEdit returns an edit inserting an import declaration after the
+// last import, or before the first declaration if there are no imports.
+func newImportEdit(td TextDocument, decl *parser.ImportDecl) lsp.TextEdit {
+	var (
+		last  *parser.ImportDecl
+		first parser.Node
+	)
+	for _, d := range td.Module.Decls {
+		switch {
+		case d.Import != nil:
+			last = d.Import
+		case first == nil && (d.Export != nil || d.Func != nil || d.Doc != nil):
+			first = d
+		}
+	}
+
+	switch {
+	case last != nil:
+		pos := newPosition(td.Text, last.End())
+		return lsp.TextEdit{
+			Range:   lsp.Range{Start: pos, End: pos},
+			NewText: fmt.Sprintf("\n%s", decl),
+		}
+	case first != nil:
+		pos := lsp.Position{Line: first.Position().Line - 1}
+		return lsp.TextEdit{
+			Range:   lsp.Range{Start: pos, End: pos},
+			NewText: fmt.Sprintf("%s\n\n", decl),
+		}
+	default:
+		return lsp.TextEdit{
+			NewText: fmt.Sprintf("%s\n", decl),
+		}
+	}
+}
+
+// extractOptionAction returns a refactoring that converts the inline option
+// block of the call in rng into a named option function declared after the
+// enclosing function. Parameters and loop variables of the enclosing function
+// used by the block become parameters of the option function, which is then
+// called with them from an option block in place of the inline one.
+func extractOptionAction(td TextDocument, rng lsp.Range) *CodeAction {
+	var (
+		fun  *parser.FuncDecl
+		call *parser.CallStmt
+	)
+
+	parser.Inspect(td.Module, func(node parser.Node) bool {
+		if node == nil || !isRangeOverlapping(newRangeFromNode(td.Text, node), rng) {
+			return false
+		}
+
+		switch n := node.(type) {
+		case *parser.FuncDecl:
+			fun = n
+		case *parser.CallStmt:
+			if n.Func == nil || n.Func.Ident == nil || n.WithOpt == nil || n.WithOpt.Expr == nil {
+				break
+			}
+
+			lit := n.WithOpt.Expr.FuncLit
+			if lit != nil && lit.Type != nil && lit.Type.ObjType == parser.Option {
+				call = n
+			}
+		}
+		return true
+	})
+
+	if fun == nil || call == nil {
+		return nil
+	}
+
+	lit := call.WithOpt.Expr.FuncLit
+	fields, ok := localFields(td.Module, lit)
+	if !ok {
+		return nil
+	}
+
+	var (
+		params []*parser.Field
+		args   []*parser.Expr
+	)
+	for _, field := range fields {
+		params = append(params, parser.NewField(field.Type.ObjType, field.Name.Name, field.Variadic != nil))
+		args = append(args, parser.NewIdentExpr(field.Name.Name))
+	}
+
+	name := uniqueName(td.Module.Scope, fmt.Sprintf("%sOption", call.Func.Ident))
+	typ := parser.ObjType(fmt.Sprintf("%s::%s", parser.Option, call.Func.Ident))
+	decl := parser.NewFuncDecl(typ, name, params, lit.Body.List...)
+
+	// An option function with parameters cannot follow `with` directly, so
+	// the body of the block is replaced by a call to it instead.
+	edit := lsp.TextEdit{
+		Range:   newRangeFromNode(td.Text, lit),
+		NewText: name,
+	}
+	if len(args) > 0 {
+		indent := lineIndent(td.Text, call.Position().Offset)
+		edit = lsp.TextEdit{
+			Range: lsp.Range{
+				Start: newPosition(td.Text, lit.Body.OpenBrace.End()),
+				End:   newPosition(td.Text, lit.Body.CloseBrace.Position()),
+			},
+			NewText: fmt.Sprintf("\n%s\t%s\n%s", indent, parser.NewCallStmt(name, args, nil, nil), indent),
+		}
+	}
+
+	end := newPosition(td.Text, fun.End())
+	return &CodeAction{
+		Title: fmt.Sprintf("Extract option block into %s", name),
+		Kind:  lsp.CAKRefactorExtract,
+		Edit: newWorkspaceEdit(td.Identifier.URI,
+			edit,
+			lsp.TextEdit{
+				Range:   lsp.Range{Start: end, End: end},
+				NewText: fmt.Sprintf("\n\n%s", decl),
+			},
+		),
+	}
+}
+
+// localFields returns the parameters and loop variables declared outside of
+// lit that are used in it, in the order they are declared. It returns false if
+// one of them is variadic and cannot be passed on as a single argument.
+func localFields(mod *parser.Module, lit *parser.FuncLit) ([]*parser.Field, bool) {
+	var (
+		fields []*parser.Field
+		seen   = make(map[*parser.Object]struct{})
+	)
+
+	for _, ref := range resolveSymbols(mod) {
+		// Named args resolve to the params of the function called, which
+		// don't have a scope.
+		if ref.Scope == nil || ref.Obj.Kind != parser.FieldKind || !isNodeWithinNode(ref.Ident, lit) || isNodeWithinNode(ref.Obj.Ident, lit) {
+			continue
+		}
+
+		field, ok := ref.Obj.Node.(*parser.Field)
+		if !ok {
+			continue
+		}
+
+		if _, ok := seen[ref.Obj]; ok {
+			continue
+		}
+		seen[ref.Obj] = struct{}{}
+
+		// Only variadic strings are bound to a list that can be passed on.
+		if field.Variadic != nil && field.Type.ObjType != parser.Str {
+			return nil, false
+		}
+		fields = append(fields, field)
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Position().Offset < fields[j].Position().Offset
+	})
+	return fields, true
+}
+
+// lineIndent returns the whitespace indenting the line of a byte offset.
+func lineIndent(text string, offset int) string {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	line := text[start:]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// isNodeWithinNode returns true if node is within the bytes of parent.
+func isNodeWithinNode(node, parent parser.Node) bool {
+	return node.Position().Offset >= parent.Position().Offset && node.End().Offset <= parent.End().Offset
+}
+
+// uniqueName returns name, or name suffixed with a number if it is already
+// declared in scope.
+func uniqueName(scope *parser.Scope, name string) string {
+	unique := name
+	for i := 2; scope.Lookup(unique) != nil; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	return unique
+}
+
+func newWorkspaceEdit(uri lsp.DocumentURI, edits ...lsp.TextEdit) *lsp.WorkspaceEdit {
+	return &lsp.WorkspaceEdit{
+		Changes: map[string][]lsp.TextEdit{
+			string(uri): edits,
+		},
+	}
+}
@@ -0,0 +1,220 @@
+package langserver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeActions(t *testing.T) {
+	t.Parallel()
+
+	// Imports are only offered for modules next to the document.
+	dir, err := ioutil.TempDir("", "hlb-langserver")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "util.hlb"), []byte("export build\n\nfs build() {\n\tscratch\n}\n"), 0644)
+	require.NoError(t, err)
+
+	uri := lsp.DocumentURI("file://" + filepath.Join(dir, "build.hlb"))
+
+	type testCase struct {
+		name     string
+		input    string
+		rng      lsp.Range
+		titles   []string
+		expected string
+	}
+
+	for _, tc := range []testCase{{
+		"misspelled builtin",
+		"fs default() {\n\timag \"alpine\"\n}\n",
+		lsp.Range{Start: lsp.Position{Line: 1, Character: 2}, End: lsp.Position{Line: 1, Character: 2}},
+		[]string{"Change to image"},
+		"fs default() {\n\timage \"alpine\"\n}\n",
+	}, {
+		"misspelled function",
+		"fs default() {\n\tbiuld\n}\n\nfs build() {\n\tscratch\n}\n",
+		lsp.Range{Start: lsp.Position{Line: 1, Character: 2}, End: lsp.Position{Line: 1, Character: 2}},
+		[]string{"Change to build"},
+		"fs default() {\n\tbuild\n}\n\nfs build() {\n\tscratch\n}\n",
+	}, {
+		"missing import",
+		"fs default() {\n\tutil.build\n}\n",
+		lsp.Range{Start: lsp.Position{Line: 1, Character: 2}, End: lsp.Position{Line: 1, Character: 2}},
+		[]string{"Add import util"},
+		"import util \"./util.hlb\"\n\nfs default() {\n\tutil.build\n}\n",
+	}, {
+		"missing import after imports",
+		"import other \"./other.hlb\"\n\nfs default() {\n\tutil.build\n}\n",
+		lsp.Range{Start: lsp.Position{Line: 3, Character: 2}, End: lsp.Position{Line: 3, Character: 2}},
+		[]string{"Add import util"},
+		"import other \"./other.hlb\"\nimport util \"./util.hlb\"\n\nfs default() {\n\tutil.build\n}\n",
+	}, {
+		"outside of range",
+		"fs default() {\n\timag \"alpine\"\n}\n",
+		lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 2}},
+		nil,
+		"",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, tc.input)
+
+			actions, err := ls.textDocumentCodeActionHandler(context.Background(), lsp.CodeActionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Range:        tc.rng,
+			})
+			require.NoError(t, err)
+
+			var titles []string
+			for _, action := range actions {
+				titles = append(titles, action.Title)
+			}
+			require.Equal(t, tc.titles, titles)
+
+			if len(actions) > 0 {
+				text := applyTextEdits(tc.input, actions[0].Edit.Changes[string(uri)])
+				require.Equal(t, tc.expected, text)
+			}
+		})
+	}
+}
+
+func TestExtractOptionAction(t *testing.T) {
+	t.Parallel()
+
+	uri := lsp.DocumentURI("file:///build.hlb")
+
+	type testCase struct {
+		name     string
+		input    string
+		rng      lsp.Range
+		expected string
+	}
+
+	for _, tc := range []testCase{{
+		"no params",
+		`fs default() {
+	image "alpine"
+	run "echo hi" with option {
+		dir "/src"
+	}
+}
+`,
+		lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 2}},
+		`fs default() {
+	image "alpine"
+	run "echo hi" with runOption
+}
+
+option::run runOption() {
+	dir "/src"
+}
+`,
+	}, {
+		"params",
+		`fs default(string src, fs input, string unused) {
+	image "alpine"
+	run "echo hi" with option {
+		mount input src
+		dir src
+	}
+}
+`,
+		lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 2}},
+		`fs default(string src, fs input, string unused) {
+	image "alpine"
+	run "echo hi" with option {
+		runOption src input
+	}
+}
+
+option::run runOption(string src, fs input) {
+	mount input src
+	dir src
+}
+`,
+	}, {
+		"variadic string param",
+		`fs default(variadic string dirs) {
+	image "alpine"
+	run "echo hi" with option {
+		mkdirs dirs
+	}
+}
+
+option::run mkdirs(variadic string dirs) {
+	dir "/"
+}
+`,
+		lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 2}},
+		`fs default(variadic string dirs) {
+	image "alpine"
+	run "echo hi" with option {
+		runOption dirs
+	}
+}
+
+option::run runOption(variadic string dirs) {
+	mkdirs dirs
+}
+
+option::run mkdirs(variadic string dirs) {
+	dir "/"
+}
+`,
+	}, {
+		"variadic fs param",
+		`fs default(variadic fs inputs) {
+	image "alpine"
+	run "echo hi" with option {
+		mount inputs "/in"
+	}
+}
+`,
+		lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 2}},
+		"",
+	}, {
+		"no option block",
+		`fs default() {
+	image "alpine"
+}
+`,
+		lsp.Range{Start: lsp.Position{Line: 1, Character: 2}, End: lsp.Position{Line: 1, Character: 2}},
+		"",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			td := NewTextDocument(uri, tc.input)
+
+			action := extractOptionAction(td, tc.rng)
+			if tc.expected == "" {
+				require.Nil(t, action)
+				return
+			}
+			require.NotNil(t, action)
+
+			text := applyTextEdits(td.Text, action.Edit.Changes[string(uri)])
+			require.Equal(t, tc.expected, text)
+			require.NoError(t, NewTextDocument(uri, text).Err)
+		})
+	}
+}
+
+// applyTextEdits applies edits that don't overlap to text, from the last to
+// the first so that the ranges of the others stay valid.
+func applyTextEdits(text string, edits []lsp.TextEdit) string {
+	for i := len(edits) - 1; i >= 0; i-- {
+		rng := edits[i].Range
+		text = applyContentChange(text, lsp.TextDocumentContentChangeEvent{Range: &rng, Text: edits[i].NewText})
+	}
+	return text
+}
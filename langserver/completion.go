@@ -0,0 +1,274 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+var (
+	// selectorPrefix matches an incomplete selector right before the cursor.
+	selectorPrefix = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)\.[a-zA-Z0-9_]*$`)
+)
+
+func (ls *LangServer) textDocumentCompletionHandler(ctx context.Context, params lsp.CompletionParams) (*lsp.CompletionList, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document completion %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &lsp.CompletionList{Items: []lsp.CompletionItem{}}
+	pos := params.Position
+	offset := offsetAt(td.Text, pos)
+
+	m := selectorPrefix.FindStringSubmatch(td.Text[:offset])
+	if m != nil {
+		// An incomplete selector is a syntax error, so parse the document
+		// without it to be able to resolve the import.
+		start := offset - len(m[0]) + len(m[1])
+		std := NewTextDocument(uri, td.Text[:start]+td.Text[offset:])
+		if std.Module == nil || std.Module.Scope == nil {
+			return list, nil
+		}
+
+		decl := findImportDecl(std.Module, m[1])
+		if decl == nil {
+			return list, nil
+		}
+
+		importTD, err := ls.importTextDocument(ctx, uri, std.Module, decl)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Items = exportedCompletionItems(importTD.Module)
+		return list, nil
+	}
+
+	if td.Module == nil {
+		return list, nil
+	}
+
+	typ, fun := blockTypeAt(td, pos)
+	if typ == "" {
+		return list, nil
+	}
+
+	list.Items = blockCompletionItems(td.Module, typ, fun)
+	return list, nil
+}
+
+// blockTypeAt returns the type of the innermost block containing pos and the
+// function declaration it belongs to. Blocks of `with option { ... }` are
+// typed by the call they are options for, e.g. `option::run`.
+func blockTypeAt(td TextDocument, pos lsp.Position) (parser.ObjType, *parser.FuncDecl) {
+	var (
+		typ    parser.ObjType
+		fun    *parser.FuncDecl
+		optLit *parser.FuncLit
+		optTyp parser.ObjType
+	)
+
+	parser.Inspect(td.Module, func(node parser.Node) bool {
+		if node == nil || !isPositionWithinNode(td.Text, pos, node) {
+			return false
+		}
+
+		switch n := node.(type) {
+		case *parser.FuncDecl:
+			if n.Type == nil || n.Body == nil || !isPositionWithinNode(td.Text, pos, n.Body) {
+				return false
+			}
+			typ, fun = n.Type.ObjType, n
+		case *parser.CallStmt:
+			if n.Func == nil || n.WithOpt == nil || n.WithOpt.Expr == nil {
+				break
+			}
+
+			lit := n.WithOpt.Expr.FuncLit
+			if lit != nil && lit.Type != nil && lit.Type.ObjType == parser.Option {
+				optLit = lit
+				optTyp = parser.ObjType(fmt.Sprintf("%s::%s", parser.Option, n.Func.Name()))
+			}
+		case *parser.FuncLit:
+			if n.Type == nil || n.Body == nil || !isPositionWithinNode(td.Text, pos, n.Body) {
+				return false
+			}
+
+			if n == optLit {
+				typ = optTyp
+			} else {
+				typ = n.Type.ObjType
+			}
+		}
+		return true
+	})
+
+	return typ, fun
+}
+
+// blockCompletionItems returns the builtins, functions, parameters and
+// imports that may be called in a block of the given type.
+func blockCompletionItems(mod *parser.Module, typ parser.ObjType, fun *parser.FuncDecl) []lsp.CompletionItem {
+	items := []lsp.CompletionItem{}
+
+	if fun != nil && fun.Params != nil {
+		for _, field := range fun.Params.List {
+			if field.Type == nil || field.Name == nil {
+				continue
+			}
+
+			// Function values are completed by the value they return.
+			fieldTyp, _ := field.Type.Signature()
+
+			if fieldTyp != typ {
+				continue
+			}
+
+			items = append(items, lsp.CompletionItem{
+				Label:  field.Name.Name,
+				Kind:   lsp.CIKVariable,
+				Detail: field.String(),
+			})
+		}
+	}
+
+	if lookupByType, ok := builtin.Lookup.ByType[typ]; ok {
+		var names []string
+		for name := range lookupByType.Func {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			lookup := lookupByType.Func[name]
+			items = append(items, newFuncCompletionItem(typ, name, lookup.Params, lookup.Doc))
+		}
+	}
+
+	for _, decl := range mod.Decls {
+		switch {
+		case decl.Func != nil:
+			fun := decl.Func
+			if fun.Type == nil || fun.Name == nil || fun.Type.ObjType != typ {
+				continue
+			}
+
+			var params []*parser.Field
+			if fun.Params != nil {
+				params = fun.Params.List
+			}
+
+			items = append(items, newFuncCompletionItem(typ, fun.Name.Name, params, docString(fun.Doc)))
+		case decl.Import != nil:
+			if decl.Import.Ident == nil {
+				continue
+			}
+
+			items = append(items, lsp.CompletionItem{
+				Label:  decl.Import.Ident.Name,
+				Kind:   lsp.CIKModule,
+				Detail: fmt.Sprintf("import %s", decl.Import.Ident),
+			})
+		}
+	}
+
+	return items
+}
+
+// exportedCompletionItems returns the functions exported by an imported
+// module.
+func exportedCompletionItems(mod *parser.Module) []lsp.CompletionItem {
+	items := []lsp.CompletionItem{}
+	if mod == nil {
+		return items
+	}
+
+	exported := make(map[string]struct{})
+	for _, decl := range mod.Decls {
+		if decl.Export != nil && decl.Export.Ident != nil {
+			exported[decl.Export.Ident.Name] = struct{}{}
+		}
+	}
+
+	for _, decl := range mod.Decls {
+		fun := decl.Func
+		if fun == nil || fun.Type == nil || fun.Name == nil {
+			continue
+		}
+
+		if _, ok := exported[fun.Name.Name]; !ok {
+			continue
+		}
+
+		var params []*parser.Field
+		if fun.Params != nil {
+			params = fun.Params.List
+		}
+
+		items = append(items, newFuncCompletionItem(fun.Type.ObjType, fun.Name.Name, params, docString(fun.Doc)))
+	}
+
+	return items
+}
+
+func newFuncCompletionItem(typ parser.ObjType, name string, params []*parser.Field, doc string) lsp.CompletionItem {
+	return lsp.CompletionItem{
+		Label:            name,
+		Kind:             lsp.CIKFunction,
+		Detail:           fmt.Sprintf("%s %s%s", typ, name, parser.NewFieldList(params...)),
+		Documentation:    doc,
+		InsertText:       newSnippet(name, params),
+		InsertTextFormat: lsp.ITFSnippet,
+	}
+}
+
+// newSnippet returns a snippet calling a function with placeholders for its
+// required parameters.
+func newSnippet(name string, params []*parser.Field) string {
+	snippet := []string{name}
+	for _, param := range params {
+		if param.Variadic != nil || param.Default != nil {
+			continue
+		}
+
+		placeholder := fmt.Sprintf("${%d:%s}", len(snippet), param.Name)
+		if param.Type.ObjType == parser.Str {
+			placeholder = fmt.Sprintf(`"%s"`, placeholder)
+		}
+		snippet = append(snippet, placeholder)
+	}
+	return strings.Join(snippet, " ")
+}
+
+func findImportDecl(mod *parser.Module, name string) *parser.ImportDecl {
+	for _, decl := range mod.Decls {
+		if decl.Import != nil && decl.Import.Ident != nil && decl.Import.Ident.Name == name {
+			return decl.Import
+		}
+	}
+	return nil
+}
+
+// docString returns the text of a doc comment without its comment markers.
+func docString(doc *parser.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, comment := range doc.List {
+		lines = append(lines, strings.TrimSpace(strings.TrimPrefix(comment.Text, "#")))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,145 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/alecthomas/participle/lexer"
+	"github.com/openllb/hlb/checker"
+	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/report"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) publishDiagnostics(ctx context.Context, td TextDocument) error {
+	log.Printf("publishing diagnostics")
+	return ls.server.Push(ctx, "textDocument/publishDiagnostics", lsp.PublishDiagnosticsParams{
+		URI:         td.Identifier.URI,
+		Diagnostics: newDiagnostics(td),
+	})
+}
+
+// newDiagnostics converts the syntax or semantic errors of a module into
+// diagnostics. A nil error results in an empty list, which clears any
+// diagnostics previously published for the document.
+func newDiagnostics(td TextDocument) []lsp.Diagnostic {
+	diagnostics := []lsp.Diagnostic{}
+
+	switch e := td.Err.(type) {
+	case nil:
+	case report.Error:
+		for _, group := range e.Groups {
+			for _, an := range group.Annotations {
+				start := newPosition(td.Text, an.Pos)
+				end := start
+				end.Character += utf16Count(substring(td.Text, an.Pos.Offset, an.Len()))
+
+				diagnostics = append(diagnostics, newDiagnostic(lsp.Range{Start: start, End: end}, an.Message))
+			}
+		}
+	case checker.ErrSemantic:
+		for _, err := range e.Errs {
+			diagnostics = append(diagnostics, newDiagnosticFromError(td, err))
+		}
+	default:
+		diagnostics = append(diagnostics, newDiagnosticFromError(td, td.Err))
+	}
+
+	return diagnostics
+}
+
+func newDiagnosticFromError(td TextDocument, err error) lsp.Diagnostic {
+	node := errorNode(td.Module, err)
+	if node == nil {
+		return newDiagnostic(lsp.Range{}, err.Error())
+	}
+
+	msg := strings.TrimPrefix(err.Error(), checker.FormatPos(node.Position()))
+	return newDiagnostic(newRangeFromNode(td.Text, node), strings.TrimSpace(msg))
+}
+
+func newDiagnostic(rng lsp.Range, msg string) lsp.Diagnostic {
+	return lsp.Diagnostic{
+		Range:    rng,
+		Severity: lsp.Error,
+		Source:   "hlb",
+		Message:  msg,
+	}
+}
+
+// errorNode returns the node a checker error is reported at.
+func errorNode(mod *parser.Module, err error) parser.Node {
+	switch e := err.(type) {
+	case checker.ErrDuplicateDecls:
+		return e.Idents[0]
+	case checker.ErrDuplicateFields:
+		return e.Fields[0]
+	case checker.ErrInvalidFunc:
+		return e.CallStmt.Func
+	case checker.ErrForInOptionBlock:
+		return e.For
+	case checker.ErrListElemType:
+		return e.Type
+	case checker.ErrDefaultOrder:
+		return e.Field
+	case checker.ErrVariadicDefault:
+		return e.Field
+	case checker.ErrNamedArg:
+		return e.Named
+	case checker.ErrNoSuchParam:
+		return e.Named
+	case checker.ErrDuplicateArg:
+		return e.Named
+	case checker.ErrPositionalAfterNamed:
+		return e.Arg
+	case checker.ErrMissingArg:
+		return e.Node
+	case checker.ErrOptionSubtype:
+		return e.Ident
+	case checker.ErrNumArgs:
+		return e.Node
+	case checker.ErrIdentNotDefined:
+		return e.Ident
+	case checker.ErrFuncArg:
+		return e.Ident
+	case checker.ErrWrongArgType:
+		return findNodeAt(mod, e.Pos)
+	case checker.ErrInvalidTarget:
+		return e.Node
+	case checker.ErrCallUnexported:
+		return e.Selector
+	case checker.ErrNotImport:
+		return e.Ident
+	case checker.ErrIdentUndefined:
+		return e.Ident
+	case checker.ErrImportNotExist:
+		return e.Import
+	case checker.ErrBadParse:
+		return e.Node
+	case checker.ErrUseModuleWithoutSelector:
+		return e.Ident
+	}
+	return nil
+}
+
+// findNodeAt returns the outermost node starting at pos.
+func findNodeAt(mod *parser.Module, pos lexer.Position) parser.Node {
+	if mod == nil {
+		return nil
+	}
+
+	var found parser.Node
+	parser.Inspect(mod, func(node parser.Node) bool {
+		if node == nil || found != nil {
+			return false
+		}
+
+		if node.Position().Line == pos.Line && node.Position().Column == pos.Column {
+			found = node
+			return false
+		}
+		return true
+	})
+	return found
+}
@@ -0,0 +1,71 @@
+package langserver
+
+import (
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		input    string
+		expected []lsp.Diagnostic
+	}
+
+	for _, tc := range []testCase{{
+		"no errors",
+		"fs default() {\n\tscratch\n}\n",
+		[]lsp.Diagnostic{},
+	}, {
+		"syntax error",
+		"fs default() {\n\tscratch\n",
+		[]lsp.Diagnostic{{
+			Range:    lsp.Range{Start: lsp.Position{Line: 0, Character: 13}, End: lsp.Position{Line: 0, Character: 14}},
+			Severity: lsp.Error,
+			Source:   "hlb",
+			Message:  "unmatched {",
+		}, {
+			Range:    lsp.Range{Start: lsp.Position{Line: 2, Character: 0}, End: lsp.Position{Line: 2, Character: 0}},
+			Severity: lsp.Error,
+			Source:   "hlb",
+			Message:  "expected }, found end of file",
+		}},
+	}, {
+		"undefined identifier",
+		"fs default() {\n\tbase\n}\n",
+		[]lsp.Diagnostic{{
+			Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 1}, End: lsp.Position{Line: 1, Character: 5}},
+			Severity: lsp.Error,
+			Source:   "hlb",
+			Message:  "ident base not defined",
+		}},
+	}, {
+		"wrong number of args",
+		"fs default() {\n\timage\n}\n",
+		[]lsp.Diagnostic{{
+			Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 1}, End: lsp.Position{Line: 1, Character: 6}},
+			Severity: lsp.Error,
+			Source:   "hlb",
+			Message:  "missing arg for param ref",
+		}},
+	}, {
+		"after characters outside the basic multilingual plane",
+		"fs default() {\n\tmkfile \"\U0001F600\" 0o644 content\n}\n",
+		[]lsp.Diagnostic{{
+			Range:    lsp.Range{Start: lsp.Position{Line: 1, Character: 19}, End: lsp.Position{Line: 1, Character: 26}},
+			Severity: lsp.Error,
+			Source:   "hlb",
+			Message:  "ident content not defined",
+		}},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			td := NewTextDocument("file:///build.hlb", tc.input)
+			require.Equal(t, tc.expected, newDiagnostics(td))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentFormattingHandler(ctx context.Context, params lsp.DocumentFormattingParams) ([]lsp.TextEdit, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document formatting %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// Modules with syntax errors are only partially parsed, so unparsing
+	// them would drop the rest of the text. Only parsed modules are checked
+	// and given a scope.
+	edits := []lsp.TextEdit{}
+	if td.Module == nil || td.Module.Scope == nil {
+		return edits, nil
+	}
+
+	// Files end with a single newline regardless of the trailing newlines
+	// kept by the unparser.
+	text := fmt.Sprintf("%s\n", strings.TrimRight(td.Module.String(), "\n"))
+	if text == td.Text {
+		return edits, nil
+	}
+
+	edits = append(edits, lsp.TextEdit{
+		Range:   lsp.Range{End: positionAt(td.Text, len(td.Text))},
+		NewText: text,
+	})
+	return edits, nil
+}
+
+func (ls *LangServer) textDocumentRangeFormattingHandler(ctx context.Context, params lsp.DocumentRangeFormattingParams) ([]lsp.TextEdit, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document range formatting %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	edits := []lsp.TextEdit{}
+	if td.Module == nil || td.Module.Scope == nil {
+		return edits, nil
+	}
+
+	// Only whole declarations can be unparsed, so every declaration that
+	// overlaps with the range is formatted.
+	for _, decl := range td.Module.Decls {
+		var node parser.Node
+		switch {
+		case decl.Import != nil:
+			node = decl.Import
+		case decl.Export != nil:
+			node = decl.Export
+		case decl.Func != nil:
+			node = decl.Func
+		default:
+			continue
+		}
+
+		rng := newRangeFromNode(td.Text, node)
+		if !isRangeOverlapping(rng, params.Range) {
+			continue
+		}
+
+		edits = append(edits, lsp.TextEdit{
+			Range:   rng,
+			NewText: decl.String(),
+		})
+	}
+
+	return edits, nil
+}
+
+// isRangeOverlapping returns true if the two ranges share a position.
+func isRangeOverlapping(a, b lsp.Range) bool {
+	return !isPositionBefore(a.End, b.Start) && !isPositionBefore(b.End, a.Start)
+}
+
+func isPositionBefore(a, b lsp.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+}
@@ -0,0 +1,100 @@
+package langserver
+
+import (
+	"context"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatting(t *testing.T) {
+	t.Parallel()
+
+	uri := lsp.DocumentURI("file:///build.hlb")
+
+	type testCase struct {
+		name     string
+		input    string
+		expected []lsp.TextEdit
+	}
+
+	for _, tc := range []testCase{{
+		"formatted",
+		"fs default() {\n\tscratch\n}\n",
+		[]lsp.TextEdit{},
+	}, {
+		"unformatted",
+		"fs   default( ) {\n  image  \"alpine\"\n}\n\n\n",
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{End: lsp.Position{Line: 5, Character: 0}},
+			NewText: "fs default() {\n\timage \"alpine\"\n}\n",
+		}},
+	}, {
+		"syntax error",
+		"fs   default( ) {\n  image  \"alpine\"\n",
+		[]lsp.TextEdit{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, tc.input)
+
+			edits, err := ls.textDocumentFormattingHandler(context.Background(), lsp.DocumentFormattingParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, edits)
+		})
+	}
+}
+
+func TestRangeFormatting(t *testing.T) {
+	t.Parallel()
+
+	uri := lsp.DocumentURI("file:///build.hlb")
+	text := "fs   default( ) {\n  image  \"alpine\"\n}\n\nfs  build( ) {\n  scratch\n}\n"
+
+	type testCase struct {
+		name     string
+		rng      lsp.Range
+		expected []lsp.TextEdit
+	}
+
+	for _, tc := range []testCase{{
+		"one declaration",
+		lsp.Range{Start: lsp.Position{Line: 5, Character: 2}, End: lsp.Position{Line: 5, Character: 4}},
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 4, Character: 0}, End: lsp.Position{Line: 6, Character: 1}},
+			NewText: "fs build() {\n\tscratch\n}",
+		}},
+	}, {
+		"overlapping declarations",
+		lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 4, Character: 0}},
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 2, Character: 1}},
+			NewText: "fs default() {\n\timage \"alpine\"\n}",
+		}, {
+			Range:   lsp.Range{Start: lsp.Position{Line: 4, Character: 0}, End: lsp.Position{Line: 6, Character: 1}},
+			NewText: "fs build() {\n\tscratch\n}",
+		}},
+	}, {
+		"between declarations",
+		lsp.Range{Start: lsp.Position{Line: 3, Character: 0}, End: lsp.Position{Line: 3, Character: 0}},
+		[]lsp.TextEdit{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, text)
+			require.NoError(t, ls.tds[uri].Err)
+
+			edits, err := ls.textDocumentRangeFormattingHandler(context.Background(), lsp.DocumentRangeFormattingParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Range:        tc.rng,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, edits)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package langserver
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/lexer"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+// LSP characters count UTF-16 code units, while nodes are positioned by byte
+// offsets into the text they were parsed from. Positions are converted
+// between the two with offsetAt and newPosition.
+
+// offsetAt returns the byte offset of a position in text.
+func offsetAt(text string, pos lsp.Position) int {
+	offset := 0
+	for i := 0; i < pos.Line; i++ {
+		index := strings.IndexByte(text[offset:], '\n')
+		if index < 0 {
+			return len(text)
+		}
+		offset += index + 1
+	}
+
+	end := strings.IndexByte(text[offset:], '\n')
+	if end < 0 {
+		end = len(text) - offset
+	}
+
+	character := 0
+	for i, r := range text[offset : offset+end] {
+		if character >= pos.Character {
+			return offset + i
+		}
+		character += utf16Len(r)
+	}
+	return offset + end
+}
+
+// newPosition returns the LSP position of a node position in text. Only the
+// offset is used, as the end of nodes like newlines is shifted past the line
+// of the position.
+func newPosition(text string, pos lexer.Position) lsp.Position {
+	return positionAt(text, pos.Offset)
+}
+
+// positionAt returns the LSP position of a byte offset in text.
+func positionAt(text string, offset int) lsp.Position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	return lsp.Position{Line: strings.Count(text[:offset], "\n"), Character: characterAt(text, offset)}
+}
+
+// characterAt returns the LSP character of a byte offset in text, which is
+// the number of UTF-16 code units from the start of its line.
+func characterAt(text string, offset int) int {
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	return utf16Count(text[strings.LastIndexByte(text[:offset], '\n')+1 : offset])
+}
+
+// substring returns up to n bytes of text starting at offset.
+func substring(text string, offset, n int) string {
+	if offset > len(text) {
+		return ""
+	}
+	if offset+n > len(text) {
+		n = len(text) - offset
+	}
+	return text[offset : offset+n]
+}
+
+// utf16Count returns the number of UTF-16 code units encoding s.
+func utf16Count(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r, which is two
+// for runes outside the basic multilingual plane.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,130 @@
+package langserver
+
+import (
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetAt(t *testing.T) {
+	t.Parallel()
+
+	text := "fs default() {\n\tmkfile \"é😀\" 0o644 \"x\"\n}"
+
+	type testCase struct {
+		name     string
+		pos      lsp.Position
+		expected int
+	}
+
+	for _, tc := range []testCase{{
+		"start of text",
+		lsp.Position{Line: 0, Character: 0},
+		0,
+	}, {
+		"within first line",
+		lsp.Position{Line: 0, Character: 3},
+		3,
+	}, {
+		"past end of line",
+		lsp.Position{Line: 0, Character: 100},
+		14,
+	}, {
+		"start of second line",
+		lsp.Position{Line: 1, Character: 0},
+		15,
+	}, {
+		"after two byte rune",
+		lsp.Position{Line: 1, Character: 10},
+		26,
+	}, {
+		"after surrogate pair",
+		lsp.Position{Line: 1, Character: 12},
+		30,
+	}, {
+		"after closing quote",
+		lsp.Position{Line: 1, Character: 13},
+		31,
+	}, {
+		"last line",
+		lsp.Position{Line: 2, Character: 1},
+		len(text),
+	}, {
+		"past last line",
+		lsp.Position{Line: 5, Character: 0},
+		len(text),
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, offsetAt(text, tc.pos))
+		})
+	}
+}
+
+func TestPositionAt(t *testing.T) {
+	t.Parallel()
+
+	text := "fs default() {\n\tmkfile \"é😀\" 0o644 \"x\"\n}"
+
+	type testCase struct {
+		name     string
+		offset   int
+		expected lsp.Position
+	}
+
+	for _, tc := range []testCase{{
+		"start of text",
+		0,
+		lsp.Position{Line: 0, Character: 0},
+	}, {
+		"end of first line",
+		14,
+		lsp.Position{Line: 0, Character: 14},
+	}, {
+		"start of second line",
+		15,
+		lsp.Position{Line: 1, Character: 0},
+	}, {
+		"after two byte rune",
+		26,
+		lsp.Position{Line: 1, Character: 10},
+	}, {
+		"after surrogate pair",
+		30,
+		lsp.Position{Line: 1, Character: 12},
+	}, {
+		"end of text",
+		len(text),
+		lsp.Position{Line: 2, Character: 1},
+	}, {
+		"past end of text",
+		len(text) + 5,
+		lsp.Position{Line: 2, Character: 1},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, positionAt(text, tc.offset))
+		})
+	}
+}
+
+func TestNewRangeFromNode(t *testing.T) {
+	t.Parallel()
+
+	text := "fs default() {\n\tmkfile \"😀\" 0o644 \"x\"\n}\n"
+	td := NewTextDocument("file:///build.hlb", text)
+	require.NoError(t, td.Err)
+
+	// Statements end past the newline terminating them.
+	call := td.Module.Decls[0].Func.Body.List[1].Call
+	require.NotNil(t, call)
+	require.Equal(t, lsp.Range{
+		Start: lsp.Position{Line: 1, Character: 1},
+		End:   lsp.Position{Line: 2, Character: 0},
+	}, newRangeFromNode(text, call))
+	require.Equal(t, lsp.Range{
+		Start: lsp.Position{Line: 1, Character: 13},
+		End:   lsp.Position{Line: 1, Character: 18},
+	}, newRangeFromNode(text, call.Args[1]))
+}
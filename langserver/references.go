@@ -0,0 +1,245 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/parser"
+	"github.com/openllb/hlb/report"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+var (
+	identPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// symbolRef is an identifier in a module and the object it resolves to.
+type symbolRef struct {
+	Ident *parser.Ident
+	Obj   *parser.Object
+	Scope *parser.Scope
+}
+
+func (ls *LangServer) textDocumentReferencesHandler(ctx context.Context, params lsp.ReferenceParams) ([]lsp.Location, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document references %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := []lsp.Location{}
+	if td.Module == nil || td.Module.Scope == nil {
+		return locs, nil
+	}
+
+	refs := resolveSymbols(td.Module)
+	obj := symbolAt(td.Text, refs, params.Position)
+	if obj == nil {
+		return locs, nil
+	}
+
+	for _, ref := range refs {
+		if ref.Obj != obj {
+			continue
+		}
+
+		if ref.Ident == obj.Ident && !params.Context.IncludeDeclaration {
+			continue
+		}
+
+		locs = append(locs, *newLocationFromNode(td, ref.Ident))
+	}
+
+	return locs, nil
+}
+
+func (ls *LangServer) textDocumentRenameHandler(ctx context.Context, params lsp.RenameParams) (*lsp.WorkspaceEdit, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document rename %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.Module == nil || td.Module.Scope == nil {
+		return nil, fmt.Errorf("cannot rename in a module with syntax errors")
+	}
+
+	refs := resolveSymbols(td.Module)
+	obj := symbolAt(td.Text, refs, params.Position)
+	if obj == nil {
+		return nil, fmt.Errorf("no symbol to rename at %d:%d", params.Position.Line+1, params.Position.Character+1)
+	}
+
+	name := params.NewName
+	err = validateName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The export of a symbol resolves to it and is renamed with it, but the
+	// modules importing it are out of scope and keep the old name.
+	var edits []lsp.TextEdit
+	for _, ref := range refs {
+		if ref.Obj != obj {
+			continue
+		}
+
+		// Renaming must not change what any of the references resolve to, so
+		// the new name cannot already be visible from where they are.
+		if ref.Scope != nil {
+			if other := ref.Scope.Lookup(name); other != nil && other != obj {
+				return nil, fmt.Errorf("%s is already declared", name)
+			}
+		}
+
+		edits = append(edits, lsp.TextEdit{
+			Range:   newRangeFromNode(td.Text, ref.Ident),
+			NewText: name,
+		})
+	}
+
+	return &lsp.WorkspaceEdit{
+		Changes: map[string][]lsp.TextEdit{
+			string(uri): edits,
+		},
+	}, nil
+}
+
+// validateName returns an error if name cannot be used as an identifier. Names
+// of builtins are rejected as calls to them would resolve to the builtin.
+func validateName(name string) error {
+	if !identPattern.MatchString(name) || report.Contains(report.ReservedKeywords, name) || name == "true" || name == "false" {
+		return fmt.Errorf("%q is not a valid identifier", name)
+	}
+
+	for _, lookup := range builtin.Lookup.ByType {
+		if _, ok := lookup.Func[name]; ok {
+			return fmt.Errorf("%q is the name of a builtin", name)
+		}
+	}
+	return nil
+}
+
+// symbolAt returns the object of the identifier at pos.
+func symbolAt(text string, refs []symbolRef, pos lsp.Position) *parser.Object {
+	for _, ref := range refs {
+		if isPositionWithinNode(text, pos, ref.Ident) {
+			return ref.Obj
+		}
+	}
+	return nil
+}
+
+// resolveSymbols returns every identifier in the module that resolves to an
+// object through the scopes created by the checker. Identifiers of builtins
+// and selected members of imports don't resolve to objects in this module, so
+// they are left out.
+func resolveSymbols(mod *parser.Module) []symbolRef {
+	var (
+		refs   []symbolRef
+		stack  []parser.Node
+		scopes = []*parser.Scope{mod.Scope}
+		calls  []*parser.CallStmt
+	)
+
+	add := func(scope *parser.Scope, ident *parser.Ident) {
+		if scope == nil || ident == nil {
+			return
+		}
+
+		obj := scope.Lookup(ident.Name)
+		if obj == nil {
+			return
+		}
+
+		refs = append(refs, symbolRef{Ident: ident, Obj: obj, Scope: scope})
+	}
+
+	parser.Inspect(mod, func(node parser.Node) bool {
+		if node == nil {
+			switch stack[len(stack)-1].(type) {
+			case *parser.FuncDecl, *parser.ForStmt, *parser.FieldDefault:
+				scopes = scopes[:len(scopes)-1]
+			case *parser.CallStmt:
+				calls = calls[:len(calls)-1]
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, node)
+
+		scope := scopes[len(scopes)-1]
+
+		switch n := node.(type) {
+		case *parser.ImportDecl:
+			add(mod.Scope, n.Ident)
+		case *parser.ExportDecl:
+			add(mod.Scope, n.Ident)
+		case *parser.FuncDecl:
+			add(mod.Scope, n.Name)
+
+			if n.Scope != nil {
+				scope = n.Scope
+			}
+			scopes = append(scopes, scope)
+
+			if n.Params != nil {
+				for _, field := range n.Params.List {
+					add(scope, field.Name)
+				}
+			}
+		case *parser.FieldDefault:
+			// Default values are evaluated in the module scope.
+			scopes = append(scopes, mod.Scope)
+		case *parser.ForStmt:
+			if n.Scope != nil {
+				scope = n.Scope
+			}
+			scopes = append(scopes, scope)
+			add(scope, n.Var)
+		case *parser.AliasDecl:
+			add(mod.Scope, n.Ident)
+		case *parser.CallStmt:
+			calls = append(calls, n)
+		case *parser.Expr:
+			switch {
+			case n.Ident != nil:
+				add(scope, n.Ident)
+			case n.Selector != nil:
+				add(scope, n.Selector.Ident)
+			}
+
+			if n.Named != nil && len(calls) > 0 {
+				call := calls[len(calls)-1]
+				if call.Func == nil || call.Func.Ident == nil {
+					break
+				}
+
+				// Named args resolve to the params of the function called.
+				obj := scope.Lookup(call.Func.Ident.Name)
+				if obj == nil {
+					break
+				}
+
+				fun, ok := obj.Node.(*parser.FuncDecl)
+				if !ok || fun.Scope == nil {
+					break
+				}
+
+				if param, ok := fun.Scope.Objects[n.Named.Name.Name]; ok {
+					refs = append(refs, symbolRef{Ident: n.Named.Name, Obj: param})
+				}
+			}
+		}
+		return true
+	})
+
+	return refs
+}
@@ -0,0 +1,145 @@
+package langserver
+
+import (
+	"context"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRename(t *testing.T) {
+	t.Parallel()
+
+	uri := lsp.DocumentURI("file:///build.hlb")
+	text := `export build
+
+fs default() {
+	base "alpine"
+}
+
+fs base(string ref) {
+	image ref
+}
+
+fs build() {
+	base "golang"
+}
+`
+
+	type testCase struct {
+		name    string
+		pos     lsp.Position
+		newName string
+		edits   []lsp.TextEdit
+		err     string
+	}
+
+	for _, tc := range []testCase{{
+		"function",
+		lsp.Position{Line: 6, Character: 4},
+		"baseImage",
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 3, Character: 1}, End: lsp.Position{Line: 3, Character: 5}},
+			NewText: "baseImage",
+		}, {
+			Range:   lsp.Range{Start: lsp.Position{Line: 6, Character: 3}, End: lsp.Position{Line: 6, Character: 7}},
+			NewText: "baseImage",
+		}, {
+			Range:   lsp.Range{Start: lsp.Position{Line: 11, Character: 1}, End: lsp.Position{Line: 11, Character: 5}},
+			NewText: "baseImage",
+		}},
+		"",
+	}, {
+		"param",
+		lsp.Position{Line: 7, Character: 8},
+		"name",
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 6, Character: 15}, End: lsp.Position{Line: 6, Character: 18}},
+			NewText: "name",
+		}, {
+			Range:   lsp.Range{Start: lsp.Position{Line: 7, Character: 7}, End: lsp.Position{Line: 7, Character: 10}},
+			NewText: "name",
+		}},
+		"",
+	}, {
+		"invalid identifier",
+		lsp.Position{Line: 6, Character: 4},
+		"1base",
+		nil,
+		`"1base" is not a valid identifier`,
+	}, {
+		"keyword",
+		lsp.Position{Line: 6, Character: 4},
+		"if",
+		nil,
+		`"if" is not a valid identifier`,
+	}, {
+		"type",
+		lsp.Position{Line: 6, Character: 4},
+		"group",
+		nil,
+		`"group" is not a valid identifier`,
+	}, {
+		"bool literal",
+		lsp.Position{Line: 6, Character: 4},
+		"false",
+		nil,
+		`"false" is not a valid identifier`,
+	}, {
+		"builtin",
+		lsp.Position{Line: 6, Character: 4},
+		"image",
+		nil,
+		`"image" is the name of a builtin`,
+	}, {
+		"declared name",
+		lsp.Position{Line: 6, Character: 4},
+		"default",
+		nil,
+		"default is already declared",
+	}, {
+		"exported function",
+		lsp.Position{Line: 10, Character: 4},
+		"release",
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 0, Character: 7}, End: lsp.Position{Line: 0, Character: 12}},
+			NewText: "release",
+		}, {
+			Range:   lsp.Range{Start: lsp.Position{Line: 10, Character: 3}, End: lsp.Position{Line: 10, Character: 8}},
+			NewText: "release",
+		}},
+		"",
+	}, {
+		"export",
+		lsp.Position{Line: 0, Character: 9},
+		"release",
+		[]lsp.TextEdit{{
+			Range:   lsp.Range{Start: lsp.Position{Line: 0, Character: 7}, End: lsp.Position{Line: 0, Character: 12}},
+			NewText: "release",
+		}, {
+			Range:   lsp.Range{Start: lsp.Position{Line: 10, Character: 3}, End: lsp.Position{Line: 10, Character: 8}},
+			NewText: "release",
+		}},
+		"",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, text)
+			require.NoError(t, ls.tds[uri].Err)
+
+			edit, err := ls.textDocumentRenameHandler(context.Background(), lsp.RenameParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     tc.pos,
+				NewName:      tc.newName,
+			})
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.edits, edit.Changes[string(uri)])
+		})
+	}
+}
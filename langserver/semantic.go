@@ -0,0 +1,147 @@
+package langserver
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentSemanticTokensFullHandler(ctx context.Context, params SemanticTokensParams) (*SemanticTokens, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document semantic tokens %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := ls.storeSemanticTokens(uri, encodeSemanticTokens(td))
+	return &tokens, nil
+}
+
+func (ls *LangServer) textDocumentSemanticTokensFullDeltaHandler(ctx context.Context, params SemanticTokensDeltaParams) (interface{}, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document semantic tokens delta %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ls.smu.Lock()
+	prev, ok := ls.sts[uri]
+	ls.smu.Unlock()
+
+	tokens := ls.storeSemanticTokens(uri, encodeSemanticTokens(td))
+
+	// Without the previous result, the client needs all the tokens again.
+	if !ok || prev.ResultID != params.PreviousResultID {
+		return &tokens, nil
+	}
+
+	return &SemanticTokensDelta{
+		ResultID: tokens.ResultID,
+		Edits:    diffSemanticTokens(prev.Data, tokens.Data),
+	}, nil
+}
+
+// storeSemanticTokens assigns a new result ID to the tokens of a document and
+// keeps them to compute the delta of the next request.
+func (ls *LangServer) storeSemanticTokens(uri lsp.DocumentURI, data []uint32) SemanticTokens {
+	ls.smu.Lock()
+	defer ls.smu.Unlock()
+
+	ls.stid++
+	tokens := SemanticTokens{
+		ResultID: strconv.Itoa(ls.stid),
+		Data:     data,
+	}
+	ls.sts[uri] = tokens
+	return tokens
+}
+
+// semanticTokensLegend returns the token types in the order of the scopes, so
+// that a scope is also the index of its token type.
+func semanticTokensLegend() SemanticTokensLegend {
+	legend := SemanticTokensLegend{
+		TokenModifiers: []string{},
+	}
+	for s := String; s <= Comment; s++ {
+		legend.TokenTypes = append(legend.TokenTypes, s.TokenType())
+	}
+	return legend
+}
+
+// encodeSemanticTokens returns the tokens of a document in the relative encoding
+// of the protocol, where every token is five integers: the line relative to
+// the previous token, the start character relative to the previous token if
+// on the same line, the length, the token type and the token modifiers.
+func encodeSemanticTokens(td TextDocument) []uint32 {
+	data := []uint32{}
+	if td.Module == nil {
+		return data
+	}
+
+	lines := make(map[int]lsp.SemanticHighlightingTokens)
+	highlightModule(lines, td.Text, td.Module)
+
+	var sortedLines []int
+	for line := range lines {
+		sortedLines = append(sortedLines, line)
+	}
+	sort.Ints(sortedLines)
+
+	var prevLine, prevChar uint32
+	for _, line := range sortedLines {
+		tokens := lines[line]
+		sort.SliceStable(tokens, func(i, j int) bool {
+			return tokens[i].Character < tokens[j].Character
+		})
+
+		for i, token := range tokens {
+			// Tokens may not overlap, so only the first of the tokens at the
+			// same position is kept.
+			if i > 0 && tokens[i-1].Character == token.Character {
+				continue
+			}
+
+			deltaLine := uint32(line) - prevLine
+			deltaChar := token.Character
+			if deltaLine == 0 {
+				deltaChar -= prevChar
+			}
+
+			data = append(data, deltaLine, deltaChar, uint32(token.Length), uint32(token.Scope), 0)
+			prevLine, prevChar = uint32(line), token.Character
+		}
+	}
+
+	return data
+}
+
+// diffSemanticTokens returns a single edit replacing the integers that differ
+// between the previous and next tokens, or no edits if they are equal.
+func diffSemanticTokens(prev, next []uint32) []SemanticTokensEdit {
+	start := 0
+	for start < len(prev) && start < len(next) && prev[start] == next[start] {
+		start++
+	}
+
+	if start == len(prev) && start == len(next) {
+		return []SemanticTokensEdit{}
+	}
+
+	end := 0
+	for end < len(prev)-start && end < len(next)-start && prev[len(prev)-1-end] == next[len(next)-1-end] {
+		end++
+	}
+
+	return []SemanticTokensEdit{{
+		Start:       start,
+		DeleteCount: len(prev) - start - end,
+		Data:        next[start : len(next)-end],
+	}}
+}
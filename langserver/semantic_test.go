@@ -0,0 +1,94 @@
+package langserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		input    string
+		expected []uint32
+	}
+
+	for _, tc := range []testCase{{
+		"func decl",
+		"fs default(string ref) {\n\timage ref\n}\n",
+		[]uint32{
+			0, 0, 2, uint32(Type), 0,
+			0, 3, 7, uint32(Function), 0,
+			0, 8, 6, uint32(Type), 0,
+			0, 7, 3, uint32(Parameter), 0,
+			1, 7, 3, uint32(Variable), 0,
+		},
+	}, {
+		"characters outside the basic multilingual plane",
+		"fs default() {\n\tmkfile \"\U0001F600\" 0o644 \"\u00e9\"\n}\n",
+		[]uint32{
+			0, 0, 2, uint32(Type), 0,
+			0, 3, 7, uint32(Function), 0,
+			1, 8, 4, uint32(String), 0,
+			0, 5, 5, uint32(Numeric), 0,
+			0, 6, 3, uint32(String), 0,
+		},
+	}, {
+		"empty",
+		"",
+		[]uint32{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			td := NewTextDocument("file:///test.hlb", tc.input)
+			require.Equal(t, tc.expected, encodeSemanticTokens(td))
+		})
+	}
+
+	require.Equal(t, []uint32{}, encodeSemanticTokens(TextDocument{}))
+}
+
+func TestDiffSemanticTokens(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		prev     []uint32
+		next     []uint32
+		expected []SemanticTokensEdit
+	}
+
+	for _, tc := range []testCase{{
+		"equal",
+		[]uint32{0, 0, 2, 7, 0},
+		[]uint32{0, 0, 2, 7, 0},
+		[]SemanticTokensEdit{},
+	}, {
+		"changed token",
+		[]uint32{0, 0, 2, 7, 0, 0, 3, 7, 8, 0, 1, 7, 3, 3, 0},
+		[]uint32{0, 0, 2, 7, 0, 0, 3, 9, 8, 0, 1, 7, 3, 3, 0},
+		[]SemanticTokensEdit{{Start: 7, DeleteCount: 1, Data: []uint32{9}}},
+	}, {
+		"appended token",
+		[]uint32{0, 0, 2, 7, 0},
+		[]uint32{0, 0, 2, 7, 0, 0, 3, 7, 8, 0},
+		[]SemanticTokensEdit{{Start: 5, DeleteCount: 0, Data: []uint32{0, 3, 7, 8, 0}}},
+	}, {
+		"removed token",
+		[]uint32{0, 0, 2, 7, 0, 0, 3, 7, 8, 0},
+		[]uint32{0, 0, 2, 7, 0},
+		[]SemanticTokensEdit{{Start: 5, DeleteCount: 5, Data: []uint32{}}},
+	}, {
+		"all tokens removed",
+		[]uint32{0, 0, 2, 7, 0},
+		[]uint32{},
+		[]SemanticTokensEdit{{Start: 0, DeleteCount: 5, Data: []uint32{}}},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, diffSemanticTokens(tc.prev, tc.next))
+		})
+	}
+}
@@ -6,8 +6,8 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,14 +15,12 @@ import (
 	"github.com/creachadair/jrpc2"
 	"github.com/creachadair/jrpc2/channel"
 	"github.com/creachadair/jrpc2/handler"
-	"github.com/moby/buildkit/client/llb"
-	digest "github.com/opencontainers/go-digest"
 	"github.com/openllb/hlb"
 	"github.com/openllb/hlb/builtin"
 	"github.com/openllb/hlb/checker"
-	"github.com/openllb/hlb/codegen"
 	"github.com/openllb/hlb/module"
 	"github.com/openllb/hlb/parser"
+	"github.com/pkg/errors"
 	lsp "github.com/sourcegraph/go-lsp"
 )
 
@@ -30,18 +28,24 @@ type LangServer struct {
 	server *jrpc2.Server
 	capset map[Capability]struct{}
 
+	rootURI lsp.DocumentURI
+
 	tds map[lsp.DocumentURI]TextDocument
 	tmu sync.RWMutex
 
 	dbs map[lsp.DocumentURI]*debouncer
 	dmu sync.Mutex
+
+	sts  map[lsp.DocumentURI]SemanticTokens
+	stid int
+	smu  sync.Mutex
 }
 
 type Capability int
 
 const (
 	_ Capability = iota
-	SemanticHighlightingCapability
+	HierarchicalDocumentSymbolCapability
 )
 
 func NewServer() *LangServer {
@@ -49,18 +53,30 @@ func NewServer() *LangServer {
 		capset: make(map[Capability]struct{}),
 		tds:    make(map[lsp.DocumentURI]TextDocument),
 		dbs:    make(map[lsp.DocumentURI]*debouncer),
+		sts:    make(map[lsp.DocumentURI]SemanticTokens),
 	}
 
 	ls.server = jrpc2.NewServer(handler.Map{
-		"initialize":              handler.New(ls.initializeHandler),
-		"exit":                    handler.New(ls.exitHandler),
-		"$/cancelRequest":         handler.New(ls.cancelRequestHandler),
-		"textDocument/didOpen":    handler.New(ls.textDocumentDidOpenHandler),
-		"textDocument/didClose":   handler.New(ls.textDocumentDidCloseHandler),
-		"textDocument/didChange":  handler.New(ls.textDocumentDidChangeHandler),
-		"textDocument/hover":      handler.New(ls.textDocumentHoverHandler),
-		"textDocument/definition": handler.New(ls.textDocumentDefinitionHandler),
-		"textDocument/completion": handler.New(ls.textDocumentCompletionHandler),
+		"initialize":                             handler.New(ls.initializeHandler),
+		"exit":                                   handler.New(ls.exitHandler),
+		"$/cancelRequest":                        handler.New(ls.cancelRequestHandler),
+		"textDocument/didOpen":                   handler.New(ls.textDocumentDidOpenHandler),
+		"textDocument/didClose":                  handler.New(ls.textDocumentDidCloseHandler),
+		"textDocument/didChange":                 handler.New(ls.textDocumentDidChangeHandler),
+		"textDocument/hover":                     handler.New(ls.textDocumentHoverHandler),
+		"textDocument/definition":                handler.New(ls.textDocumentDefinitionHandler),
+		"textDocument/completion":                handler.New(ls.textDocumentCompletionHandler),
+		"textDocument/signatureHelp":             handler.New(ls.textDocumentSignatureHelpHandler),
+		"textDocument/references":                handler.New(ls.textDocumentReferencesHandler),
+		"textDocument/rename":                    handler.New(ls.textDocumentRenameHandler),
+		"textDocument/documentSymbol":            handler.New(ls.textDocumentDocumentSymbolHandler),
+		"textDocument/foldingRange":              handler.New(ls.textDocumentFoldingRangeHandler),
+		"textDocument/formatting":                handler.New(ls.textDocumentFormattingHandler),
+		"textDocument/rangeFormatting":           handler.New(ls.textDocumentRangeFormattingHandler),
+		"textDocument/codeAction":                handler.New(ls.textDocumentCodeActionHandler),
+		"textDocument/semanticTokens/full":       handler.New(ls.textDocumentSemanticTokensFullHandler),
+		"textDocument/semanticTokens/full/delta": handler.New(ls.textDocumentSemanticTokensFullDeltaHandler),
+		"workspace/symbol":                       handler.New(ls.workspaceSymbolHandler),
 	}, &jrpc2.ServerOptions{
 		AllowPush: true,
 	})
@@ -81,38 +97,45 @@ func (ls *LangServer) Listen(ctx context.Context, r io.Reader, w io.WriteCloser)
 	return s.Wait()
 }
 
-func (ls *LangServer) initializeHandler(ctx context.Context, params lsp.InitializeParams) (lsp.InitializeResult, error) {
+func (ls *LangServer) initializeHandler(ctx context.Context, params lsp.InitializeParams) (InitializeResult, error) {
 	log.Printf("initialize %q", params.RootURI)
+	ls.rootURI = params.RootURI
 
-	highlightCap := params.Capabilities.TextDocument.SemanticHighlightingCapabilities
-	if highlightCap != nil && highlightCap.SemanticHighlighting {
-		ls.capset[SemanticHighlightingCapability] = struct{}{}
-		log.Printf("detected cap semantic highlighting")
+	if params.Capabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport {
+		ls.capset[HierarchicalDocumentSymbolCapability] = struct{}{}
+		log.Printf("detected cap hierarchical document symbol")
 	}
 
-	return lsp.InitializeResult{
-		Capabilities: lsp.ServerCapabilities{
-			DefinitionProvider: true,
-			HoverProvider:      true,
-			TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
-				Options: &lsp.TextDocumentSyncOptions{
-					OpenClose: true,
-					Change:    lsp.TDSKFull,
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			ServerCapabilities: lsp.ServerCapabilities{
+				DefinitionProvider:              true,
+				HoverProvider:                   true,
+				ReferencesProvider:              true,
+				RenameProvider:                  true,
+				DocumentSymbolProvider:          true,
+				WorkspaceSymbolProvider:         true,
+				DocumentFormattingProvider:      true,
+				DocumentRangeFormattingProvider: true,
+				CodeActionProvider:              true,
+				CompletionProvider: &lsp.CompletionOptions{
+					TriggerCharacters: []string{"."},
+				},
+				SignatureHelpProvider: &lsp.SignatureHelpOptions{
+					TriggerCharacters: []string{" "},
+				},
+				TextDocumentSync: &lsp.TextDocumentSyncOptionsOrKind{
+					Options: &lsp.TextDocumentSyncOptions{
+						OpenClose: true,
+						Change:    lsp.TDSKIncremental,
+					},
 				},
 			},
-			SemanticHighlighting: &lsp.SemanticHighlightingOptions{
-				Scopes: [][]string{
-					{String.String()},
-					{Constant.String()},
-					{Numeric.String()},
-					{Variable.String()},
-					{Parameter.String()},
-					{Keyword.String()},
-					{Modifier.String()},
-					{Type.String()},
-					{Function.String()},
-					{Module.String()},
-					{Comment.String()},
+			FoldingRangeProvider: true,
+			SemanticTokensProvider: &SemanticTokensOptions{
+				Legend: semanticTokensLegend(),
+				Full: &SemanticTokensFullOptions{
+					Delta: true,
 				},
 			},
 		},
@@ -139,45 +162,17 @@ func (ls *LangServer) textDocumentDidOpenHandler(ctx context.Context, params lsp
 	ls.tds[uri] = td
 	ls.tmu.Unlock()
 
-	if _, ok := ls.capset[SemanticHighlightingCapability]; ok {
-		go func() {
-			err := ls.publishSemanticHighlighting(ctx, td)
-			if err != nil {
-				log.Printf("err: %s", err)
-			}
-		}()
-	}
+	go func() {
+		err := ls.publishDiagnostics(ctx, td)
+		if err != nil {
+			log.Printf("err: %s", err)
+		}
+	}()
 
 	return nil
 }
 
-func (ls *LangServer) publishSemanticHighlighting(ctx context.Context, td TextDocument) error {
-	log.Printf("publishing semantic highlighting")
-	params := lsp.SemanticHighlightingParams{
-		TextDocument: td.Identifier,
-	}
-
-	lines := make(map[int]lsp.SemanticHighlightingTokens)
-
-	highlightModule(lines, td.Module)
-
-	var sortedLines []int
-	for line := range lines {
-		sortedLines = append(sortedLines, line)
-	}
-	sort.Ints(sortedLines)
-
-	for _, line := range sortedLines {
-		params.Lines = append(params.Lines, lsp.SemanticHighlightingInformation{
-			Line:   line,
-			Tokens: lines[line],
-		})
-	}
-
-	return ls.server.Push(ctx, "textDocument/semanticHighlighting", params)
-}
-
-func highlightModule(lines map[int]lsp.SemanticHighlightingTokens, mod *parser.Module) {
+func highlightModule(lines map[int]lsp.SemanticHighlightingTokens, text string, mod *parser.Module) {
 	parser.Inspect(mod, func(node parser.Node) bool {
 		if node == nil {
 			return false
@@ -185,63 +180,63 @@ func highlightModule(lines map[int]lsp.SemanticHighlightingTokens, mod *parser.M
 
 		switch n := node.(type) {
 		case *parser.Comment:
-			highlightNode(lines, node, Comment)
+			highlightNode(lines, text, node, Comment)
 			return false
 		case *parser.ImportDecl:
 			if n.Import != nil {
-				highlightNode(lines, n.Import, Keyword)
+				highlightNode(lines, text, n.Import, Keyword)
 			}
 			if n.Ident != nil {
-				highlightNode(lines, n.Ident, Module)
+				highlightNode(lines, text, n.Ident, Module)
 			}
 			switch {
 			case n.ImportFunc != nil:
 				if n.ImportFunc.From != nil {
-					highlightNode(lines, n.ImportFunc.From, Keyword)
+					highlightNode(lines, text, n.ImportFunc.From, Keyword)
 				}
 				if n.ImportFunc.Func != nil {
 					lit := n.ImportFunc.Func
 					if lit.Type != nil {
-						highlightNode(lines, lit.Type, Type)
+						highlightNode(lines, text, lit.Type, Type)
 					}
 					if lit.Type != nil && lit.Body != nil {
-						highlightBlock(lines, lit.Type.ObjType, lit.Body)
+						highlightBlock(lines, text, lit.Type.ObjType, lit.Body)
 					}
 				}
 			case n.ImportPath != nil:
-				highlightNode(lines, n.ImportPath, String)
+				highlightNode(lines, text, n.ImportPath, String)
 			}
 			return false
 		case *parser.ExportDecl:
 			if n.Export != nil {
-				highlightNode(lines, n.Export, Keyword)
+				highlightNode(lines, text, n.Export, Keyword)
 			}
 			if n.Ident != nil {
-				highlightNode(lines, n.Ident, Variable)
+				highlightNode(lines, text, n.Ident, Variable)
 			}
 			return false
 		case *parser.FuncDecl:
 			if n.Type != nil {
-				highlightNode(lines, n.Type, Type)
+				highlightNode(lines, text, n.Type, Type)
 			}
 			if n.Name != nil {
-				highlightNode(lines, n.Name, Function)
+				highlightNode(lines, text, n.Name, Function)
 			}
 			if n.Params != nil {
 				for _, field := range n.Params.List {
 					if field.Variadic != nil {
-						highlightNode(lines, field.Variadic, Modifier)
+						highlightNode(lines, text, field.Variadic, Modifier)
 					}
 					if field.Type != nil {
-						highlightNode(lines, field.Type, Type)
+						highlightNode(lines, text, field.Type, Type)
 					}
 					if field.Name != nil {
-						highlightNode(lines, field.Name, Parameter)
+						highlightNode(lines, text, field.Name, Parameter)
 					}
 				}
 			}
 			if n.Type != nil && n.Body != nil {
-				highlightBlock(lines, n.Type.ObjType, n.Body)
+				highlightBlock(lines, text, n.Type.ObjType, n.Body)
 			}
 			return false
 		}
@@ -250,7 +245,7 @@ func highlightModule(lines map[int]lsp.SemanticHighlightingTokens, mod *parser.M
 	})
 }
 
-func highlightBlock(lines map[int]lsp.SemanticHighlightingTokens, typ parser.ObjType, block *parser.BlockStmt) {
+func highlightBlock(lines map[int]lsp.SemanticHighlightingTokens, text string, typ parser.ObjType, block *parser.BlockStmt) {
 	parser.Inspect(block, func(node parser.Node) bool {
 		if node == nil {
 			return false
@@ -258,7 +253,17 @@ func highlightBlock(lines map[int]lsp.SemanticHighlightingTokens, typ parser.Obj
 
 		switch n := node.(type) {
 		case *parser.Comment:
-			highlightNode(lines, node, Comment)
+			highlightNode(lines, text, node, Comment)
+		case *parser.If:
+			highlightNode(lines, text, node, Keyword)
+		case *parser.Else:
+			highlightNode(lines, text, node, Keyword)
+		case *parser.For:
+			highlightNode(lines, text, node, Keyword)
+		case *parser.In:
+			highlightNode(lines, text, node, Keyword)
+		case *parser.ForStmt:
+			highlightNode(lines, text, n.Var, Variable)
 		case *parser.CallStmt:
 			var ident *parser.Ident
 			switch {
@@ -268,79 +273,83 @@ func highlightBlock(lines map[int]lsp.SemanticHighlightingTokens, typ parser.Obj
 				if ok {
 					_, ok = lookupByType.Func[ident.Name]
 					if !ok {
-						highlightNode(lines, ident, Variable)
+						highlightNode(lines, text, ident, Variable)
 					}
 				}
 			case n.Func.Selector != nil:
 				ident = n.Func.Selector.Ident
 				if ident != nil {
-					highlightNode(lines, ident, Module)
+					highlightNode(lines, text, ident, Module)
 				}
 				if n.Func.Selector.Select != nil {
-					highlightNode(lines, n.Func.Selector.Select, Variable)
+					highlightNode(lines, text, n.Func.Selector.Select, Variable)
 				}
 			default:
 				return true
 			}
 
 			for _, arg := range n.Args {
+				if arg.Named != nil {
+					highlightNode(lines, text, arg.Named.Name, Parameter)
+				}
+
 				switch {
 				case arg.Bad != nil:
 				case arg.Selector != nil:
 					if arg.Selector.Ident != nil {
-						highlightNode(lines, arg.Selector.Ident, Module)
+						highlightNode(lines, text, arg.Selector.Ident, Module)
 					}
 					if arg.Selector.Select != nil {
-						highlightNode(lines, arg.Selector.Select, Variable)
+						highlightNode(lines, text, arg.Selector.Select, Variable)
 					}
 				case arg.Ident != nil:
-					highlightNode(lines, arg.Ident, Variable)
+					highlightNode(lines, text, arg.Ident, Variable)
 				case arg.BasicLit != nil:
 					switch {
 					case arg.BasicLit.Str != nil:
-						highlightNode(lines, arg.BasicLit, String)
+						highlightNode(lines, text, arg.BasicLit, String)
 					case arg.BasicLit.Decimal != nil, arg.BasicLit.Numeric != nil:
-						highlightNode(lines, arg.BasicLit, Numeric)
+						highlightNode(lines, text, arg.BasicLit, Numeric)
 					case arg.BasicLit.Bool != nil:
-						highlightNode(lines, arg.BasicLit, Constant)
+						highlightNode(lines, text, arg.BasicLit, Constant)
 					}
 				case arg.FuncLit != nil:
 					if arg.FuncLit.Type != nil {
-						highlightNode(lines, arg.FuncLit.Type, Type)
+						highlightNode(lines, text, arg.FuncLit.Type, Type)
 					}
 
 					if arg.FuncLit.Type != nil && arg.FuncLit.Body != nil {
-						highlightBlock(lines, arg.FuncLit.Type.ObjType, arg.FuncLit.Body)
+						highlightBlock(lines, text, arg.FuncLit.Type.ObjType, arg.FuncLit.Body)
 					}
 				}
 			}
 
 			if n.WithOpt != nil {
 				if n.WithOpt.With != nil {
-					highlightNode(lines, n.WithOpt.With, Keyword)
+					highlightNode(lines, text, n.WithOpt.With, Keyword)
 				}
 
 				switch {
 				case n.WithOpt.Expr.Ident != nil:
 				case n.WithOpt.Expr.FuncLit != nil:
 					lit := n.WithOpt.Expr.FuncLit
-					highlightNode(lines, lit.Type, Type)
+					highlightNode(lines, text, lit.Type, Type)
 
 					if lit.Type.Primary() == parser.Option {
 						typ := parser.ObjType(fmt.Sprintf("%s::%s", lit.Type.Primary(), ident))
-						highlightBlock(lines, typ, lit.Body)
+						highlightBlock(lines, text, typ, lit.Body)
 					} else {
-						highlightBlock(lines, lit.Type.ObjType, lit.Body)
+						highlightBlock(lines, text, lit.Type.ObjType, lit.Body)
 					}
 				}
 			}
 
 			if n.Alias != nil {
 				if n.Alias.As != nil {
-					highlightNode(lines, n.Alias.As, Keyword)
+					highlightNode(lines, text, n.Alias.As, Keyword)
 				}
 				if n.Alias.Ident != nil {
-					highlightNode(lines, n.Alias.Ident, Function)
+					highlightNode(lines, text, n.Alias.Ident, Function)
 				}
 			}
 
@@ -350,11 +359,12 @@ func highlightBlock(lines map[int]lsp.SemanticHighlightingTokens, typ parser.Obj
 	})
 }
 
-func highlightNode(lines map[int]lsp.SemanticHighlightingTokens, node parser.Node, s Scope) {
-	line := node.Position().Line - 1
-	lines[line] = append(lines[line], lsp.SemanticHighlightingToken{
-		Character: uint32(node.Position().Column - 1),
-		Length:    uint16(node.End().Column - node.Position().Column),
+func highlightNode(lines map[int]lsp.SemanticHighlightingTokens, text string, node parser.Node, s Scope) {
+	start, end := node.Position(), node.End()
+	pos := newPosition(text, start)
+	lines[pos.Line] = append(lines[pos.Line], lsp.SemanticHighlightingToken{
+		Character: uint32(pos.Character),
+		Length:    uint16(utf16Count(substring(text, start.Offset, end.Offset-start.Offset))),
 		Scope:     uint16(s),
 	})
 }
@@ -368,32 +378,66 @@ func (ls *LangServer) textDocumentDidChangeHandler(ctx context.Context, params l
 	uri := params.TextDocument.URI
 	log.Printf("did change %q", uri)
 
+	// Changes are applied as they arrive because every incremental change
+	// depends on the text left by the ones before it.
+	ls.tmu.Lock()
+	td, ok := ls.tds[uri]
+	if !ok {
+		ls.tmu.Unlock()
+		return fmt.Errorf("unknown uri %q", uri)
+	}
+
+	for _, change := range params.ContentChanges {
+		td.Text = applyContentChange(td.Text, change)
+	}
+	td.stale = true
+	ls.tds[uri] = td
+	ls.tmu.Unlock()
+
+	// Parsing and checking waits until the changes settle, unless a request
+	// needs the module of the document in the meantime.
 	return ls.debounce(uri, 10*time.Millisecond, func() error {
-		ls.tmu.Lock()
-		defer ls.tmu.Unlock()
+		ls.tmu.RLock()
+		text := ls.tds[uri].Text
+		ls.tmu.RUnlock()
 
-		_, ok := ls.tds[uri]
-		if !ok {
-			return fmt.Errorf("unknown uri %q", uri)
+		td := NewTextDocument(uri, text)
+
+		// A change that arrived while parsing is published by its own
+		// callback instead.
+		ls.tmu.Lock()
+		if ls.tds[uri].Text != text {
+			ls.tmu.Unlock()
+			return nil
 		}
+		ls.tds[uri] = td
+		ls.tmu.Unlock()
 
-		for _, change := range params.ContentChanges {
-			td := NewTextDocument(uri, change.Text)
-			if _, ok := ls.capset[SemanticHighlightingCapability]; ok {
-				go func() {
-					err := ls.publishSemanticHighlighting(ctx, td)
-					if err != nil {
-						log.Printf("err: %s", err)
-					}
-				}()
+		go func() {
+			err := ls.publishDiagnostics(ctx, td)
+			if err != nil {
+				log.Printf("err: %s", err)
 			}
-
-			ls.tds[uri] = td
-		}
+		}()
 		return nil
 	})
 }
 
+// applyContentChange returns the text after a change. Changes without a range
+// replace the whole text.
+func applyContentChange(text string, change lsp.TextDocumentContentChangeEvent) string {
+	if change.Range == nil {
+		return change.Text
+	}
+
+	start := offsetAt(text, change.Range.Start)
+	end := offsetAt(text, change.Range.End)
+	if end < start {
+		end = start
+	}
+	return text[:start] + change.Text + text[end:]
+}
+
 type debouncer struct {
 	timer        *time.Timer
 	mu           sync.Mutex
@@ -461,32 +505,43 @@ func (ls *LangServer) textDocumentDefinitionHandler(ctx context.Context, params
 	uri := params.TextDocument.URI
 	log.Printf("text document definition %q", uri)
 
-	ls.tmu.RLock()
-	td, ok := ls.tds[uri]
-	if !ok {
-		ls.tmu.RUnlock()
-		return nil, fmt.Errorf("unknown uri %q", uri)
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
 	}
-	ls.tmu.RUnlock()
 
 	var loc *lsp.Location
-
 	pos := params.Position
 
 	parser.Inspect(td.Module, func(node parser.Node) bool {
-		if node == nil || !isPositionWithinNode(pos, node) {
+		if node == nil || !isPositionWithinNode(td.Text, pos, node) {
 			return false
 		}
 
 		switch n := node.(type) {
+		case *parser.ImportDecl:
+			if n.Ident == nil || isPositionWithinNode(td.Text, pos, n.Ident) {
+				return false
+			}
+
+			// Anywhere else in the import declaration leads to the source of the
+			// imported module.
+			var importTD TextDocument
+			importTD, err = ls.importTextDocument(ctx, uri, td.Module, n)
+			if err != nil {
+				return false
+			}
+
+			loc = &lsp.Location{URI: importTD.Identifier.URI}
+			return false
 		case *parser.ExportDecl:
-			if isPositionWithinNode(pos, n.Ident) {
-				loc = newLocationFromIdent(td.Module.Scope, uri, n.Ident.Name)
+			if isPositionWithinNode(td.Text, pos, n.Ident) {
+				loc = newLocationFromIdent(td, td.Module.Scope, n.Ident.Name)
 			}
 		case *parser.FuncDecl:
 			fun := n
 			parser.Inspect(fun, func(node parser.Node) bool {
-				if node == nil || !isPositionWithinNode(pos, node) {
+				if node == nil || !isPositionWithinNode(td.Text, pos, node) {
 					return false
 				}
 
@@ -498,9 +553,9 @@ func (ls *LangServer) textDocumentDefinitionHandler(ctx context.Context, params
 						case n.Ident != nil:
 							name = n.Ident.Name
 						case n.Selector != nil:
-							if isPositionWithinNode(pos, n.Selector.Ident) {
+							if isPositionWithinNode(td.Text, pos, n.Selector.Ident) {
 								name = n.Selector.Ident.Name
-							} else if isPositionWithinNode(pos, n.Selector.Select) {
+							} else if isPositionWithinNode(td.Text, pos, n.Selector.Select) {
 								obj := fun.Scope.Lookup(n.Selector.Ident.Name)
 								if obj == nil {
 									return false
@@ -511,59 +566,18 @@ func (ls *LangServer) textDocumentDefinitionHandler(ctx context.Context, params
 									return false
 								}
 
-								rootDir := filepath.Dir(strings.TrimPrefix(string(uri), "file://"))
-
-								var filename string
-
-								switch {
-								case decl.ImportFunc != nil:
-									cg, err := codegen.New()
-									if err != nil {
-										log.Printf("failed to create codegen: %s", err)
-										return false
-									}
-
-									st, err := cg.GenerateImport(ctx, td.Module.Scope, decl.ImportFunc.Func)
-									if err != nil {
-										log.Printf("failed to generate import: %s", err)
-										return false
-									}
-
-									def, err := st.Marshal(ctx, llb.LinuxAmd64)
-									if err != nil {
-										log.Printf("failed to marshal import vertex: %s", err)
-										return false
-									}
-
-									dgst := digest.FromBytes(def.Def[len(def.Def)-1])
-									vp := module.VendorPath(filepath.Join(rootDir, module.ModulesPath), dgst)
-									filename = filepath.Join(vp, module.ModuleFilename)
-								case decl.ImportPath != nil:
-									filename = filepath.Join(rootDir, decl.ImportPath.Path.Unquoted())
-								}
-
-								importUri := lsp.DocumentURI(fmt.Sprintf("file://%s", filename))
-
-								ls.tmu.Lock()
-								importTD, ok := ls.tds[importUri]
-								if !ok {
-									data, err := ioutil.ReadFile(filename)
-									if err != nil {
-										log.Printf("failed to read file: %s", err)
-										return false
-									}
-
-									importTD = NewTextDocument(importUri, string(data))
-									ls.tds[importUri] = importTD
+								var importTD TextDocument
+								importTD, err = ls.importTextDocument(ctx, uri, td.Module, decl)
+								if err != nil || importTD.Module == nil || importTD.Module.Scope == nil {
+									return false
 								}
-								ls.tmu.Unlock()
 
-								loc = newLocationFromIdent(importTD.Module.Scope, importUri, n.Selector.Select.Name)
+								loc = newLocationFromIdent(importTD, importTD.Module.Scope, n.Selector.Select.Name)
 								return false
 							}
 						}
 
-						loc = newLocationFromIdent(fun.Scope, uri, name)
+						loc = newLocationFromIdent(td, fun.Scope, name)
 						return false
 					case n.FuncLit != nil:
 						return true
@@ -581,12 +595,73 @@ func (ls *LangServer) textDocumentDefinitionHandler(ctx context.Context, params
 	var locs []lsp.Location
 	if loc != nil {
 		locs = append(locs, *loc)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to load import")
 	}
 
 	return locs, nil
 }
 
-func newLocationFromIdent(scope *parser.Scope, uri lsp.DocumentURI, name string) *lsp.Location {
+// importTextDocument returns the text document of the module imported by
+// decl, reading it from disk if it isn't open already.
+func (ls *LangServer) importTextDocument(ctx context.Context, uri lsp.DocumentURI, mod *parser.Module, decl *parser.ImportDecl) (TextDocument, error) {
+	rootDir := filepath.Dir(strings.TrimPrefix(string(uri), "file://"))
+
+	var filename string
+
+	switch {
+	case decl.ImportFunc != nil:
+		dgst, err := module.ImportDigest(ctx, mod.Scope, decl.ImportFunc.Func)
+		if err != nil {
+			return TextDocument{}, errors.Wrap(err, "failed to compute import digest")
+		}
+
+		vp := module.VendorPath(findModulesPath(rootDir), dgst)
+		filename = filepath.Join(vp, module.ModuleFilename)
+	case decl.ImportPath != nil:
+		filename = filepath.Join(rootDir, decl.ImportPath.Path.Unquoted())
+	}
+
+	importUri := lsp.DocumentURI(fmt.Sprintf("file://%s", filename))
+
+	ls.tmu.Lock()
+	defer ls.tmu.Unlock()
+
+	importTD, ok := ls.lookupTextDocument(importUri)
+	if !ok {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			if os.IsNotExist(err) && decl.ImportFunc != nil {
+				return importTD, errors.Errorf("missing module %q from vendor, run `hlb mod vendor --target %s %s` to vendor module", decl.Ident, decl.Ident, mod.Pos.Filename)
+			}
+			return importTD, errors.Wrap(err, "failed to read file")
+		}
+
+		importTD = NewTextDocument(importUri, string(data))
+		ls.tds[importUri] = importTD
+	}
+
+	return importTD, nil
+}
+
+// findModulesPath returns the modules path of the closest directory from dir
+// upwards that has vendored modules, or the modules path in dir if there are
+// none.
+func findModulesPath(dir string) string {
+	for parent := dir; ; parent = filepath.Dir(parent) {
+		modulesPath := filepath.Join(parent, module.ModulesPath)
+		if _, err := os.Stat(modulesPath); err == nil {
+			return modulesPath
+		}
+
+		if parent == filepath.Dir(parent) {
+			break
+		}
+	}
+	return filepath.Join(dir, module.ModulesPath)
+}
+
+func newLocationFromIdent(td TextDocument, scope *parser.Scope, name string) *lsp.Location {
 	obj := scope.Lookup(name)
 	if obj == nil {
 		return nil
@@ -597,18 +672,18 @@ func newLocationFromIdent(scope *parser.Scope, uri lsp.DocumentURI, name string)
 	case parser.DeclKind:
 		switch n := obj.Node.(type) {
 		case *parser.FuncDecl:
-			loc = newLocationFromNode(uri, n.Name)
+			loc = newLocationFromNode(td, n.Name)
 		case *parser.AliasDecl:
-			loc = newLocationFromNode(uri, n.Ident)
+			loc = newLocationFromNode(td, n.Ident)
 		case *parser.ImportDecl:
-			loc = newLocationFromNode(uri, n.Ident)
+			loc = newLocationFromNode(td, n.Ident)
 		default:
 			log.Printf("%s unknown decl kind", checker.FormatPos(n.Position()))
 		}
 	case parser.FieldKind, parser.ExprKind:
 		switch n := obj.Node.(type) {
 		case *parser.Field:
-			loc = newLocationFromNode(uri, n.Name)
+			loc = newLocationFromNode(td, n.Name)
 		default:
 			log.Printf("%s unknown decl kind", checker.FormatPos(n.Position()))
 		}
@@ -618,87 +693,129 @@ func newLocationFromIdent(scope *parser.Scope, uri lsp.DocumentURI, name string)
 }
 
 func (ls *LangServer) textDocumentHoverHandler(ctx context.Context, params lsp.TextDocumentPositionParams) (*lsp.Hover, error) {
-	ls.tmu.Lock()
 	uri := params.TextDocument.URI
-	td, ok := ls.tds[uri]
-	if !ok {
-		ls.tmu.Unlock()
-		return nil, fmt.Errorf("unknown uri %q", uri)
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var h lsp.Hover
+	if td.Module == nil {
+		return &h, nil
 	}
-	ls.tmu.Unlock()
 
 	pos := params.Position
+	typ, fun := blockTypeAt(td, pos)
 
 	var (
-		h   lsp.Hover
-		typ parser.ObjType
+		ident    *parser.Ident
+		selector *parser.Selector
 	)
 
 	parser.Inspect(td.Module, func(node parser.Node) bool {
-		if node == nil || !isPositionWithinNode(pos, node) {
+		if node == nil || !isPositionWithinNode(td.Text, pos, node) {
 			return false
 		}
 
 		switch n := node.(type) {
-		case *parser.FuncDecl:
-			if n.Type != nil {
-				typ = n.Type.ObjType
-			}
-		case *parser.FuncLit:
-			if n.Type != nil {
-				typ = n.Type.ObjType
-			}
+		case *parser.Selector:
+			selector = n
 		case *parser.Ident:
-			r := newRangeFromNode(node)
-			h.Range = &r
+			ident = n
+		}
+		return true
+	})
 
-			lookupByType, ok := builtin.Lookup.ByType[typ]
-			if !ok {
-				return false
-			}
+	if ident == nil {
+		return &h, nil
+	}
 
-			_, ok = lookupByType.Func[n.Name]
-			if !ok {
-				return false
-			}
+	var signature, doc string
+	switch {
+	case selector != nil && selector.Select == ident:
+		// Selected functions are declared in the imported module.
+		decl := findImportDecl(td.Module, selector.Ident.Name)
+		if decl == nil {
+			break
+		}
 
-			h.Contents = []lsp.MarkedString{
-				{
-					Language: "hlb",
-					Value:    n.Name,
-				},
-			}
+		importTD, err := ls.importTextDocument(ctx, uri, td.Module, decl)
+		if err != nil {
+			return nil, err
 		}
-		return true
-	})
-	return &h, nil
-}
 
-func (ls *LangServer) textDocumentCompletionHandler(ctx context.Context, params lsp.CompletionParams) (*lsp.CompletionList, error) {
-	return nil, nil
-}
+		if importFun := findFuncDecl(importTD.Module, ident.Name); importFun != nil {
+			signature = fmt.Sprintf("%s %s%s", importFun.Type, ident.Name, importFun.Params)
+			doc = docString(importFun.Doc)
+		}
+	default:
+		if lookup, ok := builtin.Lookup.ByType[typ].Func[ident.Name]; ok {
+			signature = fmt.Sprintf("%s %s%s", typ, ident.Name, parser.NewFieldList(lookup.Params...))
+			doc = lookup.Doc
+			break
+		}
+
+		scope := td.Module.Scope
+		if fun != nil && fun.Scope != nil {
+			scope = fun.Scope
+		}
+		if scope == nil {
+			break
+		}
+
+		obj := scope.Lookup(ident.Name)
+		if obj == nil {
+			break
+		}
+
+		switch n := obj.Node.(type) {
+		case *parser.FuncDecl:
+			signature = fmt.Sprintf("%s %s%s", n.Type, ident.Name, n.Params)
+			doc = docString(n.Doc)
+		case *parser.AliasDecl:
+			signature = fmt.Sprintf("%s %s%s", n.Func.Type, ident.Name, n.Func.Params)
+		case *parser.ImportDecl:
+			signature = n.String()
+		case *parser.Field:
+			signature = n.String()
+		}
+	}
 
-func isPositionWithinNode(pos lsp.Position, node parser.Node) bool {
-	if (pos.Line < node.Position().Line-1 || pos.Line > node.End().Line-1) ||
-		(pos.Line == node.Position().Line-1 && pos.Character < node.Position().Column-1) ||
-		(pos.Line == node.End().Line-1 && pos.Character >= node.End().Column-1) {
-		return false
+	if signature == "" {
+		return &h, nil
 	}
 
-	return true
+	r := newRangeFromNode(td.Text, ident)
+	h.Range = &r
+	h.Contents = []lsp.MarkedString{
+		{
+			Language: "hlb",
+			Value:    signature,
+		},
+	}
+	if doc != "" {
+		h.Contents = append(h.Contents, lsp.RawMarkedString(doc))
+	}
+
+	return &h, nil
 }
 
-func newLocationFromNode(uri lsp.DocumentURI, node parser.Node) *lsp.Location {
+func isPositionWithinNode(text string, pos lsp.Position, node parser.Node) bool {
+	r := newRangeFromNode(text, node)
+	return !isPositionBefore(pos, r.Start) && isPositionBefore(pos, r.End)
+}
+
+func newLocationFromNode(td TextDocument, node parser.Node) *lsp.Location {
 	return &lsp.Location{
-		URI:   uri,
-		Range: newRangeFromNode(node),
+		URI:   td.Identifier.URI,
+		Range: newRangeFromNode(td.Text, node),
 	}
 }
 
-func newRangeFromNode(node parser.Node) lsp.Range {
+func newRangeFromNode(text string, node parser.Node) lsp.Range {
 	return lsp.Range{
-		Start: lsp.Position{Line: node.Position().Line - 1, Character: node.Position().Column - 1},
-		End:   lsp.Position{Line: node.End().Line - 1, Character: node.End().Column - 1},
+		Start: newPosition(text, node.Position()),
+		End:   newPosition(text, node.End()),
 	}
 }
 
@@ -707,6 +824,33 @@ type TextDocument struct {
 	Module     *parser.Module
 	Text       string
 	Err        error
+
+	// stale is true when Text changed since Module was parsed from it.
+	stale bool
+}
+
+// textDocument returns the open document of uri with a module parsed from its
+// current text.
+func (ls *LangServer) textDocument(uri lsp.DocumentURI) (TextDocument, error) {
+	ls.tmu.Lock()
+	defer ls.tmu.Unlock()
+
+	td, ok := ls.lookupTextDocument(uri)
+	if !ok {
+		return td, fmt.Errorf("unknown uri %q", uri)
+	}
+	return td, nil
+}
+
+// lookupTextDocument returns the open document of uri, parsing its text first
+// if the module is stale. The caller must hold tmu.
+func (ls *LangServer) lookupTextDocument(uri lsp.DocumentURI) (TextDocument, bool) {
+	td, ok := ls.tds[uri]
+	if ok && td.stale {
+		td = NewTextDocument(uri, td.Text)
+		ls.tds[uri] = td
+	}
+	return td, ok
 }
 
 func NewTextDocument(uri lsp.DocumentURI, text string) TextDocument {
@@ -726,6 +870,9 @@ func NewTextDocument(uri lsp.DocumentURI, text string) TextDocument {
 	}
 	td.Module.Pos.Filename = strings.TrimPrefix(string(uri), "file://")
 
+	// Doc comments are shown by hover, completion and signature help.
+	parser.AssignDocStrings(td.Module)
+
 	td.Err = checker.Check(td.Module)
 	if td.Err != nil {
 		log.Printf("failed to check hlb: %s", td.Err)
@@ -0,0 +1,270 @@
+package langserver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyContentChange(t *testing.T) {
+	t.Parallel()
+
+	text := "fs default() {\n\tscratch\n}\n"
+
+	type testCase struct {
+		name     string
+		change   lsp.TextDocumentContentChangeEvent
+		expected string
+	}
+
+	for _, tc := range []testCase{{
+		"full text",
+		lsp.TextDocumentContentChangeEvent{
+			Text: "fs foo() {}\n",
+		},
+		"fs foo() {}\n",
+	}, {
+		"insert",
+		lsp.TextDocumentContentChangeEvent{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 1, Character: 8},
+				End:   lsp.Position{Line: 1, Character: 8},
+			},
+			Text: "\n\tmkdir \"/etc\" 0o755",
+		},
+		"fs default() {\n\tscratch\n\tmkdir \"/etc\" 0o755\n}\n",
+	}, {
+		"replace",
+		lsp.TextDocumentContentChangeEvent{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 0, Character: 3},
+				End:   lsp.Position{Line: 0, Character: 10},
+			},
+			Text: "build",
+		},
+		"fs build() {\n\tscratch\n}\n",
+	}, {
+		"delete across lines",
+		lsp.TextDocumentContentChangeEvent{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 0, Character: 14},
+				End:   lsp.Position{Line: 2, Character: 0},
+			},
+		},
+		"fs default() {}\n",
+	}, {
+		"reversed range",
+		lsp.TextDocumentContentChangeEvent{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 0, Character: 10},
+				End:   lsp.Position{Line: 0, Character: 3},
+			},
+			Text: "build",
+		},
+		"fs defaultbuild() {\n\tscratch\n}\n",
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, applyContentChange(text, tc.change))
+		})
+	}
+}
+
+func TestStaleTextDocument(t *testing.T) {
+	t.Parallel()
+
+	uri := lsp.DocumentURI("file:///build.hlb")
+	text := "fs base() {\n\timage \"alpine\"\n}\n\nfs default() {\n\tscratch\n}\n"
+
+	// Requests made before the changes to a document settle see a module
+	// parsed from the changed text.
+	td := NewTextDocument(uri, "fs default() {\n\tscratch\n}\n")
+	td.Text = text
+	td.stale = true
+
+	ls := NewServer()
+	ls.tds[uri] = td
+
+	expected := NewTextDocument(uri, text)
+
+	tokens, err := ls.textDocumentSemanticTokensFullHandler(context.Background(), SemanticTokensParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	})
+	require.NoError(t, err)
+	require.Equal(t, encodeSemanticTokens(expected), tokens.Data)
+
+	ranges, err := ls.textDocumentFoldingRangeHandler(context.Background(), FoldingRangeParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+	})
+	require.NoError(t, err)
+	require.Equal(t, foldingRanges(expected.Module, expected.Text), ranges)
+	require.Len(t, ranges, 2)
+}
+
+func TestDefinition(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := writeModules(t)
+	defer cleanup()
+
+	uri := lsp.DocumentURI("file://" + filepath.Join(dir, "build.hlb"))
+	utilURI := lsp.DocumentURI("file://" + filepath.Join(dir, "util.hlb"))
+
+	type testCase struct {
+		name     string
+		pos      lsp.Position
+		expected []lsp.Location
+	}
+
+	for _, tc := range []testCase{{
+		"function",
+		lsp.Position{Line: 4, Character: 2},
+		[]lsp.Location{{
+			URI:   uri,
+			Range: lsp.Range{Start: lsp.Position{Line: 9, Character: 3}, End: lsp.Position{Line: 9, Character: 7}},
+		}},
+	}, {
+		"import of selector",
+		lsp.Position{Line: 3, Character: 2},
+		[]lsp.Location{{
+			URI:   uri,
+			Range: lsp.Range{Start: lsp.Position{Line: 0, Character: 7}, End: lsp.Position{Line: 0, Character: 11}},
+		}},
+	}, {
+		"imported function",
+		lsp.Position{Line: 3, Character: 7},
+		[]lsp.Location{{
+			URI:   utilURI,
+			Range: lsp.Range{Start: lsp.Position{Line: 3, Character: 3}, End: lsp.Position{Line: 3, Character: 8}},
+		}},
+	}, {
+		"import path",
+		lsp.Position{Line: 0, Character: 14},
+		[]lsp.Location{{URI: utilURI}},
+	}, {
+		"builtin",
+		lsp.Position{Line: 10, Character: 2},
+		nil,
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, buildModule)
+			require.NoError(t, ls.tds[uri].Err)
+
+			locs, err := ls.textDocumentDefinitionHandler(context.Background(), lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     tc.pos,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, locs)
+		})
+	}
+}
+
+func TestHover(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanup := writeModules(t)
+	defer cleanup()
+
+	uri := lsp.DocumentURI("file://" + filepath.Join(dir, "build.hlb"))
+
+	type testCase struct {
+		name     string
+		pos      lsp.Position
+		expected lsp.Hover
+	}
+
+	for _, tc := range []testCase{{
+		"function",
+		lsp.Position{Line: 4, Character: 2},
+		lsp.Hover{
+			Contents: []lsp.MarkedString{
+				{Language: "hlb", Value: "fs base(string ref)"},
+				lsp.RawMarkedString("Returns the image\nof ref."),
+			},
+			Range: &lsp.Range{Start: lsp.Position{Line: 4, Character: 1}, End: lsp.Position{Line: 4, Character: 5}},
+		},
+	}, {
+		"imported function",
+		lsp.Position{Line: 3, Character: 7},
+		lsp.Hover{
+			Contents: []lsp.MarkedString{
+				{Language: "hlb", Value: "fs build(string ref)"},
+				lsp.RawMarkedString("Builds the image."),
+			},
+			Range: &lsp.Range{Start: lsp.Position{Line: 3, Character: 6}, End: lsp.Position{Line: 3, Character: 11}},
+		},
+	}, {
+		"import",
+		lsp.Position{Line: 3, Character: 2},
+		lsp.Hover{
+			Contents: []lsp.MarkedString{
+				{Language: "hlb", Value: `import util "./util.hlb"`},
+			},
+			Range: &lsp.Range{Start: lsp.Position{Line: 3, Character: 1}, End: lsp.Position{Line: 3, Character: 5}},
+		},
+	}, {
+		"param",
+		lsp.Position{Line: 10, Character: 8},
+		lsp.Hover{
+			Contents: []lsp.MarkedString{
+				{Language: "hlb", Value: "string ref"},
+			},
+			Range: &lsp.Range{Start: lsp.Position{Line: 10, Character: 7}, End: lsp.Position{Line: 10, Character: 10}},
+		},
+	}, {
+		"outside of identifiers",
+		lsp.Position{Line: 1, Character: 0},
+		lsp.Hover{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, buildModule)
+			require.NoError(t, ls.tds[uri].Err)
+
+			h, err := ls.textDocumentHoverHandler(context.Background(), lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     tc.pos,
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, *h)
+		})
+	}
+}
+
+var buildModule = `import util "./util.hlb"
+
+fs default() {
+	util.build "alpine"
+	base "busybox"
+}
+
+# Returns the image
+# of ref.
+fs base(string ref) {
+	image ref
+}
+`
+
+// writeModules writes buildModule and the module it imports to a temporary
+// directory. It returns the directory and a function that removes it.
+func writeModules(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "hlb-langserver")
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "build.hlb"), []byte(buildModule), 0644)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "util.hlb"), []byte("export build\n\n# Builds the image.\nfs build(string ref) {\n\timage ref\n}\n"), 0644)
+	require.NoError(t, err)
+
+	return dir, func() { os.RemoveAll(dir) }
+}
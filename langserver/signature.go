@@ -0,0 +1,195 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/openllb/hlb/builtin"
+	"github.com/openllb/hlb/parser"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+func (ls *LangServer) textDocumentSignatureHelpHandler(ctx context.Context, params lsp.TextDocumentPositionParams) (*lsp.SignatureHelp, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document signature help %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if td.Module == nil {
+		return nil, nil
+	}
+
+	pos := params.Position
+	call := callStmtAt(td, pos)
+	if call == nil {
+		return nil, nil
+	}
+
+	var (
+		typ    parser.ObjType
+		name   string
+		fields []*parser.Field
+		doc    string
+	)
+
+	switch {
+	case call.Func.Ident != nil:
+		name = call.Func.Ident.Name
+
+		blockTyp, _ := blockTypeAt(td, pos)
+		if lookup, ok := builtin.Lookup.ByType[blockTyp].Func[name]; ok {
+			typ, fields, doc = blockTyp, lookup.Params, lookup.Doc
+			break
+		}
+
+		fun := findFuncDecl(td.Module, name)
+		if fun == nil {
+			return nil, nil
+		}
+		typ, fields, doc = fun.Type.ObjType, fun.Params.List, docString(fun.Doc)
+	case call.Func.Selector != nil:
+		if td.Module.Scope == nil {
+			return nil, nil
+		}
+
+		decl := findImportDecl(td.Module, call.Func.Selector.Ident.Name)
+		if decl == nil || call.Func.Selector.Select == nil {
+			return nil, nil
+		}
+
+		importTD, err := ls.importTextDocument(ctx, uri, td.Module, decl)
+		if err != nil {
+			return nil, err
+		}
+
+		name = call.Func.Selector.Select.Name
+		fun := findFuncDecl(importTD.Module, name)
+		if fun == nil {
+			return nil, nil
+		}
+		typ, fields, doc = fun.Type.ObjType, fun.Params.List, docString(fun.Doc)
+	default:
+		return nil, nil
+	}
+
+	info := lsp.SignatureInformation{
+		Label:         fmt.Sprintf("%s %s%s", typ, name, parser.NewFieldList(fields...)),
+		Documentation: doc,
+	}
+	for _, field := range fields {
+		info.Parameters = append(info.Parameters, lsp.ParameterInformation{
+			Label: field.String(),
+		})
+	}
+
+	return &lsp.SignatureHelp{
+		Signatures:      []lsp.SignatureInformation{info},
+		ActiveParameter: activeParameter(td.Text, fields, call.Args, pos),
+	}, nil
+}
+
+// callStmtAt returns the innermost call statement whose arguments are being
+// typed at pos. The cursor may also be past the last argument on the same
+// line, but not inside the call's `with` option.
+func callStmtAt(td TextDocument, pos lsp.Position) *parser.CallStmt {
+	var found *parser.CallStmt
+	parser.Inspect(td.Module, func(node parser.Node) bool {
+		if node == nil {
+			return false
+		}
+
+		call, ok := node.(*parser.CallStmt)
+		if !ok {
+			return isPositionWithinNode(td.Text, pos, node)
+		}
+
+		if call.Func == nil || !isPositionAfterNode(td.Text, pos, call.Func) {
+			return isPositionWithinNode(td.Text, pos, node)
+		}
+
+		if call.WithOpt != nil && !isPositionBeforeNode(td.Text, pos, call.WithOpt) {
+			return true
+		}
+
+		if isPositionWithinNode(td.Text, pos, call) || newPosition(td.Text, call.End()).Line == pos.Line {
+			found = call
+		}
+		return true
+	})
+	return found
+}
+
+// activeParameter returns the index of the parameter the argument at pos is
+// bound to, binding named and variadic arguments the same way as the checker.
+func activeParameter(text string, fields []*parser.Field, args []*parser.Expr, pos lsp.Position) int {
+	index := 0
+	for _, arg := range args {
+		if isPositionAfterNode(text, pos, arg) {
+			index++
+		}
+	}
+
+	var (
+		variadic   = -1
+		positional = len(fields)
+	)
+	if len(fields) > 0 && fields[len(fields)-1].Variadic != nil {
+		variadic = len(fields) - 1
+		positional = variadic
+	}
+
+	if index < len(args) && args[index].Named != nil {
+		for i, field := range fields {
+			if field.Name.Name == args[index].Named.Name.Name {
+				return i
+			}
+		}
+		return len(fields)
+	}
+
+	for i := 0; i < index && i < len(args); i++ {
+		// Positional args cannot follow named args, so there is no parameter
+		// to highlight.
+		if args[i].Named != nil {
+			return len(fields)
+		}
+	}
+
+	switch {
+	case index < positional:
+		return index
+	case variadic >= 0:
+		return variadic
+	default:
+		return len(fields)
+	}
+}
+
+func findFuncDecl(mod *parser.Module, name string) *parser.FuncDecl {
+	if mod == nil {
+		return nil
+	}
+
+	for _, decl := range mod.Decls {
+		fun := decl.Func
+		if fun != nil && fun.Name != nil && fun.Name.Name == name && fun.Type != nil && fun.Params != nil {
+			return fun
+		}
+	}
+	return nil
+}
+
+// isPositionAfterNode returns true if pos is separated from the end of node.
+func isPositionAfterNode(text string, pos lsp.Position, node parser.Node) bool {
+	end := newPosition(text, node.End())
+	return pos.Line > end.Line || (pos.Line == end.Line && pos.Character > end.Character)
+}
+
+// isPositionBeforeNode returns true if pos is before the start of node.
+func isPositionBeforeNode(text string, pos lsp.Position, node parser.Node) bool {
+	return isPositionBefore(pos, newPosition(text, node.Position()))
+}
@@ -0,0 +1,102 @@
+package langserver
+
+import (
+	"context"
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	t.Parallel()
+
+	uri := lsp.DocumentURI("file:///build.hlb")
+	text := `fs default() {
+	image "alpine"
+	build "golang" "1.14" version="1.15"
+	echo "\U0001F600" "hi"
+	scratch
+}
+
+fs build(string ref, string tag, string version) {
+	image ref
+}
+
+fs echo(variadic string args) {
+	run "echo" with option {
+		env "ARGS" "x"
+	}
+}
+`
+
+	type testCase struct {
+		name   string
+		pos    lsp.Position
+		label  string
+		active int
+	}
+
+	for _, tc := range []testCase{{
+		"builtin",
+		lsp.Position{Line: 1, Character: 8},
+		"fs image(string ref)",
+		0,
+	}, {
+		"function",
+		lsp.Position{Line: 2, Character: 17},
+		"fs build(string ref, string tag, string version)",
+		1,
+	}, {
+		"named arg",
+		lsp.Position{Line: 2, Character: 25},
+		"fs build(string ref, string tag, string version)",
+		2,
+	}, {
+		"past last arg",
+		lsp.Position{Line: 2, Character: 39},
+		"fs build(string ref, string tag, string version)",
+		3,
+	}, {
+		"variadic param",
+		lsp.Position{Line: 3, Character: 14},
+		"fs echo(variadic string args)",
+		0,
+	}, {
+		"call without args",
+		lsp.Position{Line: 4, Character: 4},
+		"",
+		0,
+	}, {
+		"call in option block",
+		lsp.Position{Line: 13, Character: 8},
+		"option::run env(string key, string value)",
+		0,
+	}, {
+		"outside of calls",
+		lsp.Position{Line: 7, Character: 2},
+		"",
+		0,
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ls := NewServer()
+			ls.tds[uri] = NewTextDocument(uri, text)
+			require.NoError(t, ls.tds[uri].Err)
+
+			help, err := ls.textDocumentSignatureHelpHandler(context.Background(), lsp.TextDocumentPositionParams{
+				TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+				Position:     tc.pos,
+			})
+			require.NoError(t, err)
+			if tc.label == "" {
+				require.Nil(t, help)
+				return
+			}
+			require.NotNil(t, help)
+			require.Len(t, help.Signatures, 1)
+			require.Equal(t, tc.label, help.Signatures[0].Label)
+			require.Equal(t, tc.active, help.ActiveParameter)
+		})
+	}
+}
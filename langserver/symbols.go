@@ -0,0 +1,311 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/openllb/hlb"
+	"github.com/openllb/hlb/parser"
+	"github.com/pkg/errors"
+	lsp "github.com/sourcegraph/go-lsp"
+)
+
+var (
+	// heredocOperator matches the operator and identifier starting a heredoc.
+	heredocOperator = regexp.MustCompile(`^<<[-~]?([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+	errLimitReached = errors.New("limit reached")
+)
+
+func (ls *LangServer) textDocumentDocumentSymbolHandler(ctx context.Context, params lsp.DocumentSymbolParams) (interface{}, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document document symbol %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := documentSymbols(td)
+
+	if _, ok := ls.capset[HierarchicalDocumentSymbolCapability]; ok {
+		return symbols, nil
+	}
+
+	// Clients without support for hierarchical symbols expect a flat list.
+	infos := []lsp.SymbolInformation{}
+	var flatten func(symbols []DocumentSymbol, container string)
+	flatten = func(symbols []DocumentSymbol, container string) {
+		for _, symbol := range symbols {
+			infos = append(infos, lsp.SymbolInformation{
+				Name:          symbol.Name,
+				Kind:          symbol.Kind,
+				Location:      lsp.Location{URI: uri, Range: symbol.Range},
+				ContainerName: container,
+			})
+			flatten(symbol.Children, symbol.Name)
+		}
+	}
+	flatten(symbols, "")
+
+	return infos, nil
+}
+
+func (ls *LangServer) workspaceSymbolHandler(ctx context.Context, params lsp.WorkspaceSymbolParams) ([]lsp.SymbolInformation, error) {
+	log.Printf("workspace symbol %q", params.Query)
+
+	infos := []lsp.SymbolInformation{}
+	if ls.rootURI == "" {
+		return infos, nil
+	}
+
+	query := strings.ToLower(params.Query)
+	root := strings.TrimPrefix(string(ls.rootURI), "file://")
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			// Skip hidden directories like .git and the vendored modules in
+			// .hlb, which are not part of the workspace.
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".hlb" {
+			return nil
+		}
+
+		uri := lsp.DocumentURI(fmt.Sprintf("file://%s", path))
+		td, err := ls.workspaceDocument(uri, path)
+		if err != nil {
+			log.Printf("failed to parse %q: %s", path, err)
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		for _, symbol := range documentSymbols(td) {
+			if !strings.Contains(strings.ToLower(symbol.Name), query) {
+				continue
+			}
+
+			infos = append(infos, lsp.SymbolInformation{
+				Name:          symbol.Name,
+				Kind:          symbol.Kind,
+				Location:      lsp.Location{URI: uri, Range: symbol.Range},
+				ContainerName: rel,
+			})
+
+			if params.Limit > 0 && len(infos) >= params.Limit {
+				return errLimitReached
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errLimitReached {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+// workspaceDocument returns an open document, or parses the file from disk if
+// it isn't open.
+func (ls *LangServer) workspaceDocument(uri lsp.DocumentURI, filename string) (TextDocument, error) {
+	ls.tmu.Lock()
+	td, ok := ls.lookupTextDocument(uri)
+	ls.tmu.Unlock()
+	if ok {
+		return td, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return td, err
+	}
+
+	td.Identifier.URI = uri
+	td.Text = string(data)
+	td.Module, _, err = hlb.Parse(strings.NewReader(td.Text))
+	return td, err
+}
+
+// documentSymbols returns the imports, exports and functions declared in a
+// document, with the parameters of a function as its children.
+func documentSymbols(td TextDocument) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	if td.Module == nil {
+		return symbols
+	}
+
+	for _, decl := range td.Module.Decls {
+		switch {
+		case decl.Import != nil:
+			imp := decl.Import
+			if imp.Ident == nil {
+				continue
+			}
+
+			var detail string
+			switch {
+			case imp.ImportPath != nil:
+				detail = imp.ImportPath.Path.Unquoted()
+			case imp.ImportFunc != nil:
+				detail = "from fs"
+			}
+
+			symbols = append(symbols, DocumentSymbol{
+				Name:           imp.Ident.Name,
+				Detail:         detail,
+				Kind:           lsp.SKModule,
+				Range:          newRangeFromNode(td.Text, imp),
+				SelectionRange: newRangeFromNode(td.Text, imp.Ident),
+			})
+		case decl.Export != nil:
+			exp := decl.Export
+			if exp.Ident == nil {
+				continue
+			}
+
+			symbols = append(symbols, DocumentSymbol{
+				Name:           exp.Ident.Name,
+				Detail:         "export",
+				Kind:           lsp.SKKey,
+				Range:          newRangeFromNode(td.Text, exp),
+				SelectionRange: newRangeFromNode(td.Text, exp.Ident),
+			})
+		case decl.Func != nil:
+			fun := decl.Func
+			if fun.Type == nil || fun.Name == nil {
+				continue
+			}
+
+			var params []*parser.Field
+			if fun.Params != nil {
+				params = fun.Params.List
+			}
+
+			symbol := DocumentSymbol{
+				Name:           fun.Name.Name,
+				Detail:         fmt.Sprintf("%s %s%s", fun.Type, fun.Name.Name, parser.NewFieldList(params...)),
+				Kind:           lsp.SKFunction,
+				Range:          newRangeFromNode(td.Text, fun),
+				SelectionRange: newRangeFromNode(td.Text, fun.Name),
+			}
+
+			for _, param := range params {
+				if param.Name == nil {
+					continue
+				}
+
+				symbol.Children = append(symbol.Children, DocumentSymbol{
+					Name:           param.Name.Name,
+					Detail:         param.String(),
+					Kind:           lsp.SKVariable,
+					Range:          newRangeFromNode(td.Text, param),
+					SelectionRange: newRangeFromNode(td.Text, param.Name),
+				})
+			}
+
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols
+}
+
+func (ls *LangServer) textDocumentFoldingRangeHandler(ctx context.Context, params FoldingRangeParams) ([]FoldingRange, error) {
+	uri := params.TextDocument.URI
+	log.Printf("text document folding range %q", uri)
+
+	td, err := ls.textDocument(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return foldingRanges(td.Module, td.Text), nil
+}
+
+// foldingRanges returns the ranges of blocks, heredocs and comment groups
+// spanning multiple lines. Blocks and heredocs are folded up to the line
+// before their closing brace or terminator so that it stays visible.
+func foldingRanges(mod *parser.Module, text string) []FoldingRange {
+	ranges := []FoldingRange{}
+	if mod == nil {
+		return ranges
+	}
+
+	add := func(start, end int, kind FoldingRangeKind) {
+		if end > start {
+			ranges = append(ranges, FoldingRange{StartLine: start, EndLine: end, Kind: kind})
+		}
+	}
+
+	lines := strings.Split(text, "\n")
+
+	parser.Inspect(mod, func(node parser.Node) bool {
+		if node == nil {
+			return false
+		}
+
+		switch n := node.(type) {
+		case *parser.BlockStmt:
+			if n.CloseBrace == nil {
+				break
+			}
+			add(n.Position().Line-1, n.CloseBrace.Position().Line-2, FRKRegion)
+		case *parser.BasicLit:
+			if n.HereDoc == nil {
+				break
+			}
+			add(n.Position().Line-1, heredocEndLine(text, lines, n)-1, FRKRegion)
+		case *parser.CommentGroup:
+			add(n.Position().Line-1, n.End().Line-1, FRKComment)
+			return false
+		}
+		return true
+	})
+
+	return ranges
+}
+
+// heredocEndLine returns the 0-based line of the identifier terminating the
+// heredoc of lit. The end position of a heredoc doesn't account for its lines,
+// so the terminator is found in the text instead.
+func heredocEndLine(text string, lines []string, lit *parser.BasicLit) int {
+	start := lit.Position().Line - 1
+	if start < 0 || start >= len(lines) {
+		return start
+	}
+
+	offset := lit.Position().Offset
+	if offset > len(text) {
+		return start
+	}
+
+	m := heredocOperator.FindStringSubmatch(text[offset:])
+	if m == nil {
+		return start
+	}
+
+	for i := start + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == m[1] {
+			return i
+		}
+	}
+	return start
+}
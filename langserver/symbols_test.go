@@ -0,0 +1,121 @@
+package langserver
+
+import (
+	"testing"
+
+	lsp "github.com/sourcegraph/go-lsp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentSymbols(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		input    string
+		expected []DocumentSymbol
+	}
+
+	for _, tc := range []testCase{{
+		"declarations",
+		`import util from fs {
+	image "util"
+}
+
+export build
+
+# Builds the image.
+fs build(string ref, variadic string tags) {
+	image ref
+}
+`,
+		[]DocumentSymbol{{
+			Name:           "util",
+			Detail:         "from fs",
+			Kind:           lsp.SKModule,
+			Range:          lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 2, Character: 1}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: 0, Character: 7}, End: lsp.Position{Line: 0, Character: 11}},
+		}, {
+			Name:           "build",
+			Detail:         "export",
+			Kind:           lsp.SKKey,
+			Range:          lsp.Range{Start: lsp.Position{Line: 4, Character: 0}, End: lsp.Position{Line: 4, Character: 12}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: 4, Character: 7}, End: lsp.Position{Line: 4, Character: 12}},
+		}, {
+			Name:           "build",
+			Detail:         "fs build(string ref, variadic string tags)",
+			Kind:           lsp.SKFunction,
+			Range:          lsp.Range{Start: lsp.Position{Line: 7, Character: 0}, End: lsp.Position{Line: 9, Character: 1}},
+			SelectionRange: lsp.Range{Start: lsp.Position{Line: 7, Character: 3}, End: lsp.Position{Line: 7, Character: 8}},
+			Children: []DocumentSymbol{{
+				Name:           "ref",
+				Detail:         "string ref",
+				Kind:           lsp.SKVariable,
+				Range:          lsp.Range{Start: lsp.Position{Line: 7, Character: 9}, End: lsp.Position{Line: 7, Character: 19}},
+				SelectionRange: lsp.Range{Start: lsp.Position{Line: 7, Character: 16}, End: lsp.Position{Line: 7, Character: 19}},
+			}, {
+				Name:           "tags",
+				Detail:         "variadic string tags",
+				Kind:           lsp.SKVariable,
+				Range:          lsp.Range{Start: lsp.Position{Line: 7, Character: 21}, End: lsp.Position{Line: 7, Character: 41}},
+				SelectionRange: lsp.Range{Start: lsp.Position{Line: 7, Character: 37}, End: lsp.Position{Line: 7, Character: 41}},
+			}},
+		}},
+	}, {
+		"empty",
+		"",
+		[]DocumentSymbol{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			td := NewTextDocument("file:///build.hlb", tc.input)
+			require.NoError(t, td.Err)
+			require.Equal(t, tc.expected, documentSymbols(td))
+		})
+	}
+}
+
+func TestFoldingRanges(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		input    string
+		expected []FoldingRange
+	}
+
+	for _, tc := range []testCase{{
+		"blocks, heredocs and comments",
+		`# Builds
+# the image.
+fs build() {
+	run "alpine" with option {
+		env "A" "B"
+	}
+	mkfile "x" 0o644 <<-EOM
+		hello
+		world
+	EOM
+}
+`,
+		[]FoldingRange{
+			{StartLine: 0, EndLine: 1, Kind: FRKComment},
+			{StartLine: 2, EndLine: 9, Kind: FRKRegion},
+			{StartLine: 3, EndLine: 4, Kind: FRKRegion},
+			{StartLine: 6, EndLine: 8, Kind: FRKRegion},
+		},
+	}, {
+		"single lines",
+		`# Builds the image.
+fs build() { image "alpine"; }
+`,
+		[]FoldingRange{},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			td := NewTextDocument("file:///build.hlb", tc.input)
+			require.NoError(t, td.Err)
+			require.Equal(t, tc.expected, foldingRanges(td.Module, td.Text))
+		})
+	}
+}
@@ -81,7 +81,7 @@ define(function (require, exports, module) {
             },
             {
                "token": "variable.language",
-               "regex": "(\\b(with|as|variadic)\\b)"
+               "regex": "(\\b(with|as|variadic|if|else|for|in)\\b)"
             },
             {
                "token": ["entity.name.type", "punctuation"],
@@ -36,7 +36,7 @@ module Rouge
             groups Punctuation, Name::Constant
             push :common__2
           end
-          rule /(\b(with|as|variadic)\b)/, Name::Builtin
+          rule /(\b(with|as|variadic|if|else|for|in)\b)/, Name::Builtin
           rule /(\bstring\b|\bint\b|\bbool\b|\bfs\b|\bgroup\b|\boption(?!::)\b|\boption::(?:copy|frontend|git|http|image|local|mkdir|mkfile|mount|rm|run|secret|ssh|template)\b)(?:[\t ]+)(\{)/ do
             groups Keyword::Type, Punctuation
             push :block
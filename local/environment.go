@@ -49,20 +49,25 @@ func WithArch(ctx context.Context, arch string) context.Context {
 }
 
 func Env(ctx context.Context, key string) string {
+	value, _ := LookupEnv(ctx, key)
+	return value
+}
+
+func LookupEnv(ctx context.Context, key string) (string, bool) {
 	if environ, ok := ctx.Value(environContextKey).([]string); ok {
 		for _, env := range environ {
 			envParts := strings.SplitN(env, "=", 2)
 			if envParts[0] == key {
 				if len(envParts) > 1 {
-					return envParts[1]
+					return envParts[1], true
 				}
-				return ""
+				return "", true
 			}
 		}
 		// did not find the key
-		return ""
+		return "", false
 	}
-	return os.Getenv(key)
+	return os.LookupEnv(key)
 }
 
 func Environ(ctx context.Context) []string {
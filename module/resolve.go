@@ -115,23 +115,30 @@ func (r *vendorResolver) Resolve(ctx context.Context, scope *parser.Scope, decl
 }
 
 func resolveLocal(ctx context.Context, scope *parser.Scope, lit *parser.FuncLit, modulePath string) (Resolved, error) {
-	cg, err := codegen.New()
+	dgst, err := ImportDigest(ctx, scope, lit)
 	if err != nil {
 		return nil, err
 	}
 
-	st, err := cg.GenerateImport(ctx, scope, lit)
+	vp := VendorPath(modulePath, dgst)
+	return &localResolved{dgst, vp}, nil
+}
+
+// ImportDigest returns the digest of the filesystem an import declaration
+// imports from, which identifies its module in the vendor directory.
+func ImportDigest(ctx context.Context, scope *parser.Scope, lit *parser.FuncLit) (digest.Digest, error) {
+	cg, err := codegen.New()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	dgst, _, _, err := st.Output().Vertex(ctx).Marshal(ctx, &llb.Constraints{})
+	st, err := cg.GenerateImport(ctx, scope, lit)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	vp := VendorPath(modulePath, dgst)
-	return &localResolved{dgst, vp}, nil
+	dgst, _, _, err := st.Output().Vertex(ctx).Marshal(ctx, &llb.Constraints{})
+	return dgst, err
 }
 
 type localResolved struct {
@@ -193,7 +200,7 @@ func (r *remoteResolver) Resolve(ctx context.Context, scope *parser.Scope, decl
 		return nil, err
 	}
 
-	def, err := st.Marshal(ctx, llb.LinuxAmd64)
+	def, err := st.Marshal(ctx, llb.Platform(codegen.DefaultPlatform))
 	if err != nil {
 		return nil, err
 	}
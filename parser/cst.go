@@ -18,9 +18,9 @@ var (
 	Lexer = lexer.Must(regex.New(fmt.Sprintf(`
 		Whitespace = [\r\t ]+
 		HereDoc = <<[-~]?
-		Keyword  = \b(with|as|import|export|from)\b
+		Keyword  = \b(with|as|import|export|from|if|else|for|in)\b
 		Modifier = \b(variadic)\b
-		Type     = \b(string|int|bool|fs|option|group)(::[a-z][a-zA-Z]*)?\b
+		Type     = (\[\])?\b(string|int|bool|fs|option|group)(::[a-z][a-zA-Z]*)?\b
 		Numeric  = \b(0(b|B|o|O|x|X)[a-fA-F0-9]+)\b
 		Decimal  = \b(0|[1-9][0-9]*)\b
 		String   = "(\\.|[^"])*"|'[^']*'
@@ -28,7 +28,7 @@ var (
 		Selector = \b[a-zA-Z_][a-zA-Z0-9_]*\.[a-zA-Z_][a-zA-Z0-9_]*\b
 		Ident    = \b[a-zA-Z_][a-zA-Z0-9_]*\b
 		Newline  = \n
-		Operator = {|}|\(|\)|,|;
+		Operator = {|}|\(|\)|\[|\]|,|;|=
 		Comment  = #[^\n]*\n
 		Bad      = .*
 	`)))
@@ -227,7 +227,7 @@ type ImportPath struct {
 }
 
 func (i *ImportPath) Position() lexer.Position { return i.Pos }
-func (i *ImportPath) End() lexer.Position      { return shiftPosition(i.Pos, len(i.Path), 0) }
+func (i *ImportPath) End() lexer.Position      { return shiftPosition(i.Pos, len(i.Path.String()), 0) }
 
 // ExportDecl represents an export declaration.
 type ExportDecl struct {
@@ -271,7 +271,12 @@ func NewFuncDecl(typ ObjType, name string, params []*Field, stmts ...*Stmt) *Dec
 }
 
 func (d *FuncDecl) Position() lexer.Position { return d.Pos }
-func (d *FuncDecl) End() lexer.Position      { return d.Body.CloseBrace.End() }
+func (d *FuncDecl) End() lexer.Position {
+	if d.Body == nil {
+		return d.Params.End()
+	}
+	return d.Body.CloseBrace.End()
+}
 
 // FieldList represents a list of Fields, enclosed by parentheses.
 type FieldList struct {
@@ -299,9 +304,10 @@ func (f *FieldList) NumFields() int {
 // Field represents a parameter declaration in a signature.
 type Field struct {
 	Pos      lexer.Position
-	Variadic *Variadic `parser:"( @@ )?"`
-	Type     *Type     `parser:"@@"`
-	Name     *Ident    `parser:"@@"`
+	Variadic *Variadic     `parser:"( @@ )?"`
+	Type     *Type         `parser:"@@"`
+	Name     *Ident        `parser:"@@"`
+	Default  *FieldDefault `parser:"( @@ )?"`
 }
 
 func NewField(typ ObjType, name string, variadic bool) *Field {
@@ -315,8 +321,44 @@ func NewField(typ ObjType, name string, variadic bool) *Field {
 	return f
 }
 
+// NewDefaultField returns a field that is bound to value when no argument is
+// passed for it.
+func NewDefaultField(typ ObjType, name string, value *Expr) *Field {
+	f := NewField(typ, name, false)
+	f.Default = &FieldDefault{
+		Assign: &Assign{Text: "="},
+		Value:  value,
+	}
+	return f
+}
+
 func (f *Field) Position() lexer.Position { return f.Pos }
-func (f *Field) End() lexer.Position      { return f.Name.End() }
+func (f *Field) End() lexer.Position {
+	if f.Default != nil {
+		return f.Default.End()
+	}
+	return f.Name.End()
+}
+
+// FieldDefault represents the default value of a parameter, which is used when
+// no argument is passed for it.
+type FieldDefault struct {
+	Pos    lexer.Position
+	Assign *Assign `parser:"@@"`
+	Value  *Expr   `parser:"@@"`
+}
+
+func (d *FieldDefault) Position() lexer.Position { return d.Pos }
+func (d *FieldDefault) End() lexer.Position      { return d.Value.End() }
+
+// Assign represents the "=" operator.
+type Assign struct {
+	Pos  lexer.Position
+	Text string `parser:"@\"=\""`
+}
+
+func (a *Assign) Position() lexer.Position { return a.Pos }
+func (a *Assign) End() lexer.Position      { return shiftPosition(a.Pos, len(a.Text), 0) }
 
 // Variadic represents a modifier for variadic fields. Variadic must only
 // modify the last field of a FieldList.
@@ -328,13 +370,16 @@ type Variadic struct {
 func (v *Variadic) Position() lexer.Position { return v.Pos }
 func (v *Variadic) End() lexer.Position      { return shiftPosition(v.Pos, len(v.Keyword), 0) }
 
-// Expr represents an expression node.
+// Expr represents an expression node. Arguments to a call may be named to be
+// passed to the parameter of the same name.
 type Expr struct {
 	Pos      lexer.Position
+	Named    *NamedArg `parser:"( @@ )?"`
 	Bad      *Bad      `parser:"( @@"`
 	Selector *Selector `parser:"| @Selector"`
 	Ident    *Ident    `parser:"| @@"`
 	BasicLit *BasicLit `parser:"| @@"`
+	ListLit  *ListLit  `parser:"| @@"`
 	FuncLit  *FuncLit  `parser:"| @@ )"`
 }
 
@@ -349,6 +394,8 @@ func (e *Expr) End() lexer.Position {
 		return e.Ident.End()
 	case e.BasicLit != nil:
 		return e.BasicLit.End()
+	case e.ListLit != nil:
+		return e.ListLit.End()
 	case e.FuncLit != nil:
 		return e.FuncLit.End()
 	default:
@@ -360,6 +407,16 @@ func (e *Expr) Name() string {
 	return e.IdentNode().Name
 }
 
+// NamedArg represents the name of the parameter an argument is passed to.
+type NamedArg struct {
+	Pos    lexer.Position
+	Name   *Ident  `parser:"@@"`
+	Assign *Assign `parser:"@@"`
+}
+
+func (n *NamedArg) Position() lexer.Position { return n.Pos }
+func (n *NamedArg) End() lexer.Position      { return n.Assign.End() }
+
 func (e *Expr) IdentNode() *Ident {
 	switch {
 	case e.Selector != nil:
@@ -371,18 +428,39 @@ func (e *Expr) IdentNode() *Ident {
 	}
 }
 
-// Type represents an object type.
+// Type represents an object type. A type followed by a parenthesized list of
+// types is the type of a function with those parameters.
 type Type struct {
 	Pos     lexer.Position
-	ObjType ObjType `parser:"@Type"`
+	ObjType ObjType   `parser:"@Type"`
+	Func    *FuncType `parser:"( @@ )?"`
 }
 
+// NewType returns the Type of an ObjType, which may be a function type built by
+// FuncOf.
 func NewType(typ ObjType) *Type {
-	return &Type{ObjType: typ}
+	if !typ.IsFunc() {
+		return &Type{ObjType: typ}
+	}
+
+	ret, params := typ.Signature()
+	return &Type{
+		ObjType: ret,
+		Func:    &FuncType{Params: params},
+	}
 }
 
 func (t *Type) Position() lexer.Position { return t.Pos }
-func (t *Type) End() lexer.Position      { return shiftPosition(t.Pos, len(string(t.ObjType)), 0) }
+func (t *Type) End() lexer.Position      { return shiftPosition(t.Pos, len(t.String()), 0) }
+
+// Kind returns the ObjType of the type. For a function type, this is the
+// return type and the parameter types as built by FuncOf.
+func (t *Type) Kind() ObjType {
+	if t.Func == nil {
+		return t.ObjType
+	}
+	return FuncOf(t.ObjType, t.Func.Params...)
+}
 
 func (t *Type) Primary() ObjType {
 	parts := typeParts(t.ObjType)
@@ -401,15 +479,43 @@ func typeParts(typ ObjType) []string {
 	return strings.Split(string(typ), "::")
 }
 
+// IsFunc returns whether the type is a function type.
+func (t *Type) IsFunc() bool {
+	return t.Func != nil
+}
+
+// Signature returns the return type and the parameter types of a function
+// type. If the type is not a function type, it is returned as is.
+func (t *Type) Signature() (ObjType, []ObjType) {
+	if t.Func == nil {
+		return t.ObjType, nil
+	}
+	return t.ObjType, t.Func.Params
+}
+
+// Elem returns the type of the elements of a list type.
+func (t *Type) Elem() ObjType {
+	return t.ObjType.Elem()
+}
+
 // Equals returns whether type equals another ObjType.
 func (t *Type) Equals(typ ObjType) bool {
-	if t.Primary() == Option && t.Secondary() == None {
+	if !t.IsFunc() && t.Primary() == Option && t.Secondary() == None {
 		parts := typeParts(typ)
 		return ObjType(parts[0]) == Option
 	}
-	return t.ObjType == typ
+	return t.Kind() == typ
 }
 
+// FuncType represents the parenthesized parameter types of a function type.
+type FuncType struct {
+	Pos    lexer.Position
+	Params []ObjType `parser:"\"(\" ( @Type ( \",\" @Type )* )? \")\""`
+}
+
+func (f *FuncType) Position() lexer.Position { return f.Pos }
+func (f *FuncType) End() lexer.Position      { return shiftPosition(f.Pos, len(f.String()), 0) }
+
 type ObjType string
 
 const (
@@ -422,6 +528,61 @@ const (
 	Group      ObjType = "group"
 )
 
+// FuncOf returns the type of a function with the given return type and
+// parameter types.
+func FuncOf(ret ObjType, params ...ObjType) ObjType {
+	var parts []string
+	for _, param := range params {
+		parts = append(parts, string(param))
+	}
+	return ObjType(fmt.Sprintf("%s(%s)", ret, strings.Join(parts, ",")))
+}
+
+// IsFunc returns whether the type is a function type.
+func (typ ObjType) IsFunc() bool {
+	return strings.HasSuffix(string(typ), ")")
+}
+
+// Signature returns the return type and the parameter types of a function
+// type. If the type is not a function type, it is returned as is.
+func (typ ObjType) Signature() (ObjType, []ObjType) {
+	if !typ.IsFunc() {
+		return typ, nil
+	}
+
+	str := string(typ)
+	i := strings.IndexByte(str, '(')
+
+	var params []ObjType
+	if args := str[i+1 : len(str)-1]; args != "" {
+		for _, param := range strings.Split(args, ",") {
+			params = append(params, ObjType(param))
+		}
+	}
+	return ObjType(str[:i]), params
+}
+
+const listPrefix = "[]"
+
+// ListOf returns the type of a list with elements of the given type.
+func ListOf(elem ObjType) ObjType {
+	return ObjType(listPrefix + string(elem))
+}
+
+// IsList returns whether the type is a list type.
+func (typ ObjType) IsList() bool {
+	return strings.HasPrefix(string(typ), listPrefix)
+}
+
+// Elem returns the type of the elements of a list type, or None if the type is
+// not a list.
+func (typ ObjType) Elem() ObjType {
+	if !typ.IsList() {
+		return None
+	}
+	return ObjType(strings.TrimPrefix(string(typ), listPrefix))
+}
+
 // Ident represents an identifier.
 type Ident struct {
 	Pos  lexer.Position
@@ -491,7 +652,7 @@ type BasicLit struct {
 	HereDoc *HereDoc      `parser:"| @@"`
 	Decimal *int          `parser:"| @Decimal"`
 	Numeric *NumericLit   `parser:"| @Numeric"`
-	Bool    *bool         `parser:"| @Bool )"`
+	Bool    *BoolLit      `parser:"| @Bool )"`
 }
 
 func (l *BasicLit) Position() lexer.Position { return l.Pos }
@@ -504,6 +665,21 @@ func (l *BasicLit) End() lexer.Position {
 	}
 }
 
+// ListLit represents a literal list of expressions.
+type ListLit struct {
+	Pos          lexer.Position
+	OpenBracket  *OpenBracket  `parser:"@@"`
+	Elems        []*Expr       `parser:"( Newline )? ( @@ ( Newline )? ( \",\" ( Newline )? ( @@ ( Newline )? )? )* )?"`
+	CloseBracket *CloseBracket `parser:"@@"`
+}
+
+func NewListLit(elems ...*Expr) *ListLit {
+	return &ListLit{Elems: elems}
+}
+
+func (l *ListLit) Position() lexer.Position { return l.Pos }
+func (l *ListLit) End() lexer.Position      { return l.CloseBracket.End() }
+
 // HereDoc represents a multiline here-doc type.
 type HereDoc struct {
 	Pos      lexer.Position
@@ -598,6 +774,15 @@ func (h *HereDoc) Parse(lex *lexer.PeekingLexer) error {
 	return nil
 }
 
+// BoolLit represents a bool literal.
+type BoolLit bool
+
+func (l *BoolLit) Capture(tokens []string) error {
+	v, err := strconv.ParseBool(tokens[0])
+	*l = BoolLit(v)
+	return err
+}
+
 // NumericLit represents a number literal with a non-decimal base.
 type NumericLit struct {
 	Pos   lexer.Position
@@ -671,9 +856,10 @@ func NewNumericExpr(v int64, base int) *Expr {
 }
 
 func NewBoolExpr(v bool) *Expr {
+	b := BoolLit(v)
 	return &Expr{
 		BasicLit: &BasicLit{
-			Bool: &v,
+			Bool: &b,
 		},
 	}
 }
@@ -715,6 +901,8 @@ func (l *FuncLit) NumStmts() int {
 type Stmt struct {
 	Pos     lexer.Position
 	Bad     *Bad          `parser:"( @@"`
+	If      *IfStmt       `parser:"| @@"`
+	For     *ForStmt      `parser:"| @@"`
 	Call    *CallStmt     `parser:"| @@"`
 	Newline *Newline      `parser:"| @@"`
 	Doc     *CommentGroup `parser:"| @@ )"`
@@ -725,6 +913,10 @@ func (s *Stmt) End() lexer.Position {
 	switch {
 	case s.Bad != nil:
 		return s.Bad.End()
+	case s.If != nil:
+		return s.If.End()
+	case s.For != nil:
+		return s.For.End()
 	case s.Call != nil:
 		return s.Call.End()
 	case s.Newline != nil:
@@ -736,6 +928,154 @@ func (s *Stmt) End() lexer.Position {
 	}
 }
 
+// IfStmt represents a conditional statement. Only the statements of the
+// branch chosen by its condition are emitted.
+//
+// The condition is either a bool expression, or the name of a bool function
+// followed by its argument list.
+type IfStmt struct {
+	Pos     lexer.Position
+	If      *If        `parser:"@@"`
+	Cond    *Expr      `parser:"@@"`
+	Args    []*Expr    `parser:"( @@ )*"`
+	Body    *BlockStmt `parser:"@@"`
+	Else    *ElseStmt  `parser:"( @@ )?"`
+	StmtEnd *StmtEnd   `parser:"( @@ )?"`
+}
+
+func NewIfStmt(cond *Expr, args []*Expr, body []*Stmt, elseStmt *ElseStmt) *Stmt {
+	return &Stmt{
+		If: &IfStmt{
+			If:   &If{Keyword: "if"},
+			Cond: cond,
+			Args: args,
+			Body: NewBlockStmt(body...),
+			Else: elseStmt,
+		},
+	}
+}
+
+func (s *IfStmt) Position() lexer.Position { return s.Pos }
+func (s *IfStmt) End() lexer.Position {
+	switch {
+	case s.StmtEnd != nil:
+		return s.StmtEnd.End()
+	case s.Else != nil:
+		return s.Else.End()
+	default:
+		return s.Body.End()
+	}
+}
+
+// If represents the keyword "if".
+type If struct {
+	Pos     lexer.Position
+	Keyword string `parser:"@\"if\""`
+}
+
+func (i *If) Position() lexer.Position { return i.Pos }
+func (i *If) End() lexer.Position      { return shiftPosition(i.Pos, len(i.Keyword), 0) }
+
+// ElseStmt represents the alternative branch of an IfStmt, which is either
+// another IfStmt or a braced statement list.
+type ElseStmt struct {
+	Pos  lexer.Position
+	Else *Else      `parser:"@@"`
+	If   *IfStmt    `parser:"( @@"`
+	Body *BlockStmt `parser:"| @@ )"`
+}
+
+func NewElseStmt(stmts ...*Stmt) *ElseStmt {
+	return &ElseStmt{
+		Else: &Else{Keyword: "else"},
+		Body: NewBlockStmt(stmts...),
+	}
+}
+
+func NewElseIfStmt(stmt *Stmt) *ElseStmt {
+	return &ElseStmt{
+		Else: &Else{Keyword: "else"},
+		If:   stmt.If,
+	}
+}
+
+func (s *ElseStmt) Position() lexer.Position { return s.Pos }
+func (s *ElseStmt) End() lexer.Position {
+	switch {
+	case s.If != nil:
+		return s.If.End()
+	case s.Body != nil:
+		return s.Body.End()
+	default:
+		return s.Else.End()
+	}
+}
+
+// Else represents the keyword "else".
+type Else struct {
+	Pos     lexer.Position
+	Keyword string `parser:"@\"else\""`
+}
+
+func (e *Else) Position() lexer.Position { return e.Pos }
+func (e *Else) End() lexer.Position      { return shiftPosition(e.Pos, len(e.Keyword), 0) }
+
+// ForStmt represents a loop over the elements of a list. Its statements are
+// emitted once for every element, with the element bound to the loop variable.
+//
+// The list is either a list expression, or the name of a list function
+// followed by its argument list.
+type ForStmt struct {
+	Pos     lexer.Position
+	Scope   *Scope
+	For     *For       `parser:"@@"`
+	Var     *Ident     `parser:"@@"`
+	In      *In        `parser:"@@"`
+	List    *Expr      `parser:"@@"`
+	Args    []*Expr    `parser:"( @@ )*"`
+	Body    *BlockStmt `parser:"@@"`
+	StmtEnd *StmtEnd   `parser:"( @@ )?"`
+}
+
+func NewForStmt(name string, list *Expr, args []*Expr, body []*Stmt) *Stmt {
+	return &Stmt{
+		For: &ForStmt{
+			For:  &For{Keyword: "for"},
+			Var:  NewIdent(name),
+			In:   &In{Keyword: "in"},
+			List: list,
+			Args: args,
+			Body: NewBlockStmt(body...),
+		},
+	}
+}
+
+func (s *ForStmt) Position() lexer.Position { return s.Pos }
+func (s *ForStmt) End() lexer.Position {
+	if s.StmtEnd != nil {
+		return s.StmtEnd.End()
+	}
+	return s.Body.End()
+}
+
+// For represents the keyword "for".
+type For struct {
+	Pos     lexer.Position
+	Keyword string `parser:"@\"for\""`
+}
+
+func (f *For) Position() lexer.Position { return f.Pos }
+func (f *For) End() lexer.Position      { return shiftPosition(f.Pos, len(f.Keyword), 0) }
+
+// In represents the keyword "in".
+type In struct {
+	Pos     lexer.Position
+	Keyword string `parser:"@\"in\""`
+}
+
+func (i *In) Position() lexer.Position { return i.Pos }
+func (i *In) End() lexer.Position      { return shiftPosition(i.Pos, len(i.Keyword), 0) }
+
 // CallStmt represents an function name followed by an argument list, and an
 // optional WithOpt.
 type CallStmt struct {
@@ -957,6 +1297,24 @@ type CloseBrace struct {
 func (b *CloseBrace) Position() lexer.Position { return b.Pos }
 func (b *CloseBrace) End() lexer.Position      { return shiftPosition(b.Pos, 1, 0) }
 
+// OpenBracket represents the "[" bracket.
+type OpenBracket struct {
+	Pos     lexer.Position
+	Bracket string `parser:"@\"[\""`
+}
+
+func (b *OpenBracket) Position() lexer.Position { return b.Pos }
+func (b *OpenBracket) End() lexer.Position      { return shiftPosition(b.Pos, 1, 0) }
+
+// CloseBracket represents the "]" bracket.
+type CloseBracket struct {
+	Pos     lexer.Position
+	Bracket string `parser:"@\"]\""`
+}
+
+func (b *CloseBracket) Position() lexer.Position { return b.Pos }
+func (b *CloseBracket) End() lexer.Position      { return shiftPosition(b.Pos, 1, 0) }
+
 // Helper functions.
 func shiftPosition(pos lexer.Position, offset int, line int) lexer.Position { //nolint:unparam
 	pos.Offset += offset
@@ -166,18 +166,47 @@ func (f *Field) String() string {
 	if f.Variadic != nil {
 		variadic = fmt.Sprintf("%s ", f.Variadic)
 	}
+	if f.Default != nil {
+		return fmt.Sprintf("%s%s %s %s", variadic, f.Type, f.Name, f.Default)
+	}
 	return fmt.Sprintf("%s%s %s", variadic, f.Type, f.Name)
 }
 
+func (d *FieldDefault) String() string {
+	return fmt.Sprintf("%s %s", d.Assign, d.Value)
+}
+
+func (a *Assign) String() string {
+	return a.Text
+}
+
 func (v *Variadic) String() string {
 	return v.Keyword
 }
 
 func (t *Type) String() string {
+	if t.Func != nil {
+		return fmt.Sprintf("%s%s", t.ObjType, t.Func)
+	}
 	return string(t.ObjType)
 }
 
+func (f *FuncType) String() string {
+	var params []string
+	for _, param := range f.Params {
+		params = append(params, string(param))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(params, ","))
+}
+
 func (e *Expr) String() string {
+	if e.Named != nil {
+		return fmt.Sprintf("%s%s", e.Named, e.value())
+	}
+	return e.value()
+}
+
+func (e *Expr) value() string {
 	switch {
 	case e.Bad != nil:
 		return e.Bad.String()
@@ -187,12 +216,18 @@ func (e *Expr) String() string {
 		return e.Ident.String()
 	case e.BasicLit != nil:
 		return e.BasicLit.String()
+	case e.ListLit != nil:
+		return e.ListLit.String()
 	case e.FuncLit != nil:
 		return e.FuncLit.String()
 	}
 	panic("unknown expr")
 }
 
+func (n *NamedArg) String() string {
+	return fmt.Sprintf("%s%s", n.Name, n.Assign)
+}
+
 func (s *Selector) String() string {
 	return fmt.Sprintf("%s.%s", s.Ident, s.Select)
 }
@@ -220,7 +255,7 @@ func (l *BasicLit) String() string {
 	case l.Numeric != nil:
 		return l.Numeric.String()
 	case l.Bool != nil:
-		return strconv.FormatBool(*l.Bool)
+		return strconv.FormatBool(bool(*l.Bool))
 	}
 	panic("unknown basic lit")
 }
@@ -244,6 +279,14 @@ func (h *HereDoc) String() string {
 	return fmt.Sprintf("%s%s\n<HLB-HEREDOC-MARKER>\n%s\n<HLB-HEREDOC-MARKER>\n%s", h.operator, h.ident, h.raw, h.ident)
 }
 
+func (l *ListLit) String() string {
+	var elems []string
+	for _, elem := range l.Elems {
+		elems = append(elems, elem.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(elems, ", "))
+}
+
 func (l *FuncLit) String() string {
 	return fmt.Sprintf("%s %s", l.Type, l.Body)
 }
@@ -252,6 +295,10 @@ func (s *Stmt) String() string {
 	switch {
 	case s.Bad != nil:
 		return s.Bad.String()
+	case s.If != nil:
+		return s.If.String()
+	case s.For != nil:
+		return s.For.String()
 	case s.Call != nil:
 		return s.Call.String()
 	case s.Newline != nil:
@@ -294,6 +341,78 @@ func (s *CallStmt) String() string {
 	return fmt.Sprintf("%s%s%s%s%s", s.Func, args, withOpt, alias, end)
 }
 
+func (s *IfStmt) String() string {
+	args := ""
+	if len(s.Args) > 0 {
+		var exprs []string
+		for _, expr := range s.Args {
+			exprs = append(exprs, expr.String())
+		}
+		args = fmt.Sprintf(" %s", strings.Join(exprs, " "))
+	}
+
+	elseStmt := ""
+	if s.Else != nil {
+		elseStmt = fmt.Sprintf(" %s", s.Else)
+	}
+
+	end := ""
+	if s.StmtEnd != nil {
+		if s.StmtEnd.Newline != nil {
+			end = s.StmtEnd.String()
+		} else if s.StmtEnd.Comment != nil {
+			end = fmt.Sprintf(" %s", s.StmtEnd)
+		}
+	}
+
+	return fmt.Sprintf("%s %s%s %s%s%s", s.If, s.Cond, args, s.Body, elseStmt, end)
+}
+
+func (i *If) String() string {
+	return i.Keyword
+}
+
+func (s *ElseStmt) String() string {
+	if s.If != nil {
+		return fmt.Sprintf("%s %s", s.Else, s.If)
+	}
+	return fmt.Sprintf("%s %s", s.Else, s.Body)
+}
+
+func (e *Else) String() string {
+	return e.Keyword
+}
+
+func (s *ForStmt) String() string {
+	args := ""
+	if len(s.Args) > 0 {
+		var exprs []string
+		for _, expr := range s.Args {
+			exprs = append(exprs, expr.String())
+		}
+		args = fmt.Sprintf(" %s", strings.Join(exprs, " "))
+	}
+
+	end := ""
+	if s.StmtEnd != nil {
+		if s.StmtEnd.Newline != nil {
+			end = s.StmtEnd.String()
+		} else if s.StmtEnd.Comment != nil {
+			end = fmt.Sprintf(" %s", s.StmtEnd)
+		}
+	}
+
+	return fmt.Sprintf("%s %s %s %s%s %s%s", s.For, s.Var, s.In, s.List, args, s.Body, end)
+}
+
+func (f *For) String() string {
+	return f.Keyword
+}
+
+func (i *In) String() string {
+	return i.Keyword
+}
+
 func (d *AliasDecl) String() string {
 	return fmt.Sprintf("%s %s", d.As, d.Ident)
 }
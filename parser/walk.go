@@ -85,7 +85,27 @@ func Walk(node Node, v Visitor) {
 		if n.Name != nil {
 			Walk(n.Name, v)
 		}
+		if n.Default != nil {
+			Walk(n.Default, v)
+		}
+	case *FieldDefault:
+		if n.Assign != nil {
+			Walk(n.Assign, v)
+		}
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *NamedArg:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Assign != nil {
+			Walk(n.Assign, v)
+		}
 	case *Expr:
+		if n.Named != nil {
+			Walk(n.Named, v)
+		}
 		switch {
 		case n.Bad != nil:
 			Walk(n.Bad, v)
@@ -95,6 +115,8 @@ func Walk(node Node, v Visitor) {
 			Walk(n.Ident, v)
 		case n.BasicLit != nil:
 			Walk(n.BasicLit, v)
+		case n.ListLit != nil:
+			Walk(n.ListLit, v)
 		case n.FuncLit != nil:
 			Walk(n.FuncLit, v)
 		}
@@ -109,6 +131,10 @@ func Walk(node Node, v Visitor) {
 		if n.Numeric != nil {
 			Walk(n.Numeric, v)
 		}
+	case *ListLit:
+		for _, elem := range n.Elems {
+			Walk(elem, v)
+		}
 	case *FuncLit:
 		if n.Body != nil {
 			Walk(n.Body, v)
@@ -117,11 +143,66 @@ func Walk(node Node, v Visitor) {
 		switch {
 		case n.Bad != nil:
 			Walk(n.Bad, v)
+		case n.If != nil:
+			Walk(n.If, v)
+		case n.For != nil:
+			Walk(n.For, v)
 		case n.Call != nil:
 			Walk(n.Call, v)
 		case n.Doc != nil:
 			Walk(n.Doc, v)
 		}
+	case *IfStmt:
+		if n.If != nil {
+			Walk(n.If, v)
+		}
+		if n.Cond != nil {
+			Walk(n.Cond, v)
+		}
+		walkExprList(n.Args, v)
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+		if n.Else != nil {
+			Walk(n.Else, v)
+		}
+		if n.StmtEnd != nil {
+			if n.StmtEnd.Comment != nil {
+				Walk(n.StmtEnd.Comment, v)
+			}
+		}
+	case *ForStmt:
+		if n.For != nil {
+			Walk(n.For, v)
+		}
+		if n.Var != nil {
+			Walk(n.Var, v)
+		}
+		if n.In != nil {
+			Walk(n.In, v)
+		}
+		if n.List != nil {
+			Walk(n.List, v)
+		}
+		walkExprList(n.Args, v)
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+		if n.StmtEnd != nil {
+			if n.StmtEnd.Comment != nil {
+				Walk(n.StmtEnd.Comment, v)
+			}
+		}
+	case *ElseStmt:
+		if n.Else != nil {
+			Walk(n.Else, v)
+		}
+		if n.If != nil {
+			Walk(n.If, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
 	case *CallStmt:
 		if n.Func != nil {
 			Walk(n.Func, v)
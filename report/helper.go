@@ -103,6 +103,17 @@ func getSegment(ib *IndexedBuffer, token lexer.Token) ([]byte, error) {
 }
 
 func getSuggestion(color aurora.Aurora, keywords []string, value string) (string, bool) { //nolint:unparam
+	suggestion, ok := Suggest(keywords, value)
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s%s%s", color.Red(`, did you mean `), suggestion, color.Red(`?`)), value == suggestion
+}
+
+// Suggest returns the keyword closest to value by levenshtein distance, if
+// it is close enough to be a likely typo.
+func Suggest(keywords []string, value string) (string, bool) {
 	min := -1
 	index := -1
 
@@ -119,11 +130,11 @@ func getSuggestion(color aurora.Aurora, keywords []string, value string) (string
 		failLimit = 2
 	}
 
-	if min > failLimit {
+	if index == -1 || min > failLimit {
 		return "", false
 	}
 
-	return fmt.Sprintf("%s%s%s", color.Red(`, did you mean `), keywords[index], color.Red(`?`)), value == keywords[index]
+	return keywords[index], true
 }
 
 func helpValidKeywords(color aurora.Aurora, keywords []string, subject string) string {
@@ -14,9 +14,9 @@ import (
 
 var (
 	Sources = []string{"scratch", "image", "http", "git", "local", "generate"}
-	Ops     = []string{"shell", "run", "env", "dir", "user", "entrypoint", "mkdir", "mkfile", "rm", "copy"}
+	Ops     = []string{"shell", "run", "env", "dir", "user", "entrypoint", "cmd", "label", "expose", "volume", "stopSignal", "mkdir", "mkfile", "rm", "copy"}
 	Debugs  = []string{"breakpoint"}
-	Types   = []string{"string", "int", "bool", "fs", "option"}
+	Types   = []string{"string", "int", "bool", "fs", "option", "group"}
 
 	CommonOptions   = []string{"no-cache"}
 	ImageOptions    = []string{"resolve"}
@@ -41,7 +41,7 @@ var (
 	Enums            = flatMap(NetworkModes, SecurityModes, CacheSharingModes)
 	Fields           = flatMap(Sources, Ops, Options)
 	Keywords         = flatMap(Types, Sources, Fields, Enums)
-	ReservedKeywords = flatMap(Types, []string{"with"})
+	ReservedKeywords = flatMap(Types, []string{"with", "as", "import", "export", "from", "if", "else", "for", "in"})
 
 	KeywordsWithOptions = []string{"image", "http", "git", "run", "ssh", "secret", "mount", "mkdir", "mkfile", "rm", "copy"}
 	KeywordsWithBlocks  = flatMap(Types, KeywordsWithOptions)
@@ -152,6 +152,17 @@ type Annotation struct {
 	Message string
 }
 
+// Len returns the number of columns underlined by the annotation.
+func (a Annotation) Len() int {
+	underline := len(a.Token.String())
+	if isSymbol(a.Token, "Newline") {
+		underline = 1
+	} else if isSymbol(a.Token, "String") {
+		underline += 2
+	}
+	return underline
+}
+
 func (a Annotation) Lines(color aurora.Aurora) []string {
 	end := a.Pos.Column - 1
 	if len(a.Segment) <= a.Pos.Column-1 {
@@ -168,17 +179,10 @@ func (a Annotation) Lines(color aurora.Aurora) []string {
 		}, a.Segment[:end])
 	}
 
-	underline := len(a.Token.String())
-	if isSymbol(a.Token, "Newline") {
-		underline = 1
-	} else if isSymbol(a.Token, "String") {
-		underline += 2
-	}
-
 	var lines []string
 	lines = append(lines, "")
 	lines = append(lines, string(a.Segment))
-	lines = append(lines, color.Sprintf(color.Red("%s%s"), padding, strings.Repeat("^", underline)))
+	lines = append(lines, color.Sprintf(color.Red("%s%s"), padding, strings.Repeat("^", a.Len())))
 	lines = append(lines, fmt.Sprintf("%s%s", padding, a.Message))
 
 	return lines
@@ -111,7 +111,7 @@ func errFuncName(color aurora.Aurora, ib *IndexedBuffer, lex *lexer.PeekingLexer
 		return group, err
 	}
 
-	if isSymbol(endToken, "Type") {
+	if isSymbol(endToken, "Type", "Keyword") {
 		return errKeyword(color, ib, lex, endToken)
 	}
 
@@ -342,7 +342,7 @@ func errArgIdent(color aurora.Aurora, ib *IndexedBuffer, lex *lexer.PeekingLexer
 		return group, err
 	}
 
-	if isSymbol(endToken, "Type") {
+	if isSymbol(endToken, "Type", "Keyword") {
 		return errKeyword(color, ib, lex, endToken)
 	}
 
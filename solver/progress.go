@@ -3,6 +3,7 @@ package solver
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -18,6 +19,7 @@ type ProgressOption func(*ProgressInfo) error
 
 type ProgressInfo struct {
 	LogOutput LogOutput
+	Output    io.Writer
 }
 
 type LogOutput int
@@ -36,6 +38,15 @@ func WithLogOutput(logOutput LogOutput) ProgressOption {
 	}
 }
 
+// WithOutput sets the writer that machine-readable log outputs (json, raw)
+// are streamed to. By default, it is os.Stdout.
+func WithOutput(w io.Writer) ProgressOption {
+	return func(info *ProgressInfo) error {
+		info.Output = w
+		return nil
+	}
+}
+
 type Progress interface {
 	MultiWriter() *progress.MultiWriter
 
@@ -88,7 +99,9 @@ type Progress interface {
 // return p.Wait()
 // ```
 func NewProgress(ctx context.Context, opts ...ProgressOption) (Progress, error) {
-	info := &ProgressInfo{}
+	info := &ProgressInfo{
+		Output: os.Stdout,
+	}
 	for _, opt := range opts {
 		err := opt(info)
 		if err != nil {
@@ -108,8 +121,10 @@ func NewProgress(ctx context.Context, opts ...ProgressOption) (Progress, error)
 	case LogOutputPlain:
 		pw = progress.NewPrinter(pctx, os.Stderr, "plain")
 	case LogOutputJSON, LogOutputRaw:
-		panic("unimplemented")
-		// return StreamSolveStatus(ctx, info.LogOutput, os.Stdout, ch)
+		pw = NewStreamWriter(pctx, info.LogOutput, info.Output)
+	default:
+		cancel()
+		return nil, errors.Errorf("unknown log output %d", info.LogOutput)
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
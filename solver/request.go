@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/containerd/containerd/platforms"
 	"github.com/docker/buildx/util/progress"
 	"github.com/kballard/go-shellquote"
 	"github.com/moby/buildkit/client"
@@ -38,6 +39,10 @@ type Params struct {
 	Def       *llb.Definition
 	SolveOpts []SolveOption
 	Session   *session.Session
+
+	// Platforms is set instead of Def when the request is built for several
+	// platforms at once.
+	Platforms []PlatformDefinition
 }
 
 type singleRequest struct {
@@ -62,6 +67,9 @@ func (r *singleRequest) Solve(ctx context.Context, cln *client.Client, mw *progr
 	})
 
 	g.Go(func() error {
+		if len(r.params.Platforms) > 0 {
+			return SolvePlatforms(ctx, cln, r.params.Session, pw, r.params.Platforms, r.params.SolveOpts...)
+		}
 		return Solve(ctx, cln, r.params.Session, pw, r.params.Def, r.params.SolveOpts...)
 	})
 
@@ -69,6 +77,16 @@ func (r *singleRequest) Solve(ctx context.Context, cln *client.Client, mw *progr
 }
 
 func (r *singleRequest) Tree(tree treeprint.Tree) error {
+	if len(r.params.Platforms) > 0 {
+		for _, pdef := range r.params.Platforms {
+			branch := tree.AddMetaBranch("platform", platforms.Format(pdef.Platform))
+			err := treeFromDefinition(branch, pdef.Def, r.params.SolveOpts)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	return treeFromDefinition(tree, r.params.Def, r.params.SolveOpts)
 }
 
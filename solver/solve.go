@@ -3,7 +3,9 @@ package solver
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
+	"github.com/containerd/containerd/platforms"
 	"github.com/docker/buildx/util/progress"
 	"github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
@@ -24,10 +26,21 @@ type SolveInfo struct {
 	OutputLocalTarball    bool
 	OutputLocalOCITarball bool
 	Callbacks             []func() error `json:"-"`
-	ImageSpec             *specs.Image
+	ImageSpec             *ImageSpec
 	Entitlements          []entitlements.Entitlement
 }
 
+// ImageSpec is the image config exported with a solved definition. The image
+// spec only gained the platform variant after v1.0.1, so it is added here like
+// buildkit's dockerfile frontend does.
+type ImageSpec struct {
+	specs.Image
+
+	// Variant is the variant of the CPU the image is built for, like v7 for
+	// linux/arm/v7.
+	Variant string `json:"variant,omitempty"`
+}
+
 func WithDownloadDockerTarball(ref string) SolveOption {
 	return func(info *SolveInfo) error {
 		info.OutputDockerRef = ref
@@ -70,7 +83,7 @@ func WithCallback(fn func() error) SolveOption {
 	}
 }
 
-func WithImageSpec(cfg *specs.Image) SolveOption {
+func WithImageSpec(cfg *ImageSpec) SolveOption {
 	return func(info *SolveInfo) error {
 		info.ImageSpec = cfg
 		return nil
@@ -113,6 +126,85 @@ func Solve(ctx context.Context, c *client.Client, s *session.Session, pw progres
 	}, opts...)
 }
 
+// PlatformDefinition is a LLB definition marshalled for a specific platform.
+type PlatformDefinition struct {
+	Platform specs.Platform
+	Def      *llb.Definition
+}
+
+// SolvePlatforms solves a definition for every platform and exports the
+// results together, so that image exporters produce a manifest list.
+func SolvePlatforms(ctx context.Context, c *client.Client, s *session.Session, pw progress.Writer, defs []PlatformDefinition, opts ...SolveOption) error {
+	info := &SolveInfo{}
+	for _, opt := range opts {
+		err := opt(info)
+		if err != nil {
+			return err
+		}
+	}
+
+	return Build(ctx, c, s, pw, func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+		refs := make([]gateway.Reference, len(defs))
+
+		g, ctx := errgroup.WithContext(ctx)
+		for i, pdef := range defs {
+			i, pdef := i, pdef
+			g.Go(func() error {
+				r, err := c.Solve(ctx, gateway.SolveRequest{
+					Definition: pdef.Def.ToPB(),
+				})
+				if err != nil {
+					return err
+				}
+
+				refs[i], err = r.SingleRef()
+				return err
+			})
+		}
+
+		err := g.Wait()
+		if err != nil {
+			return nil, err
+		}
+
+		res := gateway.NewResult()
+		expPlatforms := exptypes.Platforms{
+			Platforms: make([]exptypes.Platform, len(defs)),
+		}
+
+		for i, pdef := range defs {
+			id := platforms.Format(pdef.Platform)
+			res.AddRef(id, refs[i])
+			expPlatforms.Platforms[i] = exptypes.Platform{
+				ID:       id,
+				Platform: pdef.Platform,
+			}
+
+			if info.ImageSpec != nil {
+				img := *info.ImageSpec
+				img.OS = pdef.Platform.OS
+				img.Architecture = pdef.Platform.Architecture
+				img.Variant = pdef.Platform.Variant
+
+				config, err := json.Marshal(img)
+				if err != nil {
+					return nil, err
+				}
+
+				res.AddMeta(fmt.Sprintf("%s/%s", exptypes.ExporterImageConfigKey, id), config)
+			}
+		}
+
+		dt, err := json.Marshal(expPlatforms)
+		if err != nil {
+			return nil, err
+		}
+		res.AddMeta(exptypes.ExporterPlatformsKey, dt)
+
+		return res, nil
+	}, opts...)
+}
+
 func Build(ctx context.Context, c *client.Client, s *session.Session, pw progress.Writer, f gateway.BuildFunc, opts ...SolveOption) error {
 	info := &SolveInfo{}
 	for _, opt := range opts {
@@ -5,11 +5,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/docker/buildx/util/progress"
 	"github.com/moby/buildkit/client"
 	digest "github.com/opencontainers/go-digest"
 )
 
+// NewStreamWriter returns a progress.Writer that streams every solve status
+// it receives to w in a machine-readable format instead of presenting it as an
+// interactive UI.
+func NewStreamWriter(ctx context.Context, logOutput LogOutput, w io.Writer) progress.Writer {
+	pw := &streamWriter{
+		status: make(chan *client.SolveStatus),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(pw.done)
+		pw.err = StreamSolveStatus(ctx, logOutput, w, pw.status)
+	}()
+
+	return pw
+}
+
+type streamWriter struct {
+	status chan *client.SolveStatus
+	done   chan struct{}
+	err    error
+}
+
+func (w *streamWriter) Done() <-chan struct{} {
+	return w.done
+}
+
+func (w *streamWriter) Err() error {
+	return w.err
+}
+
+func (w *streamWriter) Status() chan *client.SolveStatus {
+	return w.status
+}
+
 func StreamSolveStatus(ctx context.Context, logOutput LogOutput, w io.Writer, ch chan *client.SolveStatus) error {
 	var done bool
 	t := newTrace(w, logOutput)
@@ -34,8 +71,38 @@ func StreamSolveStatus(ctx context.Context, logOutput LogOutput, w io.Writer, ch
 	}
 }
 
+// EventType is the type of a streamed progress event.
+type EventType string
+
+const (
+	EventVertexStart    EventType = "vertex.start"
+	EventVertexComplete EventType = "vertex.complete"
+	EventVertexError    EventType = "vertex.error"
+	EventStatus         EventType = "status"
+	EventLog            EventType = "log"
+)
+
+// Event is a single line of the json log output. Each solve status is broken
+// down into events so that they can be consumed line by line.
+type Event struct {
+	Type      EventType     `json:"type"`
+	Vertex    digest.Digest `json:"vertex"`
+	Name      string        `json:"name,omitempty"`
+	Cached    bool          `json:"cached,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	ID        string        `json:"id,omitempty"`
+	Current   int64         `json:"current,omitempty"`
+	Total     int64         `json:"total,omitempty"`
+	Stream    int           `json:"stream,omitempty"`
+	Data      string        `json:"data,omitempty"`
+	Timestamp *time.Time    `json:"timestamp,omitempty"`
+	Started   *time.Time    `json:"started,omitempty"`
+	Completed *time.Time    `json:"completed,omitempty"`
+}
+
 type trace struct {
 	w         io.Writer
+	enc       *json.Encoder
 	logOutput LogOutput
 	byDigest  map[digest.Digest]string
 }
@@ -43,24 +110,89 @@ type trace struct {
 func newTrace(w io.Writer, logOutput LogOutput) *trace {
 	return &trace{
 		w:         w,
+		enc:       json.NewEncoder(w),
 		logOutput: logOutput,
 		byDigest:  make(map[digest.Digest]string),
 	}
 }
 
 func (t *trace) printSolveStatus(s *client.SolveStatus) error {
+	// Vertex names are only sent with vertex updates, so we keep track of them
+	// to annotate statuses and logs that only refer to the digest.
+	for _, v := range s.Vertexes {
+		t.byDigest[v.Digest] = v.Name
+	}
+
 	switch t.logOutput {
 	case LogOutputJSON:
-		dt, err := json.Marshal(s)
-		if err != nil {
-			return err
+		for _, event := range t.events(s) {
+			err := t.enc.Encode(event)
+			if err != nil {
+				return err
+			}
 		}
-
-		fmt.Fprint(t.w, string(dt))
 	case LogOutputRaw:
 		for _, l := range s.Logs {
-			fmt.Fprint(t.w, string(l.Data))
+			_, err := fmt.Fprint(t.w, string(l.Data))
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
+
+func (t *trace) events(s *client.SolveStatus) []*Event {
+	var events []*Event
+	for _, v := range s.Vertexes {
+		event := &Event{
+			Type:      EventVertexStart,
+			Vertex:    v.Digest,
+			Name:      v.Name,
+			Cached:    v.Cached,
+			Started:   v.Started,
+			Completed: v.Completed,
+		}
+
+		switch {
+		case v.Error != "":
+			event.Type = EventVertexError
+			event.Error = v.Error
+		case v.Completed != nil:
+			event.Type = EventVertexComplete
+		case v.Started == nil:
+			// Vertexes that are not started yet are only queued, so there is
+			// nothing to report yet.
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	for _, vs := range s.Statuses {
+		events = append(events, &Event{
+			Type:      EventStatus,
+			Vertex:    vs.Vertex,
+			Name:      t.byDigest[vs.Vertex],
+			ID:        vs.ID,
+			Current:   vs.Current,
+			Total:     vs.Total,
+			Timestamp: &vs.Timestamp,
+			Started:   vs.Started,
+			Completed: vs.Completed,
+		})
+	}
+
+	for _, l := range s.Logs {
+		events = append(events, &Event{
+			Type:      EventLog,
+			Vertex:    l.Vertex,
+			Name:      t.byDigest[l.Vertex],
+			Stream:    l.Stream,
+			Data:      string(l.Data),
+			Timestamp: &l.Timestamp,
+		})
+	}
+
+	return events
+}
@@ -0,0 +1,180 @@
+package solver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/client"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamWriter(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	completed := started.Add(time.Second)
+	dgst := digest.FromString("vertex")
+
+	statuses := []*client.SolveStatus{{
+		Vertexes: []*client.Vertex{{
+			Digest: digest.FromString("queued"),
+			Name:   "queued",
+		}, {
+			Digest:  dgst,
+			Name:    "run",
+			Started: &started,
+		}},
+	}, {
+		Statuses: []*client.VertexStatus{{
+			ID:        "layer",
+			Vertex:    dgst,
+			Current:   1,
+			Total:     2,
+			Timestamp: started,
+			Started:   &started,
+		}},
+		Logs: []*client.VertexLog{{
+			Vertex:    dgst,
+			Stream:    1,
+			Data:      []byte("hello\n"),
+			Timestamp: started,
+		}, {
+			Vertex:    dgst,
+			Stream:    2,
+			Data:      []byte("world\n"),
+			Timestamp: started,
+		}},
+	}, {
+		Vertexes: []*client.Vertex{{
+			Digest:    dgst,
+			Name:      "run",
+			Started:   &started,
+			Completed: &completed,
+		}, {
+			Digest:    digest.FromString("failed"),
+			Name:      "failed",
+			Started:   &started,
+			Completed: &completed,
+			Error:     "exit code 1",
+		}},
+	}}
+
+	type testCase struct {
+		name      string
+		logOutput LogOutput
+		fn        func(t *testing.T, output []byte)
+	}
+
+	for _, tc := range []testCase{{
+		"json",
+		LogOutputJSON,
+		func(t *testing.T, output []byte) {
+			var events []Event
+			scanner := bufio.NewScanner(bytes.NewReader(output))
+			for scanner.Scan() {
+				var event Event
+				err := json.Unmarshal(scanner.Bytes(), &event)
+				require.NoError(t, err)
+				events = append(events, event)
+			}
+			require.NoError(t, scanner.Err())
+
+			require.Equal(t, []Event{{
+				Type:    EventVertexStart,
+				Vertex:  dgst,
+				Name:    "run",
+				Started: &started,
+			}, {
+				Type:      EventStatus,
+				Vertex:    dgst,
+				Name:      "run",
+				ID:        "layer",
+				Current:   1,
+				Total:     2,
+				Timestamp: &started,
+				Started:   &started,
+			}, {
+				Type:      EventLog,
+				Vertex:    dgst,
+				Name:      "run",
+				Stream:    1,
+				Data:      "hello\n",
+				Timestamp: &started,
+			}, {
+				Type:      EventLog,
+				Vertex:    dgst,
+				Name:      "run",
+				Stream:    2,
+				Data:      "world\n",
+				Timestamp: &started,
+			}, {
+				Type:      EventVertexComplete,
+				Vertex:    dgst,
+				Name:      "run",
+				Started:   &started,
+				Completed: &completed,
+			}, {
+				Type:      EventVertexError,
+				Vertex:    digest.FromString("failed"),
+				Name:      "failed",
+				Error:     "exit code 1",
+				Started:   &started,
+				Completed: &completed,
+			}}, events)
+		},
+	}, {
+		"raw",
+		LogOutputRaw,
+		func(t *testing.T, output []byte) {
+			require.Equal(t, "hello\nworld\n", string(output))
+		},
+	}} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			pw := NewStreamWriter(context.Background(), tc.logOutput, &buf)
+
+			for _, status := range statuses {
+				pw.Status() <- status
+			}
+			close(pw.Status())
+
+			<-pw.Done()
+			require.NoError(t, pw.Err())
+
+			tc.fn(t, buf.Bytes())
+		})
+	}
+}
+
+func TestNewProgress_WithOutput(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	p, err := NewProgress(context.Background(), WithLogOutput(LogOutputRaw), WithOutput(&buf))
+	require.NoError(t, err)
+
+	p.Go(func(ctx context.Context) error {
+		defer p.Release()
+
+		pw := p.MultiWriter().WithPrefix("test", false)
+		defer close(pw.Status())
+
+		pw.Status() <- &client.SolveStatus{
+			Logs: []*client.VertexLog{{
+				Vertex: digest.FromString("vertex"),
+				Data:   []byte("hello\n"),
+			}},
+		}
+		return nil
+	})
+
+	err = p.Wait()
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", buf.String())
+}
@@ -476,6 +476,109 @@ func TestUnparse(t *testing.T) {
 
 			fs bar() { scratch; }
 			`,
+		}, {
+			"if else",
+			`
+			fs foo(bool cond) {
+				if equal localOs "darwin" {
+					image "alpine"
+				} else if cond { scratch; } else {
+					image "busybox"
+				}
+				if true { scratch; }; env "key" "value"
+			}
+			`,
+			`
+			fs foo(bool cond) {
+				if equal localOs "darwin" {
+					image "alpine"
+				} else if cond { scratch; } else {
+					image "busybox"
+				}
+				if true { scratch; }
+				env "key" "value"
+			}
+			`,
+		}, {
+			"bool literals",
+			`
+			fs foo() {
+				run "echo hi" with option {
+					readonlyRootfs false
+					if false { dir "/tmp"; }
+				}
+			}
+			`,
+			`
+			fs foo() {
+				run "echo hi" with option {
+					readonlyRootfs false
+					if false { dir "/tmp"; }
+				}
+			}
+			`,
+		}, {
+			"list literals",
+			`
+			fs foo([]string args) {
+				run ["echo", "hi"]
+				run []
+				run [
+					"echo",
+					args,
+				]
+			}
+			`,
+			`
+			fs foo([]string args) {
+				run ["echo", "hi"]
+				run []
+				run ["echo", args]
+			}
+			`,
+		}, {
+			"for loops",
+			`
+			fs foo() {
+				for arch in ["amd64", "arm64"] {
+					run "echo" arch
+				}
+				for name in split "a,b" "," { scratch; }; env "key" "value"
+			}
+			`,
+			`
+			fs foo() {
+				for arch in ["amd64", "arm64"] {
+					run "echo" arch
+				}
+				for name in split "a,b" "," { scratch; }
+				env "key" "value"
+			}
+			`,
+		}, {
+			"function types",
+			`
+			fs foo(fs( fs ) transform, string(string, int) format, fs() base) {
+				transform base
+			}
+			`,
+			`
+			fs foo(fs(fs) transform, string(string,int) format, fs() base) {
+				transform base
+			}
+			`,
+		}, {
+			"default values and named args",
+			`
+			fs foo(string name, int mode=0o644) {
+				bar name mode = 0o600
+			}
+			`,
+			`
+			fs foo(string name, int mode = 0o644) {
+				bar name mode=0o600
+			}
+			`,
 		}, {
 			`heredoc`,
 			`